@@ -7,8 +7,12 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"strings"
 )
 
+const keyAuthorization = "authorization"
+const prefixBearer = "Bearer "
+
 func SetOutgoingAuthInfo(src context.Context, groupId, userId string) (dst context.Context) {
 	dst = metadata.AppendToOutgoingContext(src, model.KeyGroupId, groupId, model.KeyUserId, userId)
 	return
@@ -27,6 +31,31 @@ func SetIncomingAuthInfo(src context.Context, groupId, userId string) (dst conte
 	return
 }
 
+// SetIncomingToken adds the bearer token to the incoming request metadata.
+func SetIncomingToken(src context.Context, token string) (dst context.Context) {
+	md, _ := metadata.FromIncomingContext(src)
+	md = metadata.Join(md, metadata.Pairs(keyAuthorization, prefixBearer+token))
+	dst = metadata.NewIncomingContext(src, md)
+	return
+}
+
+// GetIncomingToken returns the bearer token from the "authorization" request metadata.
+func GetIncomingToken(ctx context.Context) (token string, err error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		err = status.Error(codes.Unauthenticated, "missing request metadata")
+	}
+	if err == nil {
+		v := getMetadataValue(md, keyAuthorization)
+		var found bool
+		token, found = strings.CutPrefix(v, prefixBearer)
+		if !found || token == "" {
+			err = status.Error(codes.Unauthenticated, "missing authorization token")
+		}
+	}
+	return
+}
+
 func GetIncomingAuthInfo(ctx context.Context) (groupId, userId string, err error) {
 	groupId, userId, err = getIncomingAuthInfo(ctx, true)
 	return
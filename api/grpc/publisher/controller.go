@@ -0,0 +1,296 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/api/grpc/auth"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"go.uber.org/ratelimit"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"time"
+)
+
+type controller struct {
+	svc                     Service
+	writerInternalCfg       config.WriterInternalConfig
+	writerInternalRateLimit ratelimit.Limiter
+	authSvc                 auth.Service
+	ingest                  Ingest
+	cfgStream               config.StreamConfig
+	log                     *slog.Logger
+}
+
+func NewController(
+	svc Service,
+	writerInternalCfg config.WriterInternalConfig,
+	authSvc auth.Service,
+	ingest Ingest,
+	cfgStream config.StreamConfig,
+	log *slog.Logger,
+) ServiceServer {
+	return controller{
+		svc:                     svc,
+		writerInternalCfg:       writerInternalCfg,
+		writerInternalRateLimit: ratelimit.New(writerInternalCfg.RateLimitPerMinute, ratelimit.Per(time.Minute)),
+		authSvc:                 authSvc,
+		ingest:                  ingest,
+		cfgStream:               cfgStream,
+		log:                     log,
+	}
+}
+
+func (c controller) SubmitMessages(ctx context.Context, req *SubmitMessagesRequest) (resp *SubmitMessagesResponse, err error) {
+	var groupId, userId string
+	groupId, userId, err = auth.GetIncomingAuthInfo(ctx)
+	if err != nil {
+		return
+	}
+	var b Batch
+	b, err = c.ingest.Check(ctx, groupId, userId, req.Msgs)
+	if err != nil {
+		err = status.Error(codes.Internal, err.Error())
+		return
+	}
+	if reason, forbidden, refused := b.Refused(); refused {
+		code := codes.InvalidArgument
+		if forbidden {
+			code = codes.PermissionDenied
+		}
+		err = status.Error(code, reason)
+		return
+	}
+	switch len(b.Accepted) {
+	case 0:
+		resp = &SubmitMessagesResponse{}
+	default:
+		resp, err = c.svc.SubmitPermittedEvents(ctx, &SubmitMessagesRequest{Msgs: b.AcceptedEvents()}, groupId, userId)
+		err = c.checkAcked(resp, err)
+	}
+	if err == nil {
+		resp.Results = b.Merge(resp, userId)
+	}
+	return
+}
+
+// BlacklistedResult returns the result for the event filtered out by the blacklist match.
+func BlacklistedResult(m model.BlacklistMatch) *Result {
+	r := &Result{
+		Id:     m.EventId,
+		Status: ResultStatus_BLACKLISTED,
+		Reason: m.Reason(),
+	}
+	if m.Action == model.BlacklistActionQuarantine {
+		r.Status = ResultStatus_QUARANTINED
+	}
+	return r
+}
+
+// SubmitQuarantined publishes the events to the quarantine topic and returns the result per event: quarantined if
+// acknowledged, not acknowledged otherwise.
+func SubmitQuarantined(ctx context.Context, svc Service, evts []*pb.CloudEvent, matches []model.BlacklistMatch) (results []*Result) {
+	resp, err := svc.SubmitQuarantinedEvents(ctx, &SubmitMessagesRequest{Msgs: evts})
+	var acked int
+	if err == nil {
+		acked = int(resp.AckCount)
+	}
+	results = make([]*Result, 0, len(matches))
+	for i, m := range matches {
+		switch {
+		case i < acked:
+			results = append(results, BlacklistedResult(m))
+		default:
+			results = append(results, &Result{
+				Id:     m.EventId,
+				Status: ResultStatus_NOT_ACKED,
+				Reason: "was not quarantined, retry later",
+			})
+		}
+	}
+	return
+}
+
+func (c controller) SubmitInternalMessages(ctx context.Context, req *SubmitMessagesRequest) (resp *SubmitMessagesResponse, err error) {
+	// the internal writes bypass the permits and blacklist, so the bearer token is checked the same way as the HTTP
+	// internal writes do
+	var groupId, userId string
+	groupId, userId, err = auth.GetIncomingAuthInfo(ctx)
+	if err == nil {
+		err = c.authenticate(ctx, userId)
+	}
+	if err != nil {
+		return
+	}
+	c.writerInternalRateLimit.Take()
+	t := time.Now().UTC()
+	for _, evt := range req.Msgs {
+		model.SetPublisherAttributes(evt, groupId, userId, t)
+		evt.Attributes[c.writerInternalCfg.Name] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeInteger{
+				CeInteger: c.writerInternalCfg.Value,
+			},
+		}
+	}
+	resp, err = c.svc.SubmitInternalEvents(ctx, req)
+	err = c.checkAcked(resp, err)
+	return
+}
+
+func (c controller) authenticate(ctx context.Context, userId string) (err error) {
+	var token string
+	token, err = auth.GetIncomingToken(ctx)
+	if err == nil {
+		err = c.authSvc.Authenticate(ctx, userId, token)
+		switch {
+		case err == nil:
+		case errors.Is(err, auth.ErrInvalidToken):
+			err = status.Error(codes.Unauthenticated, "invalid token")
+		case errors.Is(err, auth.ErrInvalidUserId):
+			err = status.Error(codes.InvalidArgument, fmt.Sprintf("invalid user id: %s", userId))
+		default:
+			err = status.Error(codes.Internal, err.Error())
+		}
+	}
+	return
+}
+
+func (c controller) SubmitMessagesStream(stream Service_SubmitMessagesStreamServer) (err error) {
+	ctx := stream.Context()
+	var groupId, userId string
+	groupId, userId, err = auth.GetIncomingAuthInfo(ctx)
+	if err != nil {
+		return
+	}
+	// unbuffered, so the client stream is not read while the current chunk is being published
+	evts := make(chan *pb.CloudEvent)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(evts)
+		for {
+			evt, errRecv := stream.Recv()
+			if errRecv != nil {
+				if errRecv != io.EOF {
+					errs <- errRecv
+				}
+				return
+			}
+			select {
+			case evts <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	flush := time.NewTicker(c.cfgStream.FlushInterval)
+	defer flush.Stop()
+	var chunk []*pb.CloudEvent
+	for err == nil {
+		select {
+		case evt, ok := <-evts:
+			if !ok {
+				if len(chunk) > 0 {
+					err = c.submitChunk(stream, chunk, groupId, userId)
+				}
+				if err == nil {
+					select {
+					case err = <-errs:
+					default:
+					}
+				}
+				return
+			}
+			chunk = append(chunk, evt)
+			if uint32(len(chunk)) >= c.cfgStream.ChunkSize {
+				err = c.submitChunk(stream, chunk, groupId, userId)
+				chunk = nil
+			}
+		case <-flush.C:
+			if len(chunk) > 0 {
+				err = c.submitChunk(stream, chunk, groupId, userId)
+				chunk = nil
+			}
+		case err = <-errs:
+			// the client stream is broken, the pending chunk can't be acknowledged anyway
+		}
+	}
+	return
+}
+
+func (c controller) submitChunk(stream Service_SubmitMessagesStreamServer, chunk []*pb.CloudEvent, groupId, userId string) (err error) {
+	ctx := stream.Context()
+	var resp SubmitMessagesStreamResponse
+	var b Batch
+	b, err = c.ingest.Check(ctx, groupId, userId, chunk)
+	if err != nil {
+		err = status.Error(codes.Internal, err.Error())
+		return
+	}
+	// the invalid, blacklisted and quarantined events are not published, so these are reported as rejected
+	for _, r := range b.Results {
+		if r != nil {
+			resp.Rejections = append(resp.Rejections, &Rejection{
+				Id:     r.Id,
+				Reason: r.Reason,
+				Status: r.Status,
+			})
+		}
+	}
+	accepted := b.AcceptedEvents()
+	backoff := c.cfgStream.Backoff
+	for i := uint32(0); len(accepted) > 0; i++ {
+		var respSubmit *SubmitMessagesResponse
+		var errSubmit error
+		respSubmit, errSubmit = c.svc.SubmitPermittedEvents(ctx, &SubmitMessagesRequest{Msgs: accepted}, groupId, userId)
+		if errSubmit == nil {
+			resp.AckCount += respSubmit.AckCount
+			accepted = accepted[respSubmit.AckCount:]
+		}
+		var reason string
+		st := ResultStatus_NOT_ACKED
+		switch {
+		case len(accepted) == 0:
+		case errSubmit != nil && status.Code(errSubmit) == codes.ResourceExhausted:
+			reason = errSubmit.Error()
+			st = ResultStatus_OVER_LIMIT
+		case errSubmit != nil && status.Code(errSubmit) != codes.Unavailable:
+			reason = errSubmit.Error()
+		case i >= c.cfgStream.RetryCount:
+			reason = "was unable to submit, retry later"
+		}
+		if reason != "" {
+			for _, evt := range accepted {
+				resp.Rejections = append(resp.Rejections, &Rejection{
+					Id:     evt.Id,
+					Reason: reason,
+					Status: st,
+				})
+			}
+			break
+		}
+		if len(accepted) > 0 {
+			// the events service acknowledged only a part of the chunk, retry the remainder after a backoff
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+	err = stream.Send(&resp)
+	return
+}
+
+func (c controller) checkAcked(resp *SubmitMessagesResponse, src error) (dst error) {
+	dst = src
+	if src == nil && resp.AckCount == 0 {
+		dst = status.Error(codes.Unavailable, "was unable to submit, retry later")
+	}
+	return
+}
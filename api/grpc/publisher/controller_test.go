@@ -0,0 +1,446 @@
+package publisher
+
+import (
+	"context"
+	"github.com/awakari/pub/api/grpc/auth"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type authSvcMock struct{}
+
+func (am authSvcMock) Authenticate(ctx context.Context, userId, token string) (err error) {
+	switch {
+	case token != "token0":
+		err = auth.ErrInvalidToken
+	case userId == "fail":
+		err = auth.ErrInternal
+	}
+	return
+}
+
+func newIngestTest(blacklists model.BlacklistScopes) Ingest {
+	return NewIngest(
+		NewServiceMock(),
+		model.NewReservedAttributes([]string{"awk*"}, true),
+		model.NewBlacklistPolicy(blacklists, "awkflagged", slog.Default()),
+		storage.NewSchemasMock(),
+		false,
+		slog.Default(),
+	)
+}
+
+// newEventTest returns the conformant event of the specified type, "-" stands for the missing type.
+func newEventTest(src, typ string) *pb.CloudEvent {
+	switch typ {
+	case "":
+		typ = "com_awakari_test"
+	case "-":
+		typ = ""
+	}
+	return &pb.CloudEvent{
+		Id:          src,
+		Source:      src,
+		SpecVersion: model.CeSpecVersion,
+		Type:        typ,
+		Attributes:  make(map[string]*pb.CloudEventAttributeValue),
+	}
+}
+
+func TestController_SubmitMessages(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	_ = blacklist.Put(context.TODO(), "source:https://spam.example", model.BlacklistValue{})
+	_ = blacklist.Put(context.TODO(), "source:https://review.example", model.BlacklistValue{Action: model.BlacklistActionQuarantine})
+	_ = blacklist.Put(context.TODO(), "source:https://flag.example", model.BlacklistValue{Action: model.BlacklistActionTag})
+	_ = blacklists.Get("group0").Put(context.TODO(), "source:https://offtopic.example", model.BlacklistValue{})
+	c := NewController(NewServiceMock(), config.WriterInternalConfig{RateLimitPerMinute: 1}, authSvcMock{}, newIngestTest(blacklists), config.StreamConfig{}, slog.Default())
+	cases := map[string]struct {
+		groupId  string
+		userId   string
+		srcs     []string
+		typ      string
+		attrs    []string
+		ackCount uint32
+		results  []ResultStatus
+		flagged  bool
+		code     codes.Code
+		reason   string
+	}{
+		"ok": {
+			groupId:  "group0",
+			userId:   "user0",
+			srcs:     []string{"src0", "src1"},
+			ackCount: 2,
+		},
+		"reserved attribute": {
+			groupId: "group0",
+			userId:  "user0",
+			srcs:    []string{"src0"},
+			attrs:   []string{model.KeyToUserId},
+			code:    codes.PermissionDenied,
+		},
+		"non-conformant": {
+			groupId: "group0",
+			userId:  "user0",
+			srcs:    []string{"src0"},
+			typ:     "-",
+			code:    codes.InvalidArgument,
+		},
+		"schema violation": {
+			groupId: "group0",
+			userId:  "user0",
+			srcs:    []string{"src0"},
+			typ:     "com_awakari_webapp",
+			code:    codes.InvalidArgument,
+		},
+		"unauthenticated": {
+			srcs: []string{"src0"},
+			code: codes.Unauthenticated,
+		},
+		"all blacklisted": {
+			groupId: "group0",
+			userId:  "user0",
+			srcs:    []string{"https://spam.example/feed", "https://spam.example/feed2"},
+			code:    codes.PermissionDenied,
+			reason:  "forbidden by prefix: source:https://spam.example",
+		},
+		"all blacklisted, the first by the group": {
+			groupId: "group0",
+			userId:  "user0",
+			srcs:    []string{"https://offtopic.example/feed", "https://spam.example/feed"},
+			code:    codes.PermissionDenied,
+			reason:  "forbidden by prefix: source:https://offtopic.example",
+		},
+		"first blacklisted": {
+			groupId:  "group0",
+			userId:   "user0",
+			srcs:     []string{"https://spam.example/feed", "src1"},
+			ackCount: 1,
+			results: []ResultStatus{
+				ResultStatus_BLACKLISTED,
+				ResultStatus_ACCEPTED,
+			},
+		},
+		"filtered": {
+			groupId:  "group0",
+			userId:   "user0",
+			srcs:     []string{"src0", "https://spam.example/feed", "src1"},
+			ackCount: 2,
+			results: []ResultStatus{
+				ResultStatus_ACCEPTED,
+				ResultStatus_BLACKLISTED,
+				ResultStatus_ACCEPTED,
+			},
+		},
+		"group blacklisted": {
+			groupId:  "group0",
+			userId:   "user0",
+			srcs:     []string{"https://offtopic.example/feed", "src1"},
+			ackCount: 1,
+			results: []ResultStatus{
+				ResultStatus_BLACKLISTED,
+				ResultStatus_ACCEPTED,
+			},
+		},
+		"other group": {
+			groupId:  "group1",
+			userId:   "user0",
+			srcs:     []string{"https://offtopic.example/feed"},
+			ackCount: 1,
+		},
+		"quarantined": {
+			groupId:  "group0",
+			userId:   "user0",
+			srcs:     []string{"https://review.example/feed", "src1", "https://spam.example/feed"},
+			ackCount: 1,
+			results: []ResultStatus{
+				ResultStatus_QUARANTINED,
+				ResultStatus_ACCEPTED,
+				ResultStatus_BLACKLISTED,
+			},
+		},
+		"all quarantined": {
+			groupId: "group0",
+			userId:  "user0",
+			srcs:    []string{"https://review.example/feed"},
+			results: []ResultStatus{
+				ResultStatus_QUARANTINED,
+			},
+		},
+		"tagged": {
+			groupId:  "group0",
+			userId:   "user0",
+			srcs:     []string{"https://flag.example/feed"},
+			ackCount: 1,
+			flagged:  true,
+		},
+		"limit reached": {
+			groupId: "limit_reached",
+			userId:  "user0",
+			srcs:    []string{"src0"},
+			code:    codes.ResourceExhausted,
+		},
+		"unavailable": {
+			groupId: "unavailable",
+			userId:  "user0",
+			srcs:    []string{"src0"},
+			code:    codes.Unavailable,
+		},
+	}
+	for k, cs := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if cs.groupId != "" {
+				ctx = auth.SetIncomingAuthInfo(ctx, cs.groupId, cs.userId)
+			}
+			var req SubmitMessagesRequest
+			for _, src := range cs.srcs {
+				evt := newEventTest(src, cs.typ)
+				for _, a := range cs.attrs {
+					evt.Attributes[a] = &pb.CloudEventAttributeValue{
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "user1",
+						},
+					}
+				}
+				req.Msgs = append(req.Msgs, evt)
+			}
+			resp, err := c.SubmitMessages(ctx, &req)
+			assert.Equal(t, cs.code, status.Code(err))
+			if cs.reason != "" {
+				assert.Equal(t, cs.reason, status.Convert(err).Message())
+			}
+			if err == nil {
+				assert.Equal(t, cs.ackCount, resp.AckCount)
+				if cs.results != nil {
+					require.Len(t, resp.Results, len(cs.results))
+					for i, r := range resp.Results {
+						assert.Equal(t, cs.srcs[i], r.Id)
+						assert.Equal(t, cs.results[i], r.Status)
+					}
+				}
+				for i, evt := range req.Msgs {
+					if cs.results != nil && cs.results[i] == ResultStatus_BLACKLISTED {
+						continue
+					}
+					assert.Equal(t, cs.groupId, evt.Attributes[model.KeyCeGroupId].GetCeString())
+					assert.Equal(t, cs.userId, evt.Attributes[model.KeyCeUserId].GetCeString())
+					assert.Equal(t, cs.flagged, evt.Attributes["awkflagged"].GetCeBoolean())
+				}
+			}
+		})
+	}
+}
+
+func TestController_SubmitInternalMessages(t *testing.T) {
+	c := NewController(NewServiceMock(), config.WriterInternalConfig{Name: "awkinternal", Value: 1, RateLimitPerMinute: 60}, authSvcMock{}, newIngestTest(model.NewBlacklistScopes()), config.StreamConfig{}, slog.Default())
+	cases := map[string]struct {
+		groupId  string
+		userId   string
+		token    string
+		ackCount uint32
+		code     codes.Code
+	}{
+		"ok": {
+			groupId:  "group0",
+			userId:   "user0",
+			token:    "token0",
+			ackCount: 1,
+		},
+		"unauthenticated": {
+			token: "token0",
+			code:  codes.Unauthenticated,
+		},
+		"missing token": {
+			groupId: "group0",
+			userId:  "user0",
+			code:    codes.Unauthenticated,
+		},
+		"invalid token": {
+			groupId: "group0",
+			userId:  "user0",
+			token:   "token1",
+			code:    codes.Unauthenticated,
+		},
+		"auth failure": {
+			groupId: "group0",
+			userId:  "fail",
+			token:   "token0",
+			code:    codes.Internal,
+		},
+	}
+	for k, cs := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if cs.groupId != "" {
+				ctx = auth.SetIncomingAuthInfo(ctx, cs.groupId, cs.userId)
+			}
+			if cs.token != "" {
+				ctx = auth.SetIncomingToken(ctx, cs.token)
+			}
+			evt := &pb.CloudEvent{
+				Id:         "1",
+				Source:     "src1",
+				Attributes: make(map[string]*pb.CloudEventAttributeValue),
+			}
+			resp, err := c.SubmitInternalMessages(ctx, &SubmitMessagesRequest{Msgs: []*pb.CloudEvent{evt}})
+			assert.Equal(t, cs.code, status.Code(err))
+			if err == nil {
+				assert.Equal(t, cs.ackCount, resp.AckCount)
+				assert.Equal(t, cs.userId, evt.Attributes[model.KeyCeUserId].GetCeString())
+				assert.Equal(t, int32(1), evt.Attributes["awkinternal"].GetCeInteger())
+			}
+		})
+	}
+}
+
+type streamMock struct {
+	grpc.ServerStream
+	ctx context.Context
+	in  []*pb.CloudEvent
+	// errRecv is returned after all the input events are received, io.EOF by default
+	errRecv error
+	resps   []*SubmitMessagesStreamResponse
+}
+
+func (sm *streamMock) Context() context.Context {
+	return sm.ctx
+}
+
+func (sm *streamMock) Recv() (evt *pb.CloudEvent, err error) {
+	if len(sm.in) == 0 {
+		err = sm.errRecv
+		if err == nil {
+			err = io.EOF
+		}
+		return
+	}
+	evt, sm.in = sm.in[0], sm.in[1:]
+	return
+}
+
+func (sm *streamMock) Send(resp *SubmitMessagesStreamResponse) error {
+	sm.resps = append(sm.resps, resp)
+	return nil
+}
+
+func TestController_SubmitMessagesStream(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	_ = blacklist.Put(context.TODO(), "source:https://spam.example", model.BlacklistValue{})
+	_ = blacklist.Put(context.TODO(), "source:https://review.example", model.BlacklistValue{Action: model.BlacklistActionQuarantine})
+	_ = blacklist.Put(context.TODO(), "source:https://flag.example", model.BlacklistValue{Action: model.BlacklistActionTag})
+	cfgStream := config.StreamConfig{
+		ChunkSize:     2,
+		FlushInterval: time.Minute,
+		Backoff:       time.Millisecond,
+		RetryCount:    3,
+	}
+	c := NewController(NewServiceMock(), config.WriterInternalConfig{RateLimitPerMinute: 1}, authSvcMock{}, newIngestTest(blacklists), cfgStream, slog.Default())
+	cases := map[string]struct {
+		groupId  string
+		srcs     []string
+		typ      string
+		errRecv  error
+		ackCount uint32
+		rejected []string
+		statuses []ResultStatus
+		code     codes.Code
+	}{
+		"ok": {
+			groupId:  "group0",
+			srcs:     []string{"src0", "src1", "src2"},
+			ackCount: 3,
+		},
+		"unauthenticated": {
+			srcs: []string{"src0"},
+			code: codes.Unauthenticated,
+		},
+		"blacklisted": {
+			groupId:  "group0",
+			srcs:     []string{"src0", "https://spam.example/feed", "src2"},
+			ackCount: 2,
+			rejected: []string{"https://spam.example/feed"},
+			statuses: []ResultStatus{ResultStatus_BLACKLISTED},
+		},
+		"quarantined": {
+			groupId:  "group0",
+			srcs:     []string{"src0", "https://review.example/feed", "https://flag.example/feed"},
+			ackCount: 2,
+			rejected: []string{"https://review.example/feed"},
+			statuses: []ResultStatus{ResultStatus_QUARANTINED},
+		},
+		"invalid": {
+			groupId:  "group0",
+			srcs:     []string{"src0"},
+			typ:      "com_awakari_webapp",
+			rejected: []string{"src0"},
+			statuses: []ResultStatus{ResultStatus_INVALID},
+		},
+		"receive failure": {
+			groupId: "group0",
+			errRecv: status.Error(codes.Canceled, "client is gone"),
+			code:    codes.Canceled,
+		},
+		"partial ack": {
+			groupId:  "partial",
+			srcs:     []string{"src0", "src1", "src2"},
+			ackCount: 3,
+		},
+		"limit reached": {
+			groupId:  "limit_reached",
+			srcs:     []string{"src0", "src1"},
+			rejected: []string{"src0", "src1"},
+			statuses: []ResultStatus{ResultStatus_OVER_LIMIT, ResultStatus_OVER_LIMIT},
+		},
+		"retries exhausted": {
+			groupId:  "unavailable",
+			srcs:     []string{"src0"},
+			rejected: []string{"src0"},
+		},
+	}
+	for k, cs := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if cs.groupId != "" {
+				ctx = auth.SetIncomingAuthInfo(ctx, cs.groupId, "user0")
+			}
+			stream := &streamMock{
+				ctx:     ctx,
+				errRecv: cs.errRecv,
+			}
+			for _, src := range cs.srcs {
+				stream.in = append(stream.in, newEventTest(src, cs.typ))
+			}
+			err := c.SubmitMessagesStream(stream)
+			assert.Equal(t, cs.code, status.Code(err))
+			var ackCount uint32
+			var rejected []string
+			var statuses []ResultStatus
+			for _, resp := range stream.resps {
+				ackCount += resp.AckCount
+				for _, r := range resp.Rejections {
+					rejected = append(rejected, r.Id)
+					statuses = append(statuses, r.Status)
+				}
+			}
+			assert.Equal(t, cs.ackCount, ackCount)
+			assert.Equal(t, cs.rejected, rejected)
+			if cs.statuses != nil {
+				assert.Equal(t, cs.statuses, statuses)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Ingest applies the same checks to the published events on every transport, in the fixed order: the CloudEvents
+// conformance, the reserved attributes, the registered schema, then the blacklist policy.
+type Ingest interface {
+
+	// Check returns the checked batch. The quarantined events are submitted to the quarantine topic at once, the
+	// publisher attributes are set to the accepted events. Returns the error only when a check itself fails.
+	Check(ctx context.Context, groupId, userId string, evts []*pb.CloudEvent) (b Batch, err error)
+}
+
+// Batch is the outcome of the ingest checks.
+type Batch struct {
+	Events []*pb.CloudEvent
+	// Results are by the event index, nil for the accepted events.
+	Results []*Result
+	// Accepted are the indices of the events to publish.
+	Accepted []int
+	// Quarantined is the count of the events submitted to the quarantine topic.
+	Quarantined int
+	// violations describe the invalid events, forbidden is set if any of them has the reserved attribute.
+	violations []string
+	forbidden  bool
+	// blocked is the first blacklist match rejecting the event.
+	blocked *model.BlacklistMatch
+}
+
+type ingest struct {
+	svc       Service
+	reserved  model.ReservedAttributes
+	blacklist model.BlacklistPolicy
+	schemas   storage.Schemas
+	lenient   bool
+	log       *slog.Logger
+}
+
+const ceAttrDataSchema = "dataschema"
+
+func NewIngest(
+	svc Service,
+	reserved model.ReservedAttributes,
+	blacklist model.BlacklistPolicy,
+	schemas storage.Schemas,
+	lenient bool,
+	log *slog.Logger,
+) Ingest {
+	return ingest{
+		svc:       svc,
+		reserved:  reserved,
+		blacklist: blacklist,
+		schemas:   schemas,
+		lenient:   lenient,
+		log:       log,
+	}
+}
+
+func (in ingest) Check(ctx context.Context, groupId, userId string, evts []*pb.CloudEvent) (b Batch, err error) {
+	b.Events = evts
+	b.Results = make([]*Result, len(evts))
+	valid := make([]*pb.CloudEvent, 0, len(evts))
+	validIdxs := make([]int, 0, len(evts))
+	for i, evt := range evts {
+		errEvt := in.validate(ctx, evt, groupId, userId)
+		switch {
+		case errEvt == nil:
+			valid = append(valid, evt)
+			validIdxs = append(validIdxs, i)
+		case errors.Is(errEvt, model.ErrNonConformant), errors.Is(errEvt, model.ErrReservedAttribute), errors.Is(errEvt, model.ErrSchemaViolation):
+			if errors.Is(errEvt, model.ErrReservedAttribute) {
+				b.forbidden = true
+			}
+			b.Results[i] = &Result{
+				Id:     evt.Id,
+				Status: ResultStatus_INVALID,
+				Reason: errEvt.Error(),
+			}
+			b.violations = append(b.violations, fmt.Sprintf("event #%d, id: %s: %s", i, evt.Id, errEvt))
+		default:
+			err = errEvt
+			return
+		}
+	}
+	// dispatch on the blacklist rule action: the tagged events are published as usual
+	_, quarantined, filtered := model.FilterBlacklisted(ctx, in.blacklist, groupId, valid)
+	t := time.Now().UTC()
+	var qIdxs []int
+	var qMatches []model.BlacklistMatch
+	for j, i := range validIdxs {
+		m, found := filtered[j]
+		switch {
+		case !found:
+			model.SetPublisherAttributes(evts[i], groupId, userId, t)
+			b.Accepted = append(b.Accepted, i)
+			continue
+		case m.Action == model.BlacklistActionQuarantine:
+			model.SetPublisherAttributes(evts[i], groupId, userId, t)
+			qIdxs = append(qIdxs, i)
+			qMatches = append(qMatches, m)
+		default:
+			b.Results[i] = BlacklistedResult(m)
+			if b.blocked == nil {
+				b.blocked = &m
+			}
+		}
+		in.log.Info(fmt.Sprintf("event was %s by %s", m.Outcome(), m))
+	}
+	if len(quarantined) > 0 {
+		for k, r := range SubmitQuarantined(ctx, in.svc, quarantined, qMatches) {
+			b.Results[qIdxs[k]] = r
+		}
+		b.Quarantined = len(quarantined)
+	}
+	return
+}
+
+// validate checks the event conformance, reserved attributes and schema.
+func (in ingest) validate(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) (err error) {
+	err = model.ValidateConformance(evt, in.lenient)
+	if err == nil {
+		err = in.applyReserved(evt, groupId, userId)
+	}
+	if err == nil {
+		err = in.validateSchema(ctx, evt)
+	}
+	return
+}
+
+// applyReserved strips or rejects the reserved attributes set by the regular publisher.
+func (in ingest) applyReserved(evt *pb.CloudEvent, groupId, userId string) (err error) {
+	var names []string
+	names, err = in.reserved.Apply(evt)
+	if len(names) > 0 {
+		in.log.Warn(fmt.Sprintf("reserved attributes %v in the event %s by group: %s, user: %s, rejected: %t", names, evt.Id, groupId, userId, err != nil))
+	}
+	return
+}
+
+// validateSchema checks the event against the latest schema registered for its type and data schema, falling back to
+// the schema registered for the type only. The event is considered valid when no schema is registered.
+func (in ingest) validateSchema(ctx context.Context, evt *pb.CloudEvent) (err error) {
+	var dataSchema string
+	if attr, ok := evt.Attributes[ceAttrDataSchema]; ok {
+		dataSchema = attr.GetCeUri()
+		if dataSchema == "" {
+			dataSchema = attr.GetCeString()
+		}
+	}
+	var s model.Schema
+	s, err = in.schemas.Read(ctx, evt.Type, dataSchema, 0)
+	if errors.Is(err, storage.ErrNotFound) && dataSchema != "" {
+		s, err = in.schemas.Read(ctx, evt.Type, "", 0)
+	}
+	switch {
+	case err == nil:
+		err = s.Validate(evt)
+	case errors.Is(err, storage.ErrNotFound):
+		err = nil
+	}
+	return
+}
+
+// AcceptedEvents returns the events to publish.
+func (b Batch) AcceptedEvents() (evts []*pb.CloudEvent) {
+	evts = make([]*pb.CloudEvent, 0, len(b.Accepted))
+	for _, i := range b.Accepted {
+		evts = append(evts, b.Events[i])
+	}
+	return
+}
+
+// Refused returns the reason when none of the events is published nor quarantined. Forbidden is set when the events
+// are blacklisted or contain the reserved attributes.
+func (b Batch) Refused() (reason string, forbidden, refused bool) {
+	if len(b.Events) == 0 || len(b.Accepted) > 0 || b.Quarantined > 0 {
+		return
+	}
+	refused = true
+	switch {
+	case b.blocked != nil:
+		reason = b.blocked.Reason()
+		forbidden = true
+	default:
+		reason = strings.Join(b.violations, "\n")
+		forbidden = b.forbidden
+	}
+	return
+}
+
+// Merge returns the result per every event in the source order given the response of publishing the accepted events.
+func (b Batch) Merge(resp *SubmitMessagesResponse, userId string) (results []*Result) {
+	results = slices.Clone(b.Results)
+	submitted := resp.Results
+	if len(submitted) != len(b.Accepted) {
+		submitted = NewResults(b.AcceptedEvents(), uint32(len(b.Accepted)), resp.AckCount, userId)
+	}
+	for j, i := range b.Accepted {
+		results[i] = submitted[j]
+	}
+	return
+}
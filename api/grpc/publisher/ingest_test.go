@@ -0,0 +1,169 @@
+package publisher
+
+import (
+	"context"
+	"github.com/awakari/pub/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestIngest_Check(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	_ = blacklist.Put(context.TODO(), "source:https://spam.example", model.BlacklistValue{})
+	_ = blacklist.Put(context.TODO(), "source:https://review.example", model.BlacklistValue{Action: model.BlacklistActionQuarantine})
+	in := newIngestTest(blacklists)
+	cases := map[string]struct {
+		evts        []*pb.CloudEvent
+		results     []ResultStatus
+		accepted    []int
+		quarantined int
+		refused     bool
+		forbidden   bool
+		reason      string
+	}{
+		"empty": {},
+		"ok": {
+			evts: []*pb.CloudEvent{
+				newEventTest("src0", ""),
+				newEventTest("src1", ""),
+			},
+			results:  []ResultStatus{-1, -1},
+			accepted: []int{0, 1},
+		},
+		"mixed": {
+			evts: []*pb.CloudEvent{
+				newEventTest("src0", "-"),
+				newEventTest("https://spam.example/feed", ""),
+				newEventTest("src2", ""),
+				newEventTest("https://review.example/feed", ""),
+				newEventTest("src4", "com_awakari_webapp"),
+			},
+			results: []ResultStatus{
+				ResultStatus_INVALID,
+				ResultStatus_BLACKLISTED,
+				-1,
+				ResultStatus_QUARANTINED,
+				ResultStatus_INVALID,
+			},
+			accepted:    []int{2},
+			quarantined: 1,
+		},
+		"all invalid": {
+			evts: []*pb.CloudEvent{
+				newEventTest("src0", "-"),
+				newEventTest("src1", "com_awakari_webapp"),
+			},
+			results: []ResultStatus{
+				ResultStatus_INVALID,
+				ResultStatus_INVALID,
+			},
+			refused: true,
+		},
+		"all blacklisted": {
+			evts: []*pb.CloudEvent{
+				newEventTest("src0", "-"),
+				newEventTest("https://spam.example/feed", ""),
+			},
+			results: []ResultStatus{
+				ResultStatus_INVALID,
+				ResultStatus_BLACKLISTED,
+			},
+			refused:   true,
+			forbidden: true,
+			reason:    "forbidden by prefix: source:https://spam.example",
+		},
+		"all quarantined": {
+			evts: []*pb.CloudEvent{
+				newEventTest("https://review.example/feed", ""),
+			},
+			results: []ResultStatus{
+				ResultStatus_QUARANTINED,
+			},
+			quarantined: 1,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			b, err := in.Check(context.TODO(), "group0", "user0", c.evts)
+			require.NoError(t, err)
+			require.Len(t, b.Results, len(c.results))
+			for i, st := range c.results {
+				switch st {
+				case -1:
+					assert.Nil(t, b.Results[i])
+				default:
+					assert.Equal(t, st, b.Results[i].Status)
+				}
+			}
+			assert.Equal(t, c.accepted, b.Accepted)
+			assert.Equal(t, c.quarantined, b.Quarantined)
+			for _, i := range b.Accepted {
+				assert.Equal(t, "user0", c.evts[i].Attributes[model.KeyCeUserId].GetCeString())
+			}
+			reason, forbidden, refused := b.Refused()
+			assert.Equal(t, c.refused, refused)
+			assert.Equal(t, c.forbidden, forbidden)
+			if c.reason != "" {
+				assert.Equal(t, c.reason, reason)
+			}
+		})
+	}
+}
+
+func TestBatch_Merge(t *testing.T) {
+	b := Batch{
+		Events: []*pb.CloudEvent{
+			{Id: "0"},
+			{Id: "1"},
+			{Id: "2"},
+		},
+		Results: []*Result{
+			nil,
+			{Id: "1", Status: ResultStatus_BLACKLISTED},
+			nil,
+		},
+		Accepted: []int{0, 2},
+	}
+	cases := map[string]struct {
+		resp    *SubmitMessagesResponse
+		results []ResultStatus
+	}{
+		"results": {
+			resp: &SubmitMessagesResponse{
+				AckCount: 1,
+				Results: []*Result{
+					{Id: "0", Status: ResultStatus_ACCEPTED},
+					{Id: "2", Status: ResultStatus_OVER_LIMIT},
+				},
+			},
+			results: []ResultStatus{
+				ResultStatus_ACCEPTED,
+				ResultStatus_BLACKLISTED,
+				ResultStatus_OVER_LIMIT,
+			},
+		},
+		"no results": {
+			resp: &SubmitMessagesResponse{
+				AckCount: 1,
+			},
+			results: []ResultStatus{
+				ResultStatus_ACCEPTED,
+				ResultStatus_BLACKLISTED,
+				ResultStatus_NOT_ACKED,
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			results := b.Merge(c.resp, "user0")
+			require.Len(t, results, len(c.results))
+			for i, st := range c.results {
+				assert.Equal(t, st, results[i].Status)
+				assert.Equal(t, b.Events[i].Id, results[i].Id)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package publisher
+
+import (
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"net"
+)
+
+func Serve(port uint16, c ServiceServer) (err error) {
+	srv := grpc.NewServer()
+	RegisterServiceServer(srv, c)
+	reflection.Register(srv)
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	var conn net.Listener
+	conn, err = net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err == nil {
+		err = srv.Serve(conn)
+	}
+	return
+}
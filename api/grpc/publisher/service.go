@@ -13,17 +13,22 @@ import (
 	"github.com/segmentio/ksuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"sync/atomic"
 )
 
 type Service interface {
 	SubmitPermittedEvents(ctx context.Context, req *SubmitMessagesRequest, groupId, userId string) (resp *SubmitMessagesResponse, err error)
 	SubmitInternalEvents(ctx context.Context, req *SubmitMessagesRequest) (resp *SubmitMessagesResponse, err error)
+	// SubmitQuarantinedEvents publishes the events to the quarantine topic for the review. Doesn't use the permits.
+	SubmitQuarantinedEvents(ctx context.Context, req *SubmitMessagesRequest) (resp *SubmitMessagesResponse, err error)
 }
 
 type svc struct {
 	client     events.ServiceClient
 	svcPermits permits.Service
 	cfgEvts    config.EventsConfig
+	// quarantineStream is set once the quarantine topic stream is created, so it's created on the first use only.
+	quarantineStream *atomic.Bool
 }
 
 const subjPubMsgs = model.SubjectPublishEvents
@@ -35,9 +40,10 @@ If you did not publish messages, <a href="https://awakari.com/pub.html?own=true"
 
 func NewService(client events.ServiceClient, svcPermits permits.Service, cfgEvts config.EventsConfig) Service {
 	return svc{
-		client:     client,
-		svcPermits: svcPermits,
-		cfgEvts:    cfgEvts,
+		client:           client,
+		svcPermits:       svcPermits,
+		cfgEvts:          cfgEvts,
+		quarantineStream: &atomic.Bool{},
 	}
 }
 
@@ -53,6 +59,7 @@ func (s svc) SubmitPermittedEvents(ctx context.Context, req *SubmitMessagesReque
 	var usedCount uint32
 	if err == nil {
 		usedCount = resp.AckCount
+		resp.Results = NewResults(req.Msgs, permit.Count, resp.AckCount, permit.UserId)
 	}
 	// release the unused permit count
 	unusedCount := permit.Count - usedCount
@@ -63,16 +70,62 @@ func (s svc) SubmitPermittedEvents(ctx context.Context, req *SubmitMessagesReque
 }
 
 func (s svc) SubmitInternalEvents(ctx context.Context, req *SubmitMessagesRequest) (resp *SubmitMessagesResponse, err error) {
+	resp, err = s.submitToTopic(ctx, req, s.cfgEvts.Topic)
+	return
+}
+
+func (s svc) SubmitQuarantinedEvents(ctx context.Context, req *SubmitMessagesRequest) (resp *SubmitMessagesResponse, err error) {
+	// the quarantine is rarely used, don't require it at the startup, retry on the next submission when failed
+	if !s.quarantineStream.Load() {
+		_, err = s.client.SetStream(ctx, &events.SetStreamRequest{
+			Topic: s.cfgEvts.QuarantineTopic,
+			Limit: s.cfgEvts.Limit,
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to set the quarantine stream: %w", err)
+			return
+		}
+		s.quarantineStream.Store(true)
+	}
+	resp, err = s.submitToTopic(ctx, req, s.cfgEvts.QuarantineTopic)
+	return
+}
+
+func (s svc) submitToTopic(ctx context.Context, req *SubmitMessagesRequest, topic string) (resp *SubmitMessagesResponse, err error) {
 	// proxy a request
 	var dstResp *events.PublishResponse
 	dstResp, err = s.client.PublishBatch(ctx, &events.PublishRequest{
-		Topic: s.cfgEvts.Topic,
+		Topic: topic,
 		Evts:  req.Msgs,
 	})
 	if dstResp != nil {
 		resp = &SubmitMessagesResponse{
 			AckCount: dstResp.AckCount,
+			Results:  NewResults(req.Msgs, uint32(len(req.Msgs)), dstResp.AckCount, ""),
+		}
+	}
+	return
+}
+
+// NewResults returns the result per every event: the first acked events are accepted, the rest of the permitted
+// events are not acknowledged, the remaining events are over the limit of the specified user.
+func NewResults(evts []*pb.CloudEvent, permitted, acked uint32, userId string) (results []*Result) {
+	results = make([]*Result, len(evts))
+	for i, evt := range evts {
+		r := &Result{
+			Id: evt.Id,
+		}
+		switch {
+		case uint32(i) < acked:
+			r.Status = ResultStatus_ACCEPTED
+		case uint32(i) < permitted:
+			r.Status = ResultStatus_NOT_ACKED
+			r.Reason = "was not acknowledged, retry later"
+		default:
+			r.Status = ResultStatus_OVER_LIMIT
+			r.Reason = fmt.Sprintf("user id %s: usage limit reached", userId)
 		}
+		results[i] = r
 	}
 	return
 }
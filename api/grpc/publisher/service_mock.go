@@ -0,0 +1,66 @@
+package publisher
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type serviceMock struct {
+}
+
+func NewServiceMock() Service {
+	return serviceMock{}
+}
+
+func (sm serviceMock) SubmitPermittedEvents(ctx context.Context, req *SubmitMessagesRequest, groupId, userId string) (resp *SubmitMessagesResponse, err error) {
+	switch groupId {
+	case "fail":
+		err = status.Error(codes.Internal, "internal failure")
+	case "limit_reached":
+		err = status.Error(codes.ResourceExhausted, "limit reached")
+	case "unavailable":
+		resp = &SubmitMessagesResponse{}
+	case "partial":
+		resp = &SubmitMessagesResponse{
+			AckCount: min(uint32(len(req.Msgs)), 1),
+		}
+	case "over_limit":
+		resp = &SubmitMessagesResponse{
+			AckCount: min(uint32(len(req.Msgs)), 1),
+		}
+	default:
+		resp = &SubmitMessagesResponse{
+			AckCount: uint32(len(req.Msgs)),
+		}
+	}
+	if resp != nil {
+		permitted := uint32(len(req.Msgs))
+		if groupId == "over_limit" {
+			permitted = resp.AckCount
+		}
+		resp.Results = NewResults(req.Msgs, permitted, resp.AckCount, userId)
+	}
+	return
+}
+
+func (sm serviceMock) SubmitInternalEvents(ctx context.Context, req *SubmitMessagesRequest) (resp *SubmitMessagesResponse, err error) {
+	resp = &SubmitMessagesResponse{
+		AckCount: uint32(len(req.Msgs)),
+		Results:  NewResults(req.Msgs, uint32(len(req.Msgs)), uint32(len(req.Msgs)), ""),
+	}
+	return
+}
+
+func (sm serviceMock) SubmitQuarantinedEvents(ctx context.Context, req *SubmitMessagesRequest) (resp *SubmitMessagesResponse, err error) {
+	switch {
+	case len(req.Msgs) > 0 && req.Msgs[0].Id == "quarantine_fail":
+		err = status.Error(codes.Internal, "internal failure")
+	default:
+		resp = &SubmitMessagesResponse{
+			AckCount: uint32(len(req.Msgs)),
+			Results:  NewResults(req.Msgs, uint32(len(req.Msgs)), uint32(len(req.Msgs)), ""),
+		}
+	}
+	return
+}
@@ -0,0 +1,52 @@
+package publisher
+
+import (
+	"context"
+	"github.com/awakari/pub/api/grpc/events"
+	"github.com/awakari/pub/config"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSvc_SubmitQuarantinedEvents(t *testing.T) {
+	cases := map[string]struct {
+		topic    string
+		ackCount uint32
+		err      bool
+	}{
+		"ok": {
+			topic:    "quarantine",
+			ackCount: 42,
+		},
+		"stream failure": {
+			topic: "fail",
+			err:   true,
+		},
+		"empty topic": {
+			err: true,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			s := NewService(events.NewClientMock(), nil, config.EventsConfig{
+				QuarantineTopic: c.topic,
+			})
+			// the stream is retried until set
+			for range 2 {
+				resp, err := s.SubmitQuarantinedEvents(context.TODO(), &SubmitMessagesRequest{
+					Msgs: []*pb.CloudEvent{
+						{
+							Id: "evt0",
+						},
+					},
+				})
+				assert.Equal(t, c.err, err != nil)
+				if err == nil {
+					assert.Equal(t, c.ackCount, resp.AckCount)
+				}
+			}
+			assert.Equal(t, !c.err, s.(svc).quarantineStream.Load())
+		})
+	}
+}
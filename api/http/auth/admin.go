@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// AdminAuth allows the request only for the configured administrators. Expects the group and user ids to be set by the
+// preceding Handler.Authorize.
+type AdminAuth interface {
+	// Authorize sets the KeyAdminGroupId when the user administers only the own group.
+	Authorize(ctx *gin.Context)
+
+	// AuthorizeGlobal allows the request only for the administrators not limited to a group, e.g. to manage the
+	// settings shared by all groups.
+	AuthorizeGlobal(ctx *gin.Context)
+}
+
+// KeyAdminGroupId is the request context key of the group administered by the group admin.
+const KeyAdminGroupId = "admin-group-id"
+
+type adminAuth struct {
+	userIds map[string]bool
+	// groupUserIds are keyed by "<group id>:<user id>"
+	groupUserIds map[string]bool
+}
+
+func NewAdminValidator(cfg config.HttpAdminConfig) AdminAuth {
+	userIds := make(map[string]bool, len(cfg.UserIds))
+	for _, userId := range cfg.UserIds {
+		if userId != "" {
+			userIds[userId] = true
+		}
+	}
+	groupUserIds := make(map[string]bool, len(cfg.GroupUserIds))
+	for _, pair := range cfg.GroupUserIds {
+		groupId, userId, ok := strings.Cut(pair, ":")
+		if ok && groupId != "" && userId != "" {
+			groupUserIds[pair] = true
+		}
+	}
+	return adminAuth{
+		userIds:      userIds,
+		groupUserIds: groupUserIds,
+	}
+}
+
+func (aa adminAuth) Authorize(ctx *gin.Context) {
+	userId := ctx.GetString(model.KeyUserId)
+	// the group id comes from the unauthenticated header, so only the configured group and user pair grants the access
+	groupId := ctx.GetString(model.KeyGroupId)
+	switch {
+	case aa.userIds[userId]:
+	case groupId != "" && aa.groupUserIds[groupId+":"+userId]:
+		ctx.Set(KeyAdminGroupId, groupId)
+	default:
+		ctx.String(http.StatusForbidden, "admin permission required")
+		ctx.Abort()
+		return
+	}
+	return
+}
+
+func (aa adminAuth) AuthorizeGlobal(ctx *gin.Context) {
+	if !aa.userIds[ctx.GetString(model.KeyUserId)] {
+		ctx.String(http.StatusForbidden, "admin permission required")
+		ctx.Abort()
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuth_Authorize(t *testing.T) {
+	aa := NewAdminValidator(config.HttpAdminConfig{
+		UserIds: []string{
+			"admin0",
+		},
+		GroupUserIds: []string{
+			"group0:user0",
+			"invalid",
+		},
+	})
+	cases := map[string]struct {
+		groupId      string
+		userId       string
+		status       int
+		adminGroupId string
+	}{
+		"admin": {
+			groupId: "group0",
+			userId:  "admin0",
+			status:  http.StatusOK,
+		},
+		"group admin": {
+			groupId:      "group0",
+			userId:       "user0",
+			status:       http.StatusOK,
+			adminGroupId: "group0",
+		},
+		"group admin of other group": {
+			groupId: "group1",
+			userId:  "user0",
+			status:  http.StatusForbidden,
+		},
+		"not admin": {
+			groupId: "group0",
+			userId:  "user1",
+			status:  http.StatusForbidden,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/admin/blacklist", nil)
+			ctx.Set(model.KeyGroupId, c.groupId)
+			ctx.Set(model.KeyUserId, c.userId)
+			aa.Authorize(ctx)
+			assert.Equal(t, c.status, w.Code)
+			assert.Equal(t, c.adminGroupId, ctx.GetString(KeyAdminGroupId))
+		})
+	}
+}
+
+func TestAdminAuth_AuthorizeGlobal(t *testing.T) {
+	aa := NewAdminValidator(config.HttpAdminConfig{
+		UserIds: []string{
+			"admin0",
+		},
+		GroupUserIds: []string{
+			"group0:user0",
+		},
+	})
+	cases := map[string]struct {
+		groupId string
+		userId  string
+		status  int
+	}{
+		"admin": {
+			groupId: "group0",
+			userId:  "admin0",
+			status:  http.StatusOK,
+		},
+		"group admin": {
+			groupId: "group0",
+			userId:  "user0",
+			status:  http.StatusForbidden,
+		},
+		"not admin": {
+			groupId: "group0",
+			userId:  "user1",
+			status:  http.StatusForbidden,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/schema", nil)
+			ctx.Set(model.KeyGroupId, c.groupId)
+			ctx.Set(model.KeyUserId, c.userId)
+			aa.AuthorizeGlobal(ctx)
+			assert.Equal(t, c.status, w.Code)
+			assert.Equal(t, c.status != http.StatusOK, ctx.IsAborted())
+		})
+	}
+}
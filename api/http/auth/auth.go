@@ -6,10 +6,16 @@ import (
 	"github.com/awakari/pub/api/grpc/auth"
 	"github.com/awakari/pub/model"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"net/http"
 	"strings"
 )
 
+const keyQueryToken = "token"
+
+// WsProtocolBearerPrefix prefixes the token offered as the WebSocket subprotocol.
+const WsProtocolBearerPrefix = "bearer."
+
 type Handler struct {
 	Svc auth.Service
 }
@@ -48,3 +54,41 @@ func (h Handler) Authorize(ctx *gin.Context) {
 	}
 	return
 }
+
+// AuthorizeWebSocket does the same as Authorize but falls back to the Sec-WebSocket-Protocol header for the token and
+// to the query parameters for the group and user ids, because the browser WebSocket API doesn't allow to set the
+// request headers. The token is never taken from the query, so it doesn't get to the access logs.
+func (h Handler) AuthorizeWebSocket(ctx *gin.Context) {
+	header := ctx.Request.Header
+	if header.Get("Authorization") == "" {
+		if token := wsProtocolToken(ctx.Request); token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	for _, k := range []string{model.KeyGroupId, model.KeyUserId} {
+		if header.Get(k) == "" {
+			header.Set(k, ctx.Query(k))
+		}
+	}
+	h.Authorize(ctx)
+}
+
+// wsProtocolToken returns the token offered as the "bearer.<token>" WebSocket subprotocol.
+func wsProtocolToken(req *http.Request) (token string) {
+	for _, p := range websocket.Subprotocols(req) {
+		if t, ok := strings.CutPrefix(p, WsProtocolBearerPrefix); ok {
+			token = t
+			break
+		}
+	}
+	return
+}
+
+// StripQueryToken removes the token query parameter, so it doesn't get to the request log. Should precede the logger.
+func StripQueryToken(ctx *gin.Context) {
+	u := ctx.Request.URL
+	if q := u.Query(); q.Has(keyQueryToken) {
+		q.Del(keyQueryToken)
+		u.RawQuery = q.Encode()
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"github.com/awakari/pub/api/grpc/auth"
+	"github.com/awakari/pub/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type authSvcMock struct{}
+
+func (am authSvcMock) Authenticate(ctx context.Context, userId, token string) (err error) {
+	if token != "token0" {
+		err = auth.ErrInvalidToken
+	}
+	return
+}
+
+func TestHandler_AuthorizeWebSocket(t *testing.T) {
+	h := Handler{
+		Svc: authSvcMock{},
+	}
+	cases := map[string]struct {
+		uri       string
+		protocols string
+		auth      string
+		status    int
+	}{
+		"subprotocol token": {
+			uri:       "/v1/ws?x-awakari-group-id=group0&x-awakari-user-id=user0",
+			protocols: "awakari.v1, bearer.token0",
+			status:    http.StatusOK,
+		},
+		"header token": {
+			uri:    "/v1/ws?x-awakari-group-id=group0&x-awakari-user-id=user0",
+			auth:   "Bearer token0",
+			status: http.StatusOK,
+		},
+		"invalid subprotocol token": {
+			uri:       "/v1/ws?x-awakari-group-id=group0&x-awakari-user-id=user0",
+			protocols: "awakari.v1, bearer.token1",
+			status:    http.StatusUnauthorized,
+		},
+		"query token is ignored": {
+			uri:       "/v1/ws?x-awakari-group-id=group0&x-awakari-user-id=user0&token=token0",
+			protocols: "awakari.v1",
+			status:    http.StatusUnauthorized,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, c.uri, nil)
+			if c.protocols != "" {
+				ctx.Request.Header.Set("Sec-WebSocket-Protocol", c.protocols)
+			}
+			if c.auth != "" {
+				ctx.Request.Header.Set("Authorization", c.auth)
+			}
+			h.AuthorizeWebSocket(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.status == http.StatusOK {
+				assert.Equal(t, "group0", ctx.GetString(model.KeyGroupId))
+				assert.Equal(t, "user0", ctx.GetString(model.KeyUserId))
+			}
+		})
+	}
+}
+
+func TestStripQueryToken(t *testing.T) {
+	cases := map[string]struct {
+		uri      string
+		rawQuery string
+	}{
+		"token": {
+			uri:      "/v1/ws?token=token0&x-awakari-group-id=group0",
+			rawQuery: "x-awakari-group-id=group0",
+		},
+		"no token": {
+			uri:      "/v1/ws?x-awakari-group-id=group0",
+			rawQuery: "x-awakari-group-id=group0",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, c.uri, nil)
+			StripQueryToken(ctx)
+			assert.Equal(t, c.rawQuery, ctx.Request.URL.RawQuery)
+		})
+	}
+}
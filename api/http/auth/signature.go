@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const KeySignature = "X-Signature"
+const KeyTimestamp = "X-Timestamp"
+
+const prefixSignature = "sha256="
+
+// SignatureAuth authorizes the webhook requests signed with the hook owner's secret, so the external systems don't need
+// the user token.
+type SignatureAuth interface {
+
+	// Authorize verifies the signature when the request has one and sets the hook owner's group and user ids.
+	// Otherwise, it falls back to the token authorization.
+	Authorize(ctx *gin.Context)
+}
+
+type signatureAuth struct {
+	hooks    storage.Hooks
+	secrets  storage.Secrets
+	cfg      config.HookSignatureConfig
+	fallback gin.HandlerFunc
+	replays  storage.Replays
+}
+
+func NewSignatureValidator(hooks storage.Hooks, secrets storage.Secrets, replays storage.Replays, cfg config.HookSignatureConfig, fallback gin.HandlerFunc) SignatureAuth {
+	return signatureAuth{
+		hooks:    hooks,
+		secrets:  secrets,
+		cfg:      cfg,
+		fallback: fallback,
+		replays:  replays,
+	}
+}
+
+// Sign returns the value for the KeySignature header: HMAC-SHA256 over the timestamp, "." and the request body.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return prefixSignature + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (sa signatureAuth) Authorize(ctx *gin.Context) {
+	sig := ctx.GetHeader(KeySignature)
+	if sig == "" {
+		sa.fallback(ctx)
+		return
+	}
+	if !strings.HasPrefix(sig, prefixSignature) {
+		ctx.String(http.StatusUnauthorized, fmt.Sprintf("unsupported signature, expected %s...", prefixSignature))
+		ctx.Abort()
+		return
+	}
+	tsStr := ctx.GetHeader(KeyTimestamp)
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
+	if err != nil {
+		ctx.String(http.StatusUnauthorized, fmt.Sprintf("missing or invalid %s header: %s", KeyTimestamp, tsStr))
+		ctx.Abort()
+		return
+	}
+	now := time.Now()
+	t := time.Unix(ts, 0)
+	if t.Before(now.Add(-sa.cfg.Tolerance)) || t.After(now.Add(sa.cfg.Tolerance)) {
+		ctx.String(http.StatusUnauthorized, fmt.Sprintf("request timestamp is outside of the allowed window: %s", tsStr))
+		ctx.Abort()
+		return
+	}
+	var body []byte
+	body, err = io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, sa.cfg.BodySizeMax))
+	_ = ctx.Request.Body.Close()
+	if err != nil {
+		ctx.String(http.StatusRequestEntityTooLarge, err.Error())
+		ctx.Abort()
+		return
+	}
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	var groupId, userId string
+	groupId, userId, err = sa.verify(ctx, ctx.Param("id"), sig, ts, body)
+	switch {
+	case err == nil:
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, errSignature):
+		// don't disclose whether the hook exists
+		ctx.String(http.StatusUnauthorized, "invalid signature")
+		ctx.Abort()
+		return
+	case errors.Is(err, storage.ErrSecretsDisabled):
+		ctx.String(http.StatusNotImplemented, err.Error())
+		ctx.Abort()
+		return
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+		ctx.Abort()
+		return
+	}
+	// the signature is remembered until the request timestamp leaves the allowed window
+	err = sa.replays.Add(ctx, sig, t.Add(sa.cfg.Tolerance))
+	switch {
+	case err == nil:
+	case errors.Is(err, storage.ErrConflict):
+		ctx.String(http.StatusUnauthorized, "replayed request")
+		ctx.Abort()
+		return
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+		ctx.Abort()
+		return
+	}
+	ctx.Set(model.KeyGroupId, groupId)
+	ctx.Set(model.KeyUserId, userId)
+	return
+}
+
+var errSignature = errors.New("signature mismatch")
+
+func (sa signatureAuth) verify(ctx context.Context, hookId, sig string, ts int64, body []byte) (groupId, userId string, err error) {
+	var h model.Hook
+	h, err = sa.hooks.Read(ctx, hookId)
+	var secret string
+	if err == nil {
+		secret, err = sa.secrets.Get(ctx, h.GroupId, h.UserId)
+	}
+	if err == nil && !hmac.Equal([]byte(sig), []byte(Sign(secret, ts, body))) {
+		err = errSignature
+	}
+	if err == nil {
+		groupId = h.GroupId
+		userId = h.UserId
+	}
+	return
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignatureAuth_Authorize(t *testing.T) {
+	sa := NewSignatureValidator(
+		storage.NewHooksMock(),
+		storage.NewSecretsMock(),
+		storage.NewReplaysMock(),
+		config.HookSignatureConfig{
+			Tolerance:   5 * time.Minute,
+			BodySizeMax: 1024,
+		},
+		func(ctx *gin.Context) {
+			ctx.String(http.StatusUnauthorized, "fallback")
+			ctx.Abort()
+		},
+	)
+	now := time.Now().Unix()
+	body := `{"action":"push"}`
+	cases := map[string]struct {
+		hookId  string
+		sig     string
+		ts      int64
+		body    string
+		status  int
+		out     string
+		groupId string
+	}{
+		"no signature": {
+			hookId: "hook0",
+			status: http.StatusUnauthorized,
+			out:    "fallback",
+		},
+		"ok": {
+			hookId:  "hook0",
+			sig:     Sign("secret0", now, []byte(body)),
+			ts:      now,
+			status:  http.StatusOK,
+			groupId: "group0",
+		},
+		"replayed": {
+			hookId: "hook0",
+			sig:    Sign("secret0", now, []byte(body)),
+			ts:     now,
+			status: http.StatusUnauthorized,
+			out:    "replayed request",
+		},
+		"wrong secret": {
+			hookId: "hook0",
+			sig:    Sign("secret1", now, []byte(body)),
+			ts:     now,
+			status: http.StatusUnauthorized,
+			out:    "invalid signature",
+		},
+		"tampered body": {
+			hookId: "hook0",
+			sig:    Sign("secret0", now-1, []byte(body)),
+			ts:     now - 1,
+			body:   `{"action":"delete"}`,
+			status: http.StatusUnauthorized,
+			out:    "invalid signature",
+		},
+		"stale": {
+			hookId: "hook0",
+			sig:    Sign("secret0", now-3600, []byte(body)),
+			ts:     now - 3600,
+			status: http.StatusUnauthorized,
+		},
+		"unsupported scheme": {
+			hookId: "hook0",
+			sig:    "sha1=abc",
+			ts:     now,
+			status: http.StatusUnauthorized,
+		},
+		"missing hook": {
+			hookId: "missing",
+			sig:    Sign("secret0", now, []byte(body)),
+			ts:     now,
+			status: http.StatusUnauthorized,
+			out:    "invalid signature",
+		},
+		"storage failure": {
+			hookId: "fail",
+			sig:    Sign("secret0", now, []byte(body)),
+			ts:     now,
+			status: http.StatusInternalServerError,
+		},
+	}
+	// the replay case depends on the ok case
+	for _, k := range []string{"no signature", "ok", "replayed", "wrong secret", "tampered body", "stale", "unsupported scheme", "missing hook", "storage failure"} {
+		c := cases[k]
+		t.Run(k, func(t *testing.T) {
+			in := c.body
+			if in == "" {
+				in = body
+			}
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/hook/"+c.hookId, strings.NewReader(in))
+			ctx.Params = gin.Params{
+				{
+					Key:   "id",
+					Value: c.hookId,
+				},
+			}
+			if c.sig != "" {
+				ctx.Request.Header.Set(KeySignature, c.sig)
+				ctx.Request.Header.Set(KeyTimestamp, strconv.FormatInt(c.ts, 10))
+			}
+			sa.Authorize(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.out != "" {
+				assert.Equal(t, c.out, w.Body.String())
+			}
+			if c.status == http.StatusOK {
+				assert.False(t, ctx.IsAborted())
+				assert.Equal(t, c.groupId, ctx.GetString(model.KeyGroupId))
+				assert.Equal(t, "user0", ctx.GetString(model.KeyUserId))
+				restored, _ := io.ReadAll(ctx.Request.Body)
+				assert.Equal(t, in, string(restored))
+			}
+		})
+	}
+}
+
+func TestSignatureAuth_AuthorizeDisabled(t *testing.T) {
+	secrets, err := storage.NewSecrets(context.TODO(), config.DbConfig{})
+	require.Nil(t, err)
+	sa := NewSignatureValidator(
+		storage.NewHooksMock(),
+		secrets,
+		storage.NewReplaysMock(),
+		config.HookSignatureConfig{
+			Tolerance:   5 * time.Minute,
+			BodySizeMax: 1024,
+		},
+		func(ctx *gin.Context) {
+			ctx.String(http.StatusUnauthorized, "fallback")
+			ctx.Abort()
+		},
+	)
+	now := time.Now().Unix()
+	body := `{"action":"push"}`
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/hook/hook0", strings.NewReader(body))
+	ctx.Params = gin.Params{
+		{
+			Key:   "id",
+			Value: "hook0",
+		},
+	}
+	ctx.Request.Header.Set(KeySignature, Sign("secret0", now, []byte(body)))
+	ctx.Request.Header.Set(KeyTimestamp, strconv.FormatInt(now, 10))
+	sa.Authorize(ctx)
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.True(t, ctx.IsAborted())
+}
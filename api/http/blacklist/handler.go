@@ -0,0 +1,186 @@
+package blacklist
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/api/http/auth"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Handler manages the blacklist rules. The changes are stored and applied to the in-memory blacklist at once.
+// The rules are global unless the group id is specified. The group admin manages only the own group rules, the group
+// id defaults to the admin's group then.
+type Handler interface {
+
+	// Create adds the rule of the optional kind (prefix by default) with the optional reason.
+	Create(ctx *gin.Context)
+
+	// Read returns the entry by the prefix and the optional group id query params.
+	Read(ctx *gin.Context)
+
+	// Delete removes the entry by the prefix and the optional group id query params.
+	Delete(ctx *gin.Context)
+
+	// List returns the page of the global or the group entries ordered by prefix, starting after the cursor query param.
+	List(ctx *gin.Context)
+}
+
+type handler struct {
+	stor       storage.Blacklist
+	blacklists model.BlacklistScopes
+}
+
+const keyQueryPrefix = "prefix"
+const keyQueryGroupId = "groupId"
+
+const pageLimitDefault = 100
+
+func NewHandler(stor storage.Blacklist, blacklists model.BlacklistScopes) Handler {
+	return handler{
+		stor:       stor,
+		blacklists: blacklists,
+	}
+}
+
+var errForeignGroup = errors.New("group admin may manage only the own group blacklist")
+
+// scope returns the group id of the rules to manage, forcing the own group for the group admin.
+func scope(ctx *gin.Context, groupId string) (dst string, err error) {
+	dst = groupId
+	if own := ctx.GetString(auth.KeyAdminGroupId); own != "" {
+		switch groupId {
+		case "", own:
+			dst = own
+		default:
+			err = fmt.Errorf("%w: %s", errForeignGroup, groupId)
+		}
+	}
+	return
+}
+
+func (h handler) Create(ctx *gin.Context) {
+	defer ctx.Request.Body.Close()
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	var p createPayload
+	err = sonic.Unmarshal(body, &p)
+	now := time.Now().UTC()
+	if err == nil {
+		err = p.validate(now)
+	}
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	e := model.BlacklistEntry{
+		Prefix: p.Prefix,
+		Value:  p.value(now),
+	}
+	e.GroupId, err = scope(ctx, p.GroupId)
+	if err != nil {
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+	err = h.stor.Put(ctx, e)
+	if err == nil {
+		err = h.blacklists.Get(e.GroupId).Put(ctx, e.Prefix, e.Value)
+	}
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusCreated, newEntryPayload(e))
+	case errors.Is(err, storage.ErrConflict):
+		ctx.String(http.StatusConflict, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h handler) Read(ctx *gin.Context) {
+	prefix := ctx.Query(keyQueryPrefix)
+	if prefix == "" {
+		ctx.String(http.StatusBadRequest, "missing prefix query param")
+		return
+	}
+	groupId, err := scope(ctx, ctx.Query(keyQueryGroupId))
+	if err != nil {
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+	var v model.BlacklistValue
+	v, err = h.stor.Get(ctx, groupId, prefix)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, newEntryPayload(model.BlacklistEntry{
+			GroupId: groupId,
+			Prefix:  prefix,
+			Value:   v,
+		}))
+	case errors.Is(err, storage.ErrNotFound):
+		ctx.String(http.StatusNotFound, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h handler) Delete(ctx *gin.Context) {
+	prefix := ctx.Query(keyQueryPrefix)
+	if prefix == "" {
+		ctx.String(http.StatusBadRequest, "missing prefix query param")
+		return
+	}
+	groupId, err := scope(ctx, ctx.Query(keyQueryGroupId))
+	if err != nil {
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+	err = h.stor.Delete(ctx, groupId, prefix)
+	if b, found := h.blacklists.Find(groupId); err == nil && found {
+		err = b.Delete(ctx, prefix)
+	}
+	switch {
+	case err == nil:
+		ctx.Status(http.StatusOK)
+	case errors.Is(err, storage.ErrNotFound):
+		ctx.String(http.StatusNotFound, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h handler) List(ctx *gin.Context) {
+	limitStr := ctx.DefaultQuery("limit", strconv.Itoa(pageLimitDefault))
+	limit, err := strconv.ParseUint(limitStr, 10, 32)
+	if err != nil || limit == 0 {
+		ctx.String(http.StatusBadRequest, fmt.Sprintf("invalid limit query param: %s", limitStr))
+		return
+	}
+	var groupId string
+	groupId, err = scope(ctx, ctx.Query(keyQueryGroupId))
+	if err != nil {
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+	cursor := ctx.DefaultQuery("cursor", "")
+	var page []model.BlacklistEntry
+	page, err = h.stor.GetPage(ctx, groupId, uint32(limit), cursor)
+	switch err {
+	case nil:
+		entries := make([]entryPayload, 0, len(page))
+		for _, e := range page {
+			entries = append(entries, newEntryPayload(e))
+		}
+		ctx.JSON(http.StatusOK, entries)
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
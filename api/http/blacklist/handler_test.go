@@ -0,0 +1,312 @@
+package blacklist
+
+import (
+	"context"
+	"github.com/awakari/pub/api/http/auth"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Create(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	h := NewHandler(storage.NewBlacklistMock(), blacklists)
+	cases := map[string]struct {
+		adminGroupId string
+		in           string
+		status       int
+		groupId      string
+		prefix       string
+		blocked      string
+		content      string
+	}{
+		"ok": {
+			in:      `{"prefix":"source:https://spam.example","reason":"spam"}`,
+			status:  http.StatusCreated,
+			prefix:  "source:https://spam.example",
+			blocked: "https://spam.example/feed",
+		},
+		"missing prefix": {
+			in:     `{"reason":"spam"}`,
+			status: http.StatusBadRequest,
+		},
+		"host": {
+			in:      `{"prefix":"source:ads.example","kind":"host"}`,
+			status:  http.StatusCreated,
+			prefix:  "source:ads.example",
+			blocked: "http://www.cdn.ads.example/feed",
+		},
+		"keyword": {
+			in:      `{"prefix":"Casino","kind":"keyword","wholeWord":true,"matchCase":true}`,
+			status:  http.StatusCreated,
+			prefix:  "Casino",
+			content: "Best Casino bonus",
+		},
+		"keyword options for non-keyword kind": {
+			in:     `{"prefix":"source:https://other.example","wholeWord":true}`,
+			status: http.StatusBadRequest,
+		},
+		"expiring": {
+			in:      `{"prefix":"source:https://cooldown.example","expiresAt":"2099-01-01T00:00:00Z"}`,
+			status:  http.StatusCreated,
+			prefix:  "source:https://cooldown.example",
+			blocked: "https://cooldown.example/feed",
+		},
+		"expired": {
+			in:     `{"prefix":"source:https://expired.example","expiresAt":"2000-01-01T00:00:00Z"}`,
+			status: http.StatusBadRequest,
+		},
+		"expires before start": {
+			in:     `{"prefix":"source:https://scheduled.example","notBefore":"2099-01-02T00:00:00Z","expiresAt":"2099-01-01T00:00:00Z"}`,
+			status: http.StatusBadRequest,
+		},
+		"quarantine": {
+			in:      `{"prefix":"source:https://review.example","action":"quarantine"}`,
+			status:  http.StatusCreated,
+			prefix:  "source:https://review.example",
+			blocked: "https://review.example/feed",
+		},
+		"allow": {
+			in:     `{"prefix":"source:https://t.me/goodchannel","allow":true}`,
+			status: http.StatusCreated,
+		},
+		"allow glob": {
+			in:     `{"prefix":"source:*good*","kind":"glob","allow":true}`,
+			status: http.StatusBadRequest,
+		},
+		"throttle without rate limit": {
+			in:     `{"prefix":"source:https://noisy.example","action":"throttle"}`,
+			status: http.StatusBadRequest,
+		},
+		"group": {
+			in:      `{"prefix":"source:https://offtopic.example","groupId":"group0"}`,
+			status:  http.StatusCreated,
+			groupId: "group0",
+			prefix:  "source:https://offtopic.example",
+			blocked: "https://offtopic.example/feed",
+		},
+		"group admin": {
+			adminGroupId: "group1",
+			in:           `{"prefix":"source:https://boring.example"}`,
+			status:       http.StatusCreated,
+			groupId:      "group1",
+			prefix:       "source:https://boring.example",
+			blocked:      "https://boring.example/feed",
+		},
+		"group admin of other group": {
+			adminGroupId: "group1",
+			in:           `{"prefix":"source:https://boring.example","groupId":"group0"}`,
+			status:       http.StatusForbidden,
+		},
+		"invalid regex": {
+			in:     `{"prefix":"title:(spam","kind":"regex"}`,
+			status: http.StatusBadRequest,
+		},
+		"invalid payload": {
+			in:     `{"prefix":`,
+			status: http.StatusBadRequest,
+		},
+		"conflict": {
+			in:     `{"prefix":"conflict"}`,
+			status: http.StatusConflict,
+		},
+		"storage failure": {
+			in:     `{"prefix":"fail"}`,
+			status: http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/admin/blacklist", strings.NewReader(c.in))
+			if c.adminGroupId != "" {
+				ctx.Set(auth.KeyAdminGroupId, c.adminGroupId)
+			}
+			h.Create(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.blocked != "" {
+				rule, _, _ := blacklists.Get(c.groupId).Match(context.TODO(), "source", c.blocked, true)
+				assert.Equal(t, c.prefix, rule)
+				if c.groupId != "" {
+					_, _, found := blacklist.Match(context.TODO(), "source", c.blocked, true)
+					assert.False(t, found)
+				}
+			}
+			if c.content != "" {
+				rule, v, _ := blacklist.MatchContent(context.TODO(), c.content)
+				assert.Equal(t, c.prefix, rule)
+				assert.True(t, v.WholeWord)
+				assert.True(t, v.MatchCase)
+			}
+		})
+	}
+	_, _, found := blacklist.Match(context.TODO(), "conflict", "", false)
+	assert.False(t, found)
+}
+
+func TestHandler_Read(t *testing.T) {
+	h := NewHandler(storage.NewBlacklistMock(), model.NewBlacklistScopes())
+	cases := map[string]struct {
+		adminGroupId string
+		groupId      string
+		prefix       string
+		status       int
+		out          string
+	}{
+		"ok": {
+			prefix: "source:https://spam.example",
+			status: http.StatusOK,
+			out:    `{"prefix":"source:https://spam.example","createdAt":"2024-12-19T17:52:59Z","reason":"spam"}`,
+		},
+		"group admin": {
+			adminGroupId: "group0",
+			prefix:       "source:https://spam.example",
+			status:       http.StatusOK,
+			out:          `{"groupId":"group0","prefix":"source:https://spam.example","createdAt":"2024-12-19T17:52:59Z","reason":"spam"}`,
+		},
+		"group admin of other group": {
+			adminGroupId: "group0",
+			groupId:      "group1",
+			prefix:       "source:https://spam.example",
+			status:       http.StatusForbidden,
+		},
+		"missing prefix": {
+			status: http.StatusBadRequest,
+		},
+		"not found": {
+			prefix: "missing",
+			status: http.StatusNotFound,
+		},
+		"storage failure": {
+			prefix: "fail",
+			status: http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/admin/blacklist/entry?groupId="+c.groupId+"&prefix="+url.QueryEscape(c.prefix), nil)
+			if c.adminGroupId != "" {
+				ctx.Set(auth.KeyAdminGroupId, c.adminGroupId)
+			}
+			h.Read(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.out != "" {
+				assert.JSONEq(t, c.out, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Delete(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	_ = blacklist.Put(context.TODO(), "source:https://spam.example", model.BlacklistValue{})
+	_ = blacklist.Put(context.TODO(), "missing", model.BlacklistValue{})
+	_ = blacklists.Get("group0").Put(context.TODO(), "source:https://offtopic.example", model.BlacklistValue{})
+	h := NewHandler(storage.NewBlacklistMock(), blacklists)
+	cases := map[string]struct {
+		groupId string
+		prefix  string
+		status  int
+		blocked bool
+	}{
+		"ok": {
+			prefix: "source:https://spam.example",
+			status: http.StatusOK,
+		},
+		"group": {
+			groupId: "group0",
+			prefix:  "source:https://offtopic.example",
+			status:  http.StatusOK,
+		},
+		"missing prefix": {
+			status: http.StatusBadRequest,
+		},
+		"not found": {
+			prefix:  "missing",
+			status:  http.StatusNotFound,
+			blocked: true,
+		},
+		"storage failure": {
+			prefix: "fail",
+			status: http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodDelete, "/v1/admin/blacklist?groupId="+c.groupId+"&prefix="+url.QueryEscape(c.prefix), nil)
+			h.Delete(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.prefix != "" {
+				attrName, attrValue, _ := strings.Cut(c.prefix, ":")
+				_, _, found := blacklists.Get(c.groupId).Match(context.TODO(), attrName, attrValue, false)
+				assert.Equal(t, c.blocked, found)
+			}
+		})
+	}
+}
+
+func TestHandler_List(t *testing.T) {
+	h := NewHandler(storage.NewBlacklistMock(), model.NewBlacklistScopes())
+	cases := map[string]struct {
+		adminGroupId string
+		query        string
+		status       int
+		out          string
+	}{
+		"ok": {
+			status: http.StatusOK,
+			out:    `[{"prefix":"source:https://spam.example","createdAt":"2024-12-19T17:52:59Z","reason":"spam"}]`,
+		},
+		"group": {
+			query:  "groupId=group0",
+			status: http.StatusOK,
+			out:    `[{"groupId":"group0","prefix":"source:https://group.example","createdAt":"2024-12-19T17:52:59Z","reason":"off-topic"}]`,
+		},
+		"group admin": {
+			adminGroupId: "group0",
+			status:       http.StatusOK,
+			out:          `[{"groupId":"group0","prefix":"source:https://group.example","createdAt":"2024-12-19T17:52:59Z","reason":"off-topic"}]`,
+		},
+		"group admin of other group": {
+			adminGroupId: "group1",
+			query:        "groupId=group0",
+			status:       http.StatusForbidden,
+		},
+		"invalid limit": {
+			query:  "limit=0",
+			status: http.StatusBadRequest,
+		},
+		"storage failure": {
+			query:  "cursor=fail",
+			status: http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/admin/blacklist?"+c.query, nil)
+			if c.adminGroupId != "" {
+				ctx.Set(auth.KeyAdminGroupId, c.adminGroupId)
+			}
+			h.List(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.out != "" {
+				assert.JSONEq(t, c.out, w.Body.String())
+			}
+		})
+	}
+}
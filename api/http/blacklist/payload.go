@@ -0,0 +1,104 @@
+package blacklist
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/model"
+	"time"
+)
+
+type createPayload struct {
+	// GroupId scopes the rule to the group, the rule is global when empty.
+	GroupId string              `json:"groupId,omitempty"`
+	Prefix  string              `json:"prefix"`
+	Kind    model.BlacklistKind `json:"kind,omitempty"`
+	Reason  string              `json:"reason,omitempty"`
+	// WholeWord and MatchCase are the keyword rule options.
+	WholeWord bool `json:"wholeWord,omitempty"`
+	MatchCase bool `json:"matchCase,omitempty"`
+	// NotBefore and ExpiresAt are optional, the rule applies immediately and permanently by default.
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Action is "reject" by default, the throttle action requires the rate limit per minute. The rate limit applies
+	// per replica.
+	Action    model.BlacklistAction `json:"action,omitempty"`
+	RateLimit uint32                `json:"rateLimit,omitempty"`
+	// Allow makes the allowlist rule overriding the less specific blacklist rules.
+	Allow bool `json:"allow,omitempty"`
+}
+
+type entryPayload struct {
+	GroupId   string                `json:"groupId,omitempty"`
+	Prefix    string                `json:"prefix"`
+	Kind      model.BlacklistKind   `json:"kind,omitempty"`
+	CreatedAt time.Time             `json:"createdAt"`
+	Reason    string                `json:"reason,omitempty"`
+	WholeWord bool                  `json:"wholeWord,omitempty"`
+	MatchCase bool                  `json:"matchCase,omitempty"`
+	NotBefore *time.Time            `json:"notBefore,omitempty"`
+	ExpiresAt *time.Time            `json:"expiresAt,omitempty"`
+	Action    model.BlacklistAction `json:"action,omitempty"`
+	RateLimit uint32                `json:"rateLimit,omitempty"`
+	Allow     bool                  `json:"allow,omitempty"`
+}
+
+func newEntryPayload(e model.BlacklistEntry) entryPayload {
+	v := e.Value
+	return entryPayload{
+		GroupId:   e.GroupId,
+		Prefix:    e.Prefix,
+		Kind:      v.Kind,
+		CreatedAt: v.CreatedAt,
+		Reason:    v.Reason,
+		WholeWord: v.WholeWord,
+		MatchCase: v.MatchCase,
+		NotBefore: optionalTime(v.NotBefore),
+		ExpiresAt: optionalTime(v.ExpiresAt),
+		Action:    v.Action,
+		RateLimit: v.RateLimit,
+		Allow:     v.Allow,
+	}
+}
+
+func optionalTime(t time.Time) (dst *time.Time) {
+	if !t.IsZero() {
+		dst = &t
+	}
+	return
+}
+
+func (cp createPayload) value(now time.Time) (v model.BlacklistValue) {
+	v = model.BlacklistValue{
+		CreatedAt: now,
+		Reason:    cp.Reason,
+		Kind:      cp.Kind,
+		WholeWord: cp.WholeWord,
+		MatchCase: cp.MatchCase,
+		Action:    cp.Action,
+		RateLimit: cp.RateLimit,
+		Allow:     cp.Allow,
+	}
+	if cp.NotBefore != nil {
+		v.NotBefore = cp.NotBefore.UTC()
+	}
+	if cp.ExpiresAt != nil {
+		v.ExpiresAt = cp.ExpiresAt.UTC()
+	}
+	return
+}
+
+var errInvalidPayload = errors.New("invalid request payload")
+
+func (cp createPayload) validate(now time.Time) (err error) {
+	switch {
+	case cp.Prefix == "":
+		err = fmt.Errorf("%w: missing prefix", errInvalidPayload)
+	case (cp.WholeWord || cp.MatchCase) && cp.Kind != model.BlacklistKindKeyword:
+		err = fmt.Errorf("%w: wholeWord and matchCase are only for the keyword kind", errInvalidPayload)
+	case cp.ExpiresAt != nil && !cp.ExpiresAt.After(now):
+		err = fmt.Errorf("%w: expiresAt is in the past", errInvalidPayload)
+	default:
+		err = model.ValidateBlacklistValue(cp.Prefix, cp.value(now))
+	}
+	return
+}
@@ -0,0 +1,159 @@
+package hook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/api/http/grpc"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/segmentio/ksuid"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Handler interface {
+	Create(ctx *gin.Context)
+	Read(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+	List(ctx *gin.Context)
+
+	// PutSecret generates the new webhook signing secret for the caller, replacing the previous one, and returns it.
+	PutSecret(ctx *gin.Context)
+}
+
+type handler struct {
+	stor    storage.Hooks
+	secrets storage.Secrets
+}
+
+const pageLimitDefault = 100
+
+const secretLen = 32
+
+func NewHandler(stor storage.Hooks, secrets storage.Secrets) Handler {
+	return handler{
+		stor:    stor,
+		secrets: secrets,
+	}
+}
+
+func (h handler) Create(ctx *gin.Context) {
+	defer ctx.Request.Body.Close()
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	var m model.HookMapping
+	err = sonic.Unmarshal(body, &m)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	err = m.Validate()
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	_, groupId, userId := grpc.AuthRequestContext(ctx)
+	hook := model.Hook{
+		Id:        ksuid.New().String(),
+		GroupId:   groupId,
+		UserId:    userId,
+		CreatedAt: time.Now().UTC(),
+		Mapping:   m,
+	}
+	err = h.stor.Create(ctx, hook)
+	switch {
+	case err == nil:
+		ctx.String(http.StatusCreated, hook.Id)
+	case errors.Is(err, storage.ErrConflict):
+		ctx.String(http.StatusConflict, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h handler) Read(ctx *gin.Context) {
+	_, groupId, userId := grpc.AuthRequestContext(ctx)
+	hook, err := ReadOwn(ctx, h.stor, ctx.Param("id"), groupId, userId)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, hookPayload{
+			Id:        hook.Id,
+			CreatedAt: hook.CreatedAt,
+			Mapping:   hook.Mapping,
+		})
+	case errors.Is(err, storage.ErrNotFound):
+		ctx.String(http.StatusNotFound, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h handler) Delete(ctx *gin.Context) {
+	_, groupId, userId := grpc.AuthRequestContext(ctx)
+	err := h.stor.Delete(ctx, ctx.Param("id"), groupId, userId)
+	switch {
+	case err == nil:
+		ctx.String(http.StatusOK, "")
+	case errors.Is(err, storage.ErrNotFound):
+		ctx.String(http.StatusNotFound, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h handler) List(ctx *gin.Context) {
+	_, groupId, userId := grpc.AuthRequestContext(ctx)
+	limitStr := ctx.DefaultQuery("limit", strconv.Itoa(pageLimitDefault))
+	limit, err := strconv.ParseUint(limitStr, 10, 32)
+	if err != nil || limit == 0 {
+		ctx.String(http.StatusBadRequest, fmt.Sprintf("invalid limit query param: %s", limitStr))
+		return
+	}
+	cursor := ctx.DefaultQuery("cursor", "")
+	var ids []string
+	ids, err = h.stor.List(ctx, groupId, userId, uint32(limit), cursor)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, ids)
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h handler) PutSecret(ctx *gin.Context) {
+	_, groupId, userId := grpc.AuthRequestContext(ctx)
+	raw := make([]byte, secretLen)
+	_, err := rand.Read(raw)
+	secret := hex.EncodeToString(raw)
+	if err == nil {
+		err = h.secrets.Put(ctx, groupId, userId, secret)
+	}
+	switch {
+	case err == nil:
+		ctx.String(http.StatusOK, secret)
+	case errors.Is(err, storage.ErrSecretsDisabled):
+		ctx.String(http.StatusNotImplemented, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+// ReadOwn returns the hook only when it's owned by the specified group and user, storage.ErrNotFound otherwise.
+func ReadOwn(ctx context.Context, stor storage.Hooks, id, groupId, userId string) (h model.Hook, err error) {
+	h, err = stor.Read(ctx, id)
+	if err == nil && (h.GroupId != groupId || h.UserId != userId) {
+		h = model.Hook{}
+		err = fmt.Errorf("%w: hook %s", storage.ErrNotFound, id)
+	}
+	return
+}
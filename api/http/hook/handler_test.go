@@ -0,0 +1,236 @@
+package hook
+
+import (
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Create(t *testing.T) {
+	h := NewHandler(storage.NewHooksMock(), storage.NewSecretsMock())
+	cases := map[string]struct {
+		in     string
+		status int
+	}{
+		"ok": {
+			in:     `{"source":"$.repository.html_url","type":"com_github_{$.action}","textData":"$.head_commit.message","attributes":{"author":"$.sender.login"}}`,
+			status: http.StatusCreated,
+		},
+		"missing source": {
+			in:     `{"type":"com_github_push"}`,
+			status: http.StatusBadRequest,
+		},
+		"invalid path": {
+			in:     `{"source":"$.commits[0","type":"com_github_push"}`,
+			status: http.StatusBadRequest,
+		},
+		"invalid json": {
+			in:     `{"source":`,
+			status: http.StatusBadRequest,
+		},
+		"conflict": {
+			in:     `{"source":"conflict","type":"com_github_push"}`,
+			status: http.StatusConflict,
+		},
+		"storage failure": {
+			in:     `{"source":"fail","type":"com_github_push"}`,
+			status: http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/hook", strings.NewReader(c.in))
+			ctx.Set(model.KeyGroupId, "group0")
+			ctx.Set(model.KeyUserId, "user0")
+			h.Create(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.status == http.StatusCreated {
+				assert.NotEmpty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Read(t *testing.T) {
+	h := NewHandler(storage.NewHooksMock(), storage.NewSecretsMock())
+	cases := map[string]struct {
+		id      string
+		groupId string
+		userId  string
+		status  int
+		out     string
+	}{
+		"ok": {
+			id:      "hook0",
+			groupId: "group0",
+			userId:  "user0",
+			status:  http.StatusOK,
+			out: `{
+				"id": "hook0",
+				"createdAt": "2024-12-19T17:52:59Z",
+				"mapping": {
+					"id": "$.head_commit.id",
+					"source": "$.repository.html_url",
+					"type": "com_github_{$.action}",
+					"textData": "$.head_commit.message",
+					"attributes": {
+						"author": "$.head_commit.author.name"
+					}
+				}
+			}`,
+		},
+		"missing": {
+			id:      "missing",
+			groupId: "group0",
+			userId:  "user0",
+			status:  http.StatusNotFound,
+		},
+		"other user": {
+			id:      "hook0",
+			groupId: "group0",
+			userId:  "user1",
+			status:  http.StatusNotFound,
+		},
+		"other group": {
+			id:      "hook0",
+			groupId: "group1",
+			userId:  "user0",
+			status:  http.StatusNotFound,
+		},
+		"storage failure": {
+			id:      "fail",
+			groupId: "group0",
+			userId:  "user0",
+			status:  http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/hook/"+c.id, nil)
+			ctx.Params = gin.Params{{Key: "id", Value: c.id}}
+			ctx.Set(model.KeyGroupId, c.groupId)
+			ctx.Set(model.KeyUserId, c.userId)
+			h.Read(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.out != "" {
+				assert.JSONEq(t, c.out, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Delete(t *testing.T) {
+	h := NewHandler(storage.NewHooksMock(), storage.NewSecretsMock())
+	cases := map[string]struct {
+		id     string
+		status int
+	}{
+		"ok": {
+			id:     "hook0",
+			status: http.StatusOK,
+		},
+		"missing": {
+			id:     "missing",
+			status: http.StatusNotFound,
+		},
+		"storage failure": {
+			id:     "fail",
+			status: http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodDelete, "/v1/hook/"+c.id, nil)
+			ctx.Params = gin.Params{{Key: "id", Value: c.id}}
+			ctx.Set(model.KeyGroupId, "group0")
+			ctx.Set(model.KeyUserId, "user0")
+			h.Delete(ctx)
+			assert.Equal(t, c.status, w.Code)
+		})
+	}
+}
+
+func TestHandler_List(t *testing.T) {
+	h := NewHandler(storage.NewHooksMock(), storage.NewSecretsMock())
+	cases := map[string]struct {
+		groupId string
+		query   string
+		status  int
+		out     string
+	}{
+		"ok": {
+			groupId: "group0",
+			status:  http.StatusOK,
+			out:     `["hook0","hook1"]`,
+		},
+		"invalid limit": {
+			groupId: "group0",
+			query:   "?limit=0",
+			status:  http.StatusBadRequest,
+		},
+		"storage failure": {
+			groupId: "fail",
+			status:  http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/hook"+c.query, nil)
+			ctx.Set(model.KeyGroupId, c.groupId)
+			ctx.Set(model.KeyUserId, "user0")
+			h.List(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.out != "" {
+				assert.JSONEq(t, c.out, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_PutSecret(t *testing.T) {
+	h := NewHandler(storage.NewHooksMock(), storage.NewSecretsMock())
+	cases := map[string]struct {
+		groupId string
+		status  int
+	}{
+		"ok": {
+			groupId: "group0",
+			status:  http.StatusOK,
+		},
+		"storage failure": {
+			groupId: "fail",
+			status:  http.StatusInternalServerError,
+		},
+		"disabled": {
+			groupId: "disabled",
+			status:  http.StatusNotImplemented,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPut, "/v1/hook/secret", nil)
+			ctx.Set(model.KeyGroupId, c.groupId)
+			ctx.Set(model.KeyUserId, "user0")
+			h.PutSecret(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.status == http.StatusOK {
+				assert.Len(t, w.Body.String(), 2*secretLen)
+			}
+		})
+	}
+}
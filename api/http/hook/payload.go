@@ -0,0 +1,12 @@
+package hook
+
+import (
+	"github.com/awakari/pub/model"
+	"time"
+)
+
+type hookPayload struct {
+	Id        string            `json:"id"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Mapping   model.HookMapping `json:"mapping"`
+}
@@ -0,0 +1,131 @@
+package pub
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const headerCePrefix = "ce-"
+const headerCeSpecVersion = headerCePrefix + ceAttrSpecVersion
+const headerContentType = "Content-Type"
+
+const ceAttrId = "id"
+const ceAttrSource = "source"
+const ceAttrSpecVersion = "specversion"
+const ceAttrType = "type"
+const ceAttrDataContentType = "datacontenttype"
+const ceAttrDataSchema = "dataschema"
+const ceAttrTime = "time"
+
+var ErrBinaryMode = errors.New("invalid binary content mode event")
+
+// IsBinaryMode returns true when the request carries the event in the CloudEvents HTTP binary content mode, i.e. the
+// event attributes are in the "ce-" prefixed headers and the body is the event data.
+func IsBinaryMode(header http.Header) bool {
+	return header.Get(headerCeSpecVersion) != ""
+}
+
+// UnmarshalBinary converts the CloudEvents HTTP binary content mode headers and body to the destination event.
+func UnmarshalBinary(header http.Header, body []byte, dst *pb.CloudEvent) (err error) {
+	dst.Attributes = make(map[string]*pb.CloudEventAttributeValue)
+	for k, vals := range header {
+		k = strings.ToLower(k)
+		if !strings.HasPrefix(k, headerCePrefix) || len(vals) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(k, headerCePrefix)
+		v, errDecode := url.PathUnescape(vals[0])
+		if errDecode != nil {
+			v = vals[0]
+		}
+		switch name {
+		case ceAttrId:
+			dst.Id = v
+		case ceAttrSource:
+			dst.Source = v
+		case ceAttrSpecVersion:
+			dst.SpecVersion = v
+		case ceAttrType:
+			dst.Type = v
+		case ceAttrTime:
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				err = fmt.Errorf("%w: header %s value %s: %s", ErrBinaryMode, k, v, err)
+				return
+			}
+			dst.Attributes[name] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeTimestamp{
+					CeTimestamp: timestamppb.New(t),
+				},
+			}
+		case ceAttrDataSchema:
+			dst.Attributes[name] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeUri{
+					CeUri: v,
+				},
+			}
+		default:
+			dst.Attributes[name] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeString{
+					CeString: v,
+				},
+			}
+		}
+	}
+	contentType := header.Get(headerContentType)
+	if contentType != "" {
+		dst.Attributes[ceAttrDataContentType] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: contentType,
+			},
+		}
+	}
+	switch {
+	case len(body) == 0:
+	case isTextContentType(contentType, body):
+		dst.Data = &pb.CloudEvent_TextData{
+			TextData: string(body),
+		}
+	default:
+		dst.Data = &pb.CloudEvent_BinaryData{
+			BinaryData: body,
+		}
+	}
+	switch {
+	case dst.Id == "":
+		err = fmt.Errorf("%w: missing header %s%s", ErrBinaryMode, headerCePrefix, ceAttrId)
+	case dst.Source == "":
+		err = fmt.Errorf("%w: missing header %s%s", ErrBinaryMode, headerCePrefix, ceAttrSource)
+	case dst.Type == "":
+		err = fmt.Errorf("%w: missing header %s%s", ErrBinaryMode, headerCePrefix, ceAttrType)
+	}
+	return
+}
+
+func isTextContentType(contentType string, data []byte) (text bool) {
+	if contentType == "" {
+		return utf8.Valid(data)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return utf8.Valid(data)
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		text = true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		text = true
+	case mediaType == "application/json", mediaType == "application/xml", mediaType == "application/javascript":
+		text = true
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package pub
+
+import (
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalBinary(t *testing.T) {
+	cases := map[string]struct {
+		header http.Header
+		body   []byte
+		check  func(t *testing.T, evt *pb.CloudEvent)
+		err    error
+	}{
+		"text": {
+			header: http.Header{
+				"Ce-Specversion": {"1.0"},
+				"Ce-Id":          {"2qRgPkuvdypNEnrA7HdsWpASaco"},
+				"Ce-Source":      {"https://awakari.com/pub-msg.html"},
+				"Ce-Type":        {"com_awakari_webapp"},
+				"Ce-Time":        {"2024-12-19T17:52:59Z"},
+				"Ce-Title":       {"hello%20world"},
+				"Content-Type":   {"text/plain; charset=utf-8"},
+			},
+			body: []byte("test"),
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, "2qRgPkuvdypNEnrA7HdsWpASaco", evt.Id)
+				assert.Equal(t, "1.0", evt.SpecVersion)
+				assert.Equal(t, "https://awakari.com/pub-msg.html", evt.Source)
+				assert.Equal(t, "com_awakari_webapp", evt.Type)
+				assert.Equal(t, "test", evt.GetTextData())
+				assert.Equal(t, "hello world", evt.Attributes["title"].GetCeString())
+				assert.Equal(t, "text/plain; charset=utf-8", evt.Attributes["datacontenttype"].GetCeString())
+				assert.Equal(t, time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC), evt.Attributes["time"].GetCeTimestamp().AsTime())
+			},
+		},
+		"binary": {
+			header: http.Header{
+				"Ce-Specversion": {"1.0"},
+				"Ce-Id":          {"1"},
+				"Ce-Source":      {"src1"},
+				"Ce-Type":        {"type1"},
+				"Content-Type":   {"image/png"},
+			},
+			body: []byte{0x89, 0x50, 0x4e, 0x47},
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, []byte{0x89, 0x50, 0x4e, 0x47}, evt.GetBinaryData())
+			},
+		},
+		"missing type": {
+			header: http.Header{
+				"Ce-Specversion": {"1.0"},
+				"Ce-Id":          {"1"},
+				"Ce-Source":      {"src1"},
+			},
+			err: ErrBinaryMode,
+		},
+		"invalid time": {
+			header: http.Header{
+				"Ce-Specversion": {"1.0"},
+				"Ce-Id":          {"1"},
+				"Ce-Source":      {"src1"},
+				"Ce-Type":        {"type1"},
+				"Ce-Time":        {"yesterday"},
+			},
+			err: ErrBinaryMode,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.True(t, IsBinaryMode(c.header))
+			var evt pb.CloudEvent
+			err := UnmarshalBinary(c.header, c.body, &evt)
+			assert.ErrorIs(t, err, c.err)
+			if c.check != nil {
+				c.check(t, &evt)
+			}
+		})
+	}
+}
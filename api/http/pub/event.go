@@ -2,11 +2,16 @@ package pub
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/bytedance/sonic"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"mime"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type event struct {
@@ -18,6 +23,17 @@ type event struct {
 	Attributes   map[string]attribute `json:"attributes"`
 	TextData1    *string              `json:"textData,omitempty"`
 	TextData2    *string              `json:"text_data,omitempty"`
+	BinaryData1  *string              `json:"binaryData,omitempty"`
+	BinaryData2  *string              `json:"binary_data,omitempty"`
+	ProtoData1   *protoData           `json:"protoData,omitempty"`
+	ProtoData2   *protoData           `json:"proto_data,omitempty"`
+}
+
+// protoData represents the google.protobuf.Any with the base64-encoded value.
+type protoData struct {
+	TypeUrl1 *string `json:"typeUrl,omitempty"`
+	TypeUrl2 *string `json:"type_url,omitempty"`
+	Value    string  `json:"value"`
 }
 
 type attribute struct {
@@ -37,6 +53,8 @@ type attribute struct {
 	CeUriRef2    *string    `json:"ce_uri_ref,omitempty"`
 }
 
+var ErrInvalidData = errors.New("invalid event data")
+
 type eventBatch struct {
 	Events []event `json:"events"`
 }
@@ -170,5 +188,99 @@ func convert(raw event, dst *pb.CloudEvent) (err error) {
 		dst.Attributes[name] = &dstAttr
 	}
 
+	if err == nil {
+		err = convertData(raw, dst)
+	}
+
+	return
+}
+
+func convertData(raw event, dst *pb.CloudEvent) (err error) {
+
+	var count int
+	if raw.TextData1 != nil || raw.TextData2 != nil {
+		count++
+	}
+
+	binData := raw.BinaryData1
+	if raw.BinaryData2 != nil {
+		binData = raw.BinaryData2
+	}
+	if binData != nil {
+		count++
+		var bytes []byte
+		bytes, err = base64.StdEncoding.DecodeString(*binData)
+		if err != nil {
+			err = fmt.Errorf("%w: event %s, binary data: %s", ErrInvalidData, raw.Id, err)
+			return
+		}
+		dst.Data = &pb.CloudEvent_BinaryData{
+			BinaryData: bytes,
+		}
+	}
+
+	pd := raw.ProtoData1
+	if raw.ProtoData2 != nil {
+		pd = raw.ProtoData2
+	}
+	if pd != nil {
+		count++
+		var typeUrl string
+		switch {
+		case pd.TypeUrl1 != nil:
+			typeUrl = *pd.TypeUrl1
+		case pd.TypeUrl2 != nil:
+			typeUrl = *pd.TypeUrl2
+		}
+		if typeUrl == "" {
+			err = fmt.Errorf("%w: event %s, proto data: missing type url", ErrInvalidData, raw.Id)
+			return
+		}
+		var bytes []byte
+		bytes, err = base64.StdEncoding.DecodeString(pd.Value)
+		if err != nil {
+			err = fmt.Errorf("%w: event %s, proto data: %s", ErrInvalidData, raw.Id, err)
+			return
+		}
+		dst.Data = &pb.CloudEvent_ProtoData{
+			ProtoData: &anypb.Any{
+				TypeUrl: typeUrl,
+				Value:   bytes,
+			},
+		}
+	}
+
+	if count > 1 {
+		err = fmt.Errorf("%w: event %s, only one of text, binary or proto data is allowed", ErrInvalidData, raw.Id)
+		return
+	}
+
+	var contentType string
+	if attr, ok := dst.Attributes[ceAttrDataContentType]; ok {
+		contentType = attr.GetCeString()
+	}
+	if contentType == "" {
+		return
+	}
+	mt, _, errMt := mime.ParseMediaType(contentType)
+	if errMt != nil {
+		// nothing to check the data against, the text data is accepted with the unparsable type too
+		return
+	}
+	switch d := dst.Data.(type) {
+	case *pb.CloudEvent_BinaryData:
+		if isTextContentType(contentType, nil) && !utf8.Valid(d.BinaryData) {
+			err = fmt.Errorf("%w: event %s, binary data is not a valid %s", ErrInvalidData, raw.Id, mt)
+		}
+	case *pb.CloudEvent_ProtoData:
+		if !isProtoMediaType(mt) {
+			err = fmt.Errorf("%w: event %s, proto data doesn't match the datacontenttype %s", ErrInvalidData, raw.Id, mt)
+		}
+	}
+
 	return
 }
+
+func isProtoMediaType(mt string) bool {
+	return mt == MimeProto || mt == "application/x-protobuf" || strings.HasSuffix(mt, "+protobuf")
+}
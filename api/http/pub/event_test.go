@@ -54,3 +54,158 @@ func TestEvent_Unmarshal(t *testing.T) {
 	assert.Equal(t, "v1", out.Attributes["string"].GetCeString())
 	assert.Equal(t, time.Date(2024, 12, 19, 17, 52, 59, 216_000_000, time.UTC), out.Attributes["time"].GetCeTimestamp().AsTime())
 }
+
+func TestEvent_UnmarshalData(t *testing.T) {
+	cases := map[string]struct {
+		in    string
+		check func(t *testing.T, evt *pb.CloudEvent)
+		err   error
+	}{
+		"binary data": {
+			in: `{
+  "id": "1",
+  "source": "src1",
+  "type": "type1",
+  "attributes": {
+    "datacontenttype": {
+      "ce_string": "image/png"
+    }
+  },
+  "binary_data": "iVBORw=="
+}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, []byte{0x89, 0x50, 0x4e, 0x47}, evt.GetBinaryData())
+			},
+		},
+		"binary data camel case": {
+			in: `{"id": "1", "source": "src1", "type": "type1", "binaryData": "TWFueSBoYW5kcyBtYWtlIGxpZ2h0IHdvcmsu"}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, []byte("Many hands make light work."), evt.GetBinaryData())
+			},
+		},
+		"binary data invalid base64": {
+			in:  `{"id": "1", "source": "src1", "type": "type1", "binary_data": "#"}`,
+			err: ErrInvalidData,
+		},
+		"binary data is not text": {
+			in: `{
+  "id": "1",
+  "source": "src1",
+  "type": "type1",
+  "attributes": {
+    "datacontenttype": {
+      "ce_string": "text/plain"
+    }
+  },
+  "binary_data": "iVBORw=="
+}`,
+			err: ErrInvalidData,
+		},
+		"proto data": {
+			in: `{
+  "id": "1",
+  "source": "src1",
+  "type": "type1",
+  "attributes": {
+    "datacontenttype": {
+      "ce_string": "application/protobuf"
+    }
+  },
+  "proto_data": {
+    "type_url": "type.googleapis.com/google.protobuf.StringValue",
+    "value": "CgR0ZXN0"
+  }
+}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, "type.googleapis.com/google.protobuf.StringValue", evt.GetProtoData().TypeUrl)
+				assert.Equal(t, []byte{0x0a, 0x04, 't', 'e', 's', 't'}, evt.GetProtoData().Value)
+			},
+		},
+		"proto data missing type": {
+			in:  `{"id": "1", "source": "src1", "type": "type1", "protoData": {"value": "CgR0ZXN0"}}`,
+			err: ErrInvalidData,
+		},
+		"proto data content type mismatch": {
+			in: `{
+  "id": "1",
+  "source": "src1",
+  "type": "type1",
+  "attributes": {
+    "datacontenttype": {
+      "ce_string": "application/json"
+    }
+  },
+  "protoData": {
+    "typeUrl": "type.googleapis.com/google.protobuf.StringValue",
+    "value": "CgR0ZXN0"
+  }
+}`,
+			err: ErrInvalidData,
+		},
+		"text data with unparsable content type": {
+			in: `{
+  "id": "1",
+  "source": "src1",
+  "type": "type1",
+  "attributes": {
+    "datacontenttype": {
+      "ce_string": "text/plain; charset"
+    }
+  },
+  "text_data": "test"
+}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, "test", evt.GetTextData())
+			},
+		},
+		"binary data with unparsable content type": {
+			in: `{
+  "id": "1",
+  "source": "src1",
+  "type": "type1",
+  "attributes": {
+    "datacontenttype": {
+      "ce_string": "garbage/"
+    }
+  },
+  "binary_data": "/w=="
+}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, []byte{0xff}, evt.GetBinaryData())
+			},
+		},
+		"proto data with unparsable content type": {
+			in: `{
+  "id": "1",
+  "source": "src1",
+  "type": "type1",
+  "attributes": {
+    "datacontenttype": {
+      "ce_string": "garbage/"
+    }
+  },
+  "protoData": {
+    "typeUrl": "type.googleapis.com/google.protobuf.StringValue",
+    "value": "CgR0ZXN0"
+  }
+}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, "type.googleapis.com/google.protobuf.StringValue", evt.GetProtoData().TypeUrl)
+			},
+		},
+		"text and binary data": {
+			in:  `{"id": "1", "source": "src1", "type": "type1", "text_data": "test", "binary_data": "dGVzdA=="}`,
+			err: ErrInvalidData,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var out pb.CloudEvent
+			err := Unmarshal([]byte(c.in), &out)
+			assert.ErrorIs(t, err, c.err)
+			if c.check != nil {
+				c.check(t, &out)
+			}
+		})
+	}
+}
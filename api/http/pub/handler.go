@@ -1,18 +1,19 @@
 package pub
 
 import (
-	"fmt"
+	"errors"
 	"github.com/awakari/pub/api/grpc/publisher"
 	"github.com/awakari/pub/api/http/grpc"
+	"github.com/awakari/pub/api/http/hook"
 	"github.com/awakari/pub/config"
 	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
 	"github.com/bytedance/sonic"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/ratelimit"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/timestamppb"
 	"io"
 	"log/slog"
 	"net/http"
@@ -23,27 +24,44 @@ type Handler interface {
 	Write(ctx *gin.Context)
 	WriteBatch(ctx *gin.Context)
 	WriteInternal(ctx *gin.Context)
+
+	// WriteStream reads the NDJSON request body line by line and publishes the events in bounded batches.
+	WriteStream(ctx *gin.Context)
+
+	// WriteWebSocket upgrades the connection to WebSocket, then publishes every received event frame and responds
+	// with the ack frame per event.
+	WriteWebSocket(ctx *gin.Context)
+
+	// WriteHook converts the arbitrary JSON request body to the event using the mapping of the hook owned by the
+	// caller, then publishes the event.
+	WriteHook(ctx *gin.Context)
 }
 
 type handler struct {
 	writer                  publisher.Service
 	writerInternalCfg       config.WriterInternalConfig
 	writerInternalRateLimit ratelimit.Limiter
-	blacklist               model.Prefixes[model.BlacklistValue]
+	ingest                  publisher.Ingest
+	hooks                   storage.Hooks
+	cfgHttp                 config.HttpConfig
 	log                     *slog.Logger
 }
 
 func NewHandler(
 	writer publisher.Service,
 	writerInternalCfg config.WriterInternalConfig,
-	blacklist model.Prefixes[model.BlacklistValue],
+	ingest publisher.Ingest,
+	hooks storage.Hooks,
+	cfgHttp config.HttpConfig,
 	log *slog.Logger,
 ) Handler {
 	return handler{
 		writer:                  writer,
 		writerInternalCfg:       writerInternalCfg,
 		writerInternalRateLimit: ratelimit.New(writerInternalCfg.RateLimitPerMinute, ratelimit.Per(time.Minute)),
-		blacklist:               blacklist,
+		ingest:                  ingest,
+		hooks:                   hooks,
+		cfgHttp:                 cfgHttp,
 		log:                     log,
 	}
 }
@@ -53,11 +71,22 @@ func (h handler) Write(ctx *gin.Context) {
 	body, err := io.ReadAll(ctx.Request.Body)
 	var evt pb.CloudEvent
 	if err == nil {
-		err = Unmarshal(body, &evt)
-
+		switch {
+		case IsBinaryMode(ctx.Request.Header):
+			err = UnmarshalBinary(ctx.Request.Header, body, &evt)
+		case IsProtoMode(ctx.Request.Header):
+			err = UnmarshalProto(body, &evt)
+		case IsStructuredMode(ctx.Request.Header):
+			err = UnmarshalStructured(body, &evt)
+		default:
+			err = Unmarshal(body, &evt)
+		}
 	}
-	if err == nil {
+	switch err {
+	case nil:
 		h.write(ctx, []*pb.CloudEvent{&evt}, false)
+	default:
+		ctx.String(http.StatusBadRequest, err.Error())
 	}
 }
 
@@ -66,10 +95,22 @@ func (h handler) WriteBatch(ctx *gin.Context) {
 	body, err := io.ReadAll(ctx.Request.Body)
 	var evts []*pb.CloudEvent
 	if err == nil {
-		evts, err = UnmarshalBatch(body)
+		switch {
+		case IsProtoMode(ctx.Request.Header) && IsProtoDelimited(ctx.Request.Header):
+			evts, err = UnmarshalProtoDelimited(body)
+		case IsProtoMode(ctx.Request.Header):
+			evts, err = UnmarshalProtoBatch(body)
+		case IsStructuredBatchMode(ctx.Request.Header), IsJsonArray(body):
+			evts, err = UnmarshalStructuredBatch(body)
+		default:
+			evts, err = UnmarshalBatch(body)
+		}
 	}
-	if err == nil {
+	switch err {
+	case nil:
 		h.write(ctx, evts, false)
+	default:
+		ctx.String(http.StatusBadRequest, err.Error())
 	}
 }
 
@@ -91,118 +132,128 @@ func (h handler) WriteInternal(ctx *gin.Context) {
 	}
 }
 
-func (h handler) write(ctx *gin.Context, evts []*pb.CloudEvent, internal bool) {
-
-	if !internal {
-
-		for i, evt := range evts {
-
-			var prefix string
-			var attrName string
-			var attrValue string
-			prefix, _, _ = h.blacklist.FindOnePrefix(ctx, "source:"+evt.Source)
-			switch prefix {
-			case "":
-				prefix, _, _ = h.blacklist.FindOnePrefix(ctx, "type:"+evt.Source)
-			default:
-				attrName = "source"
-				attrValue = evt.Source
-			}
-			switch prefix {
-			case "":
-				for k, v := range evt.Attributes {
-					switch vt := v.Attr.(type) {
-					case *pb.CloudEventAttributeValue_CeString:
-						attrValue = vt.CeString
-					case *pb.CloudEventAttributeValue_CeUri:
-						attrValue = vt.CeUri
-					case *pb.CloudEventAttributeValue_CeUriRef:
-						attrValue = vt.CeUriRef
-					}
-					if attrValue != "" {
-						prefix, _, _ = h.blacklist.FindOnePrefix(ctx, k+":"+attrValue)
-						if prefix != "" {
-							attrName = k
-							break
-						}
-					}
-				}
-			default:
-				attrName = "type"
-				attrValue = evt.Type
-			}
+func (h handler) WriteHook(ctx *gin.Context) {
+	defer ctx.Request.Body.Close()
+	_, groupId, userId := grpc.AuthRequestContext(ctx)
+	hk, err := hook.ReadOwn(ctx, h.hooks, ctx.Param("id"), groupId, userId)
+	switch {
+	case err == nil:
+	case errors.Is(err, storage.ErrNotFound):
+		ctx.String(http.StatusNotFound, err.Error())
+		return
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	var body []byte
+	body, err = io.ReadAll(ctx.Request.Body)
+	var evt *pb.CloudEvent
+	if err == nil {
+		evt, err = hk.Mapping.Apply(body)
+	}
+	switch err {
+	case nil:
+		h.write(ctx, []*pb.CloudEvent{evt}, false)
+	default:
+		ctx.String(http.StatusBadRequest, err.Error())
+	}
+}
 
-			if prefix != "" {
-				switch i {
-				case 0:
-					h.log.Info(fmt.Sprintf("event was rejected by blacklist prefix: %s, id: %s, attribute: %s=%s\n", prefix, evt.Id, attrName, attrValue))
-					ctx.String(http.StatusForbidden, fmt.Sprintf("forbidden by prefix: %s", prefix))
-					return
-				default:
-					evts = evts[:i] // truncate the batch
-				}
-			}
-		}
+func (h handler) write(ctx *gin.Context, evts []*pb.CloudEvent, internal bool) {
+	code, resp, msg := h.submit(ctx, evts, internal)
+	switch code {
+	case http.StatusOK:
+		raw, _ := sonic.Marshal(newResponse(resp))
+		ctx.Data(http.StatusOK, gin.MIMEJSON, raw)
+	default:
+		ctx.String(code, msg)
 	}
+}
+
+// submit publishes the events and returns the resulting HTTP status code, the response with the result per every
+// event and the error message.
+func (h handler) submit(ctx *gin.Context, evts []*pb.CloudEvent, internal bool) (code int, resp *publisher.SubmitMessagesResponse, msg string) {
 
 	grpcCtx, groupId, userId := grpc.AuthRequestContext(ctx)
-	for _, evt := range evts {
-		if evt.Attributes == nil {
-			evt.Attributes = make(map[string]*pb.CloudEventAttributeValue)
+	var b publisher.Batch
+	var err error
+	switch internal {
+	case true:
+		t := time.Now().UTC()
+		b.Events = evts
+		b.Results = make([]*publisher.Result, len(evts))
+		for i, evt := range evts {
+			model.SetPublisherAttributes(evt, groupId, userId, t)
+			b.Accepted = append(b.Accepted, i)
 		}
-		evt.Attributes[model.KeyCeGroupId] = &pb.CloudEventAttributeValue{
-			Attr: &pb.CloudEventAttributeValue_CeString{
-				CeString: groupId,
-			},
+	default:
+		b, err = h.ingest.Check(grpcCtx, groupId, userId, evts)
+		if err != nil {
+			code = http.StatusInternalServerError
+			msg = err.Error()
+			return
 		}
-		evt.Attributes[model.KeyCeUserId] = &pb.CloudEventAttributeValue{
-			Attr: &pb.CloudEventAttributeValue_CeString{
-				CeString: userId,
-			},
+		if reason, forbidden, refused := b.Refused(); refused {
+			code = http.StatusBadRequest
+			if forbidden {
+				code = http.StatusForbidden
+			}
+			msg = reason
+			return
 		}
-		evt.Attributes[model.KeyCePubTime] = &pb.CloudEventAttributeValue{
-			Attr: &pb.CloudEventAttributeValue_CeTimestamp{
-				CeTimestamp: timestamppb.New(time.Now().UTC()),
-			},
+		if len(b.Accepted) == 0 {
+			// nothing to publish as usual
+			code = http.StatusOK
+			resp = &publisher.SubmitMessagesResponse{
+				Results: b.Results,
+			}
+			return
 		}
 	}
+
 	req := publisher.SubmitMessagesRequest{
-		Msgs: evts,
+		Msgs: b.AcceptedEvents(),
 	}
-	var resp *publisher.SubmitMessagesResponse
-	var err error
 	if internal {
 		resp, err = h.writer.SubmitInternalEvents(grpcCtx, &req)
 	} else {
 		resp, err = h.writer.SubmitPermittedEvents(grpcCtx, &req, groupId, userId)
 	}
 
-	if err == nil && resp.AckCount == 0 {
-		ctx.String(http.StatusServiceUnavailable, "was unable to submit, retry later")
-		return
+	switch {
+	case err == nil && resp.AckCount == 0:
+		code = http.StatusServiceUnavailable
+		msg = "was unable to submit, retry later"
+	case err == nil:
+		code = http.StatusOK
+		resp.Results = b.Merge(resp, userId)
+	default:
+		code = httpStatus(err)
+		msg = err.Error()
 	}
+	return
+}
 
+func httpStatus(err error) (code int) {
 	switch status.Code(err) {
 	case codes.OK:
-		raw, _ := sonic.Marshal(response{
-			AckCount: resp.AckCount,
-		})
-		ctx.Data(http.StatusOK, gin.MIMEJSON, raw)
+		code = http.StatusOK
 	case codes.NotFound:
-		ctx.String(http.StatusNotFound, err.Error())
+		code = http.StatusNotFound
 	case codes.AlreadyExists:
-		ctx.String(http.StatusConflict, err.Error())
+		code = http.StatusConflict
 	case codes.Unauthenticated:
-		ctx.String(http.StatusUnauthorized, err.Error())
+		code = http.StatusUnauthorized
 	case codes.DeadlineExceeded:
-		ctx.String(http.StatusRequestTimeout, err.Error())
+		code = http.StatusRequestTimeout
 	case codes.InvalidArgument:
-		ctx.String(http.StatusBadRequest, err.Error())
+		code = http.StatusBadRequest
 	case codes.ResourceExhausted:
-		ctx.String(http.StatusTooManyRequests, err.Error())
+		code = http.StatusTooManyRequests
 	case codes.Unavailable:
-		ctx.String(http.StatusServiceUnavailable, err.Error())
+		code = http.StatusServiceUnavailable
 	default:
-		ctx.String(http.StatusInternalServerError, err.Error())
+		code = http.StatusInternalServerError
 	}
+	return
 }
@@ -0,0 +1,159 @@
+package pub
+
+import (
+	"context"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_WriteBatch(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	_ = blacklist.Put(context.TODO(), "source:https://spam.example", model.BlacklistValue{})
+	_ = blacklist.Put(context.TODO(), "type:spam", model.BlacklistValue{})
+	_ = blacklist.Put(context.TODO(), "author:spam", model.BlacklistValue{})
+	_ = blacklist.Put(context.TODO(), "source:https://review.example", model.BlacklistValue{Action: model.BlacklistActionQuarantine})
+	_ = blacklist.Put(context.TODO(), "source:https://flag.example", model.BlacklistValue{Action: model.BlacklistActionTag})
+	cases := map[string]struct {
+		groupId string
+		lenient bool
+		reject  bool
+		in      string
+		status  int
+		out     string
+	}{
+		"ok": {
+			in:     `[{"specversion":"1.0","id":"1","source":"src1","type":"type1"},{"specversion":"1.0","id":"2","source":"src2","type":"type2"}]`,
+			status: http.StatusOK,
+			out:    `{"ackCount":2,"results":[{"id":"1","status":"accepted"},{"id":"2","status":"accepted"}]}`,
+		},
+		"per event results": {
+			in: `[
+  {"specversion":"1.0","source":"src0","type":"type0"},
+  {"specversion":"1.0","id":"1","source":"src1","type":"type1"},
+  {"specversion":"1.0","id":"2","source":"src2","type":"com_awakari_webapp"},
+  {"specversion":"1.0","id":"3","source":"src3","type":"type3"},
+  {"specversion":"1.0","id":"4","source":"https://spam.example/feed","type":"type4"},
+  {"specversion":"1.0","id":"5","source":"src5","type":"type5"}
+]`,
+			status: http.StatusOK,
+			out: `{"ackCount":3,"results":[
+  {"id":"","status":"invalid","reason":"event doesn't conform CloudEvents 1.0: id: missing"},
+  {"id":"1","status":"accepted"},
+  {"id":"2","status":"invalid","reason":"event doesn't conform the schema com_awakari_webapp v1: /attributes: missing properties: 'title'"},
+  {"id":"3","status":"accepted"},
+  {"id":"4","status":"blacklisted","reason":"forbidden by prefix: source:https://spam.example"},
+  {"id":"5","status":"accepted"}
+]}`,
+		},
+		"over limit": {
+			groupId: "over_limit",
+			in:      `[{"specversion":"1.0","id":"1","source":"src1","type":"type1"},{"specversion":"1.0","id":"2","source":"src2","type":"type2"}]`,
+			status:  http.StatusOK,
+			out:     `{"ackCount":1,"results":[{"id":"1","status":"accepted"},{"id":"2","status":"over_limit","reason":"user id user0: usage limit reached"}]}`,
+		},
+		"partially acked": {
+			groupId: "partial",
+			in:      `[{"specversion":"1.0","id":"1","source":"src1","type":"type1"},{"specversion":"1.0","id":"2","source":"src2","type":"type2"}]`,
+			status:  http.StatusOK,
+			out:     `{"ackCount":1,"results":[{"id":"1","status":"accepted"},{"id":"2","status":"not_acked","reason":"was not acknowledged, retry later"}]}`,
+		},
+		"all invalid lists all violations": {
+			in:     `[{"specversion":"1.0","source":"src1","type":"type1"},{"specversion":"1.0","id":"3","type":"type3","Foo":"bar"}]`,
+			status: http.StatusBadRequest,
+			out: "event #0, id: : event doesn't conform CloudEvents 1.0: id: missing\n" +
+				"event #1, id: 3: event doesn't conform CloudEvents 1.0: source: missing; attribute \"Foo\": name should be lowercase alphanumeric, at most 20 characters",
+		},
+		"lenient fills id": {
+			lenient: true,
+			in:      `[{"specversion":"1.0","source":"src1","type":"type1"}]`,
+			status:  http.StatusOK,
+		},
+		"reserved attribute stripped": {
+			in:     `[{"specversion":"1.0","id":"1","source":"src1","type":"type1","awktouserid":"user1"}]`,
+			status: http.StatusOK,
+			out:    `{"ackCount":1,"results":[{"id":"1","status":"accepted"}]}`,
+		},
+		"reserved attribute rejected": {
+			reject: true,
+			in:     `[{"specversion":"1.0","id":"1","source":"src1","type":"type1","awktouserid":"user1","awkinternal":1}]`,
+			status: http.StatusForbidden,
+			out:    "event #0, id: 1: reserved attribute: awkinternal, awktouserid",
+		},
+		"validated before blacklist": {
+			in:     `[{"specversion":"1.0","source":"https://spam.example/feed","type":"type1"}]`,
+			status: http.StatusBadRequest,
+		},
+		"all blacklisted": {
+			in:     `[{"specversion":"1.0","id":"1","source":"https://spam.example/feed","type":"type1"},{"specversion":"1.0","id":"2","source":"src2","type":"spam"}]`,
+			status: http.StatusForbidden,
+			out:    "forbidden by prefix: source:https://spam.example",
+		},
+		"first blacklisted": {
+			in:     `[{"specversion":"1.0","id":"1","source":"src1","type":"type1","author":"spammer"},{"specversion":"1.0","id":"2","source":"src2","type":"type2"}]`,
+			status: http.StatusOK,
+			out:    `{"ackCount":1,"results":[{"id":"1","status":"blacklisted","reason":"forbidden by prefix: author:spam"},{"id":"2","status":"accepted"}]}`,
+		},
+		"quarantined": {
+			in:     `[{"specversion":"1.0","id":"1","source":"https://review.example/feed","type":"type1"},{"specversion":"1.0","id":"2","source":"https://flag.example/feed","type":"type2"}]`,
+			status: http.StatusOK,
+			out:    `{"ackCount":1,"results":[{"id":"1","status":"quarantined","reason":"quarantined for review, forbidden by prefix: source:https://review.example"},{"id":"2","status":"accepted"}]}`,
+		},
+		"all quarantined": {
+			in:     `[{"specversion":"1.0","id":"1","source":"https://review.example/feed","type":"type1"},{"specversion":"1.0","id":"2","source":"https://spam.example/feed","type":"type2"}]`,
+			status: http.StatusOK,
+			out:    `{"ackCount":0,"results":[{"id":"1","status":"quarantined","reason":"quarantined for review, forbidden by prefix: source:https://review.example"},{"id":"2","status":"blacklisted","reason":"forbidden by prefix: source:https://spam.example"}]}`,
+		},
+		"quarantine failure": {
+			in:     `[{"specversion":"1.0","id":"quarantine_fail","source":"https://review.example/feed","type":"type1"}]`,
+			status: http.StatusOK,
+			out:    `{"ackCount":0,"results":[{"id":"quarantine_fail","status":"not_acked","reason":"was not quarantined, retry later"}]}`,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			h := NewHandler(
+				publisher.NewServiceMock(),
+				config.WriterInternalConfig{RateLimitPerMinute: 1},
+				publisher.NewIngest(
+					publisher.NewServiceMock(),
+					model.NewReservedAttributes([]string{"awk*"}, c.reject),
+					model.NewBlacklistPolicy(blacklists, "awkflagged", slog.Default()),
+					storage.NewSchemasMock(),
+					c.lenient,
+					slog.Default(),
+				),
+				storage.NewHooksMock(),
+				config.HttpConfig{},
+				slog.Default(),
+			)
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/batch", strings.NewReader(c.in))
+			groupId := c.groupId
+			if groupId == "" {
+				groupId = "group0"
+			}
+			ctx.Set(model.KeyGroupId, groupId)
+			ctx.Set(model.KeyUserId, "user0")
+			h.WriteBatch(ctx)
+			assert.Equal(t, c.status, w.Code)
+			switch {
+			case c.out == "":
+			case c.status == http.StatusOK:
+				assert.JSONEq(t, c.out, w.Body.String())
+			default:
+				assert.Equal(t, c.out, w.Body.String())
+			}
+		})
+	}
+}
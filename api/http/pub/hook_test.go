@@ -0,0 +1,108 @@
+package pub
+
+import (
+	"context"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_WriteHook(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	_ = blacklist.Put(context.TODO(), "source:https://spam.example", model.BlacklistValue{})
+	h := NewHandler(
+		publisher.NewServiceMock(),
+		config.WriterInternalConfig{RateLimitPerMinute: 1},
+		publisher.NewIngest(
+			publisher.NewServiceMock(),
+			model.NewReservedAttributes([]string{"awk*"}, false),
+			model.NewBlacklistPolicy(blacklists, "awkflagged", slog.Default()),
+			storage.NewSchemasMock(),
+			false,
+			slog.Default(),
+		),
+		storage.NewHooksMock(),
+		config.HttpConfig{},
+		slog.Default(),
+	)
+	cases := map[string]struct {
+		id      string
+		groupId string
+		in      string
+		status  int
+		out     string
+	}{
+		"ok": {
+			id:      "hook0",
+			groupId: "group0",
+			in:      `{"action":"push","repository":{"html_url":"https://github.com/awakari/pub"},"head_commit":{"id":"abc","message":"fix","author":{"name":"john"}}}`,
+			status:  http.StatusOK,
+			out:     `{"ackCount":1,"results":[{"id":"abc","status":"accepted"}]}`,
+		},
+		"not owned": {
+			id:      "hook0",
+			groupId: "group1",
+			in:      `{}`,
+			status:  http.StatusNotFound,
+		},
+		"missing": {
+			id:      "missing",
+			groupId: "group0",
+			in:      `{}`,
+			status:  http.StatusNotFound,
+		},
+		"storage failure": {
+			id:      "fail",
+			groupId: "group0",
+			in:      `{}`,
+			status:  http.StatusInternalServerError,
+		},
+		"malformed payload": {
+			id:      "hook0",
+			groupId: "group0",
+			in:      `{"action":`,
+			status:  http.StatusBadRequest,
+		},
+		"no source in payload": {
+			id:      "hook0",
+			groupId: "group0",
+			in:      `{"action":"push"}`,
+			status:  http.StatusBadRequest,
+		},
+		"blacklisted": {
+			id:      "hook0",
+			groupId: "group0",
+			in:      `{"action":"push","repository":{"html_url":"https://spam.example/repo"}}`,
+			status:  http.StatusForbidden,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/hook/"+c.id, strings.NewReader(c.in))
+			ctx.Params = gin.Params{
+				{
+					Key:   "id",
+					Value: c.id,
+				},
+			}
+			ctx.Set(model.KeyGroupId, c.groupId)
+			ctx.Set(model.KeyUserId, "user0")
+			h.WriteHook(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.out != "" {
+				assert.JSONEq(t, c.out, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package pub
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const MimeProto = "application/protobuf"
+const MimeCeProto = "application/cloudevents+protobuf"
+const MimeCeBatchProto = "application/cloudevents-batch+protobuf"
+
+// mimeParamDelimited is the content type parameter that marks the batch body as the stream of length-delimited events.
+const mimeParamDelimited = "delimited"
+
+var ErrProto = errors.New("invalid protobuf event")
+
+// IsProtoMode returns true when the request body is the protobuf wire format.
+func IsProtoMode(header http.Header) bool {
+	switch mediaType(header) {
+	case MimeProto, MimeCeProto, MimeCeBatchProto:
+		return true
+	}
+	return false
+}
+
+// IsProtoDelimited returns true when the protobuf batch request body is a stream of length-delimited events rather
+// than a single publisher.SubmitMessagesRequest message.
+func IsProtoDelimited(header http.Header) bool {
+	t, params, _ := mime.ParseMediaType(header.Get(headerContentType))
+	return t == MimeCeBatchProto || params[mimeParamDelimited] == "true"
+}
+
+// UnmarshalProto decodes the single event in the protobuf wire format.
+func UnmarshalProto(src []byte, dst *pb.CloudEvent) (err error) {
+	err = proto.Unmarshal(src, dst)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrProto, err)
+	}
+	return
+}
+
+// UnmarshalProtoBatch decodes the publisher.SubmitMessagesRequest in the protobuf wire format.
+func UnmarshalProtoBatch(src []byte) (dstBatch []*pb.CloudEvent, err error) {
+	var req publisher.SubmitMessagesRequest
+	err = proto.Unmarshal(src, &req)
+	switch err {
+	case nil:
+		dstBatch = req.Msgs
+	default:
+		err = fmt.Errorf("%w: %s", ErrProto, err)
+	}
+	return
+}
+
+// UnmarshalProtoDelimited decodes the stream of the length-delimited events in the protobuf wire format.
+func UnmarshalProtoDelimited(src []byte) (dstBatch []*pb.CloudEvent, err error) {
+	r := bufio.NewReader(bytes.NewReader(src))
+	for {
+		var dst pb.CloudEvent
+		err = protodelim.UnmarshalFrom(r, &dst)
+		if err != nil {
+			break
+		}
+		dstBatch = append(dstBatch, &dst)
+	}
+	switch err {
+	case io.EOF:
+		err = nil
+	default:
+		err = fmt.Errorf("%w: event #%d: %s", ErrProto, len(dstBatch), err)
+	}
+	return
+}
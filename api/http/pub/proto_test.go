@@ -0,0 +1,79 @@
+package pub
+
+import (
+	"bytes"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"testing"
+)
+
+func TestUnmarshalProto(t *testing.T) {
+	src := &pb.CloudEvent{
+		Id:          "1",
+		Source:      "src1",
+		SpecVersion: "1.0",
+		Type:        "type1",
+		Data: &pb.CloudEvent_TextData{
+			TextData: "foo",
+		},
+	}
+	raw, err := proto.Marshal(src)
+	require.NoError(t, err)
+	var dst pb.CloudEvent
+	err = UnmarshalProto(raw, &dst)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(src, &dst))
+	err = UnmarshalProto([]byte{0xff, 0xff}, &dst)
+	assert.ErrorIs(t, err, ErrProto)
+}
+
+func TestUnmarshalProtoBatch(t *testing.T) {
+	srcs := []*pb.CloudEvent{
+		{
+			Id:     "1",
+			Source: "src1",
+			Type:   "type1",
+		},
+		{
+			Id:     "2",
+			Source: "src2",
+			Type:   "type2",
+		},
+	}
+	t.Run("request", func(t *testing.T) {
+		raw, err := proto.Marshal(&publisher.SubmitMessagesRequest{
+			Msgs: srcs,
+		})
+		require.NoError(t, err)
+		header := http.Header{headerContentType: {MimeProto}}
+		require.True(t, IsProtoMode(header))
+		require.False(t, IsProtoDelimited(header))
+		dsts, err := UnmarshalProtoBatch(raw)
+		require.NoError(t, err)
+		require.Len(t, dsts, 2)
+		assert.Equal(t, "1", dsts[0].Id)
+		assert.Equal(t, "2", dsts[1].Id)
+	})
+	t.Run("delimited", func(t *testing.T) {
+		var buf bytes.Buffer
+		for _, src := range srcs {
+			_, err := protodelim.MarshalTo(&buf, src)
+			require.NoError(t, err)
+		}
+		header := http.Header{headerContentType: {MimeCeBatchProto}}
+		require.True(t, IsProtoMode(header))
+		require.True(t, IsProtoDelimited(header))
+		dsts, err := UnmarshalProtoDelimited(buf.Bytes())
+		require.NoError(t, err)
+		require.Len(t, dsts, 2)
+		assert.Equal(t, "1", dsts[0].Id)
+		assert.Equal(t, "2", dsts[1].Id)
+		_, err = UnmarshalProtoDelimited(buf.Bytes()[:buf.Len()-1])
+		assert.ErrorIs(t, err, ErrProto)
+	})
+}
@@ -1,5 +1,48 @@
 package pub
 
+import (
+	"github.com/awakari/pub/api/grpc/publisher"
+	"strings"
+)
+
 type response struct {
-	AckCount uint32 `json:"ackCount"`
+	AckCount uint32        `json:"ackCount"`
+	Results  []eventResult `json:"results,omitempty"`
+}
+
+type eventResult struct {
+	Id     string `json:"id"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func newResponse(src *publisher.SubmitMessagesResponse) (dst response) {
+	dst.AckCount = src.AckCount
+	for _, r := range src.Results {
+		dst.Results = append(dst.Results, eventResult{
+			Id:     r.Id,
+			Status: strings.ToLower(r.Status.String()),
+			Reason: r.Reason,
+		})
+	}
+	return
+}
+
+type streamResponse struct {
+	Accepted uint64       `json:"accepted"`
+	Rejected []lineResult `json:"rejected,omitempty"`
+	Failed   []lineResult `json:"failed,omitempty"`
+}
+
+type lineResult struct {
+	Line   uint64 `json:"line"`
+	Id     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type wsAck struct {
+	Id       string `json:"id"`
+	Status   int    `json:"status"`
+	AckCount uint32 `json:"ackCount,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
@@ -0,0 +1,156 @@
+package pub
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/awakari/pub/api/http/grpc"
+	"github.com/bytedance/sonic"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+const MimeNdJson = "application/x-ndjson"
+
+var keySpecVersionStructured = []byte(`"` + ceAttrSpecVersion + `"`)
+
+// UnmarshalJson decodes the single JSON event. The input is treated as the CloudEvents JSON format event when it
+// contains the "specversion" key, otherwise as the same JSON event accepted by the POST /v1.
+func UnmarshalJson(src []byte, dst *pb.CloudEvent) (err error) {
+	switch {
+	case bytes.Contains(src, keySpecVersionStructured):
+		err = UnmarshalStructured(src, dst)
+	default:
+		err = Unmarshal(src, dst)
+	}
+	return
+}
+
+type streamLine struct {
+	num uint64
+	evt *pb.CloudEvent
+}
+
+func (h handler) WriteStream(ctx *gin.Context) {
+	defer ctx.Request.Body.Close()
+	if t := mediaType(ctx.Request.Header); t != "" && t != MimeNdJson {
+		ctx.String(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type: %s, expected %s", t, MimeNdJson))
+		return
+	}
+	grpcCtx, groupId, userId := grpc.AuthRequestContext(ctx)
+	var resp streamResponse
+	batch := make([]streamLine, 0, h.cfgHttp.Stream.BatchSize)
+	// set once the usage limit is exhausted, there's no sense to read the rest of the request
+	var limited bool
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		evts := make([]*pb.CloudEvent, 0, len(batch))
+		for _, l := range batch {
+			evts = append(evts, l.evt)
+		}
+		respSubmit, err := h.writer.SubmitPermittedEvents(grpcCtx, &publisher.SubmitMessagesRequest{Msgs: evts}, groupId, userId)
+		var ackCount uint32
+		var results []*publisher.Result
+		if err == nil {
+			ackCount = respSubmit.AckCount
+			results = respSubmit.Results
+		}
+		resp.Accepted += uint64(ackCount)
+		reason := "was unable to submit, retry later"
+		switch {
+		case status.Code(err) == codes.ResourceExhausted:
+			limited = true
+			reason = err.Error()
+		case err != nil:
+			reason = err.Error()
+		}
+		for i := int(ackCount); i < len(batch); i++ {
+			l := batch[i]
+			r := lineResult{
+				Line:   l.num,
+				Id:     l.evt.Id,
+				Reason: reason,
+			}
+			if i < len(results) && results[i].Status != publisher.ResultStatus_ACCEPTED {
+				r.Reason = results[i].Reason
+				if results[i].Status == publisher.ResultStatus_OVER_LIMIT {
+					limited = true
+				}
+			}
+			resp.Failed = append(resp.Failed, r)
+		}
+		batch = batch[:0]
+	}
+
+	scanner := bufio.NewScanner(ctx.Request.Body)
+	scanner.Buffer(make([]byte, 0, min(bufio.MaxScanTokenSize, int(h.cfgHttp.Stream.LineSizeMax))), int(h.cfgHttp.Stream.LineSizeMax))
+	var num uint64
+	var stopped bool
+	for scanner.Scan() {
+		if limited {
+			stopped = true
+			break
+		}
+		num++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var evt pb.CloudEvent
+		err := UnmarshalJson(line, &evt)
+		if err != nil {
+			resp.Rejected = append(resp.Rejected, lineResult{
+				Line:   num,
+				Reason: err.Error(),
+			})
+			continue
+		}
+		var b publisher.Batch
+		b, err = h.ingest.Check(grpcCtx, groupId, userId, []*pb.CloudEvent{&evt})
+		switch {
+		case err != nil:
+			resp.Failed = append(resp.Failed, lineResult{
+				Line:   num,
+				Id:     evt.Id,
+				Reason: err.Error(),
+			})
+		case len(b.Accepted) == 0:
+			// the quarantined event is not published, so it's reported as rejected
+			resp.Rejected = append(resp.Rejected, lineResult{
+				Line:   num,
+				Id:     evt.Id,
+				Reason: b.Results[0].Reason,
+			})
+		default:
+			batch = append(batch, streamLine{
+				num: num,
+				evt: &evt,
+			})
+			if uint32(len(batch)) >= h.cfgHttp.Stream.BatchSize {
+				flush()
+			}
+		}
+	}
+	flush()
+	switch err := scanner.Err(); {
+	case stopped:
+		resp.Failed = append(resp.Failed, lineResult{
+			Line:   num + 1,
+			Reason: "usage limit reached, stopped",
+		})
+	case err != nil:
+		resp.Rejected = append(resp.Rejected, lineResult{
+			Line:   num + 1,
+			Reason: fmt.Sprintf("failed to read, stopped: %s", err),
+		})
+	}
+
+	raw, _ := sonic.Marshal(resp)
+	ctx.Data(http.StatusOK, gin.MIMEJSON, raw)
+}
@@ -0,0 +1,230 @@
+package pub
+
+import (
+	"context"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_WriteStream(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	_ = blacklist.Put(context.TODO(), "source:https://spam.example", model.BlacklistValue{})
+	_ = blacklist.Put(context.TODO(), "source:https://review.example", model.BlacklistValue{Action: model.BlacklistActionQuarantine})
+	h := NewHandler(
+		publisher.NewServiceMock(),
+		config.WriterInternalConfig{RateLimitPerMinute: 1},
+		publisher.NewIngest(
+			publisher.NewServiceMock(),
+			model.NewReservedAttributes([]string{"awk*"}, false),
+			model.NewBlacklistPolicy(blacklists, "awkflagged", slog.Default()),
+			storage.NewSchemasMock(),
+			true,
+			slog.Default(),
+		),
+		storage.NewHooksMock(),
+		config.HttpConfig{
+			Stream: config.HttpStreamConfig{
+				BatchSize:   2,
+				LineSizeMax: 1024,
+			},
+		},
+		slog.Default(),
+	)
+	cases := map[string]struct {
+		groupId     string
+		contentType string
+		in          string
+		status      int
+		out         streamResponse
+	}{
+		"ok": {
+			groupId:     "group0",
+			contentType: MimeNdJson,
+			in: `{"id": "1", "source": "src1", "type": "type1", "text_data": "foo"}
+
+{"specversion": "1.0", "id": "2", "source": "src2", "type": "type2", "data": "bar"}
+{"id": "3", "source": "https://spam.example/feed", "type": "type3"}
+{"id": "4", "source":
+{"id": "5", "source": "src5", "type": "type5"}
+`,
+			status: http.StatusOK,
+			out: streamResponse{
+				Accepted: 3,
+				Rejected: []lineResult{
+					{
+						Line:   4,
+						Id:     "3",
+						Reason: "forbidden by prefix: source:https://spam.example",
+					},
+					{
+						Line: 5,
+					},
+				},
+			},
+		},
+		"quarantined": {
+			groupId:     "group0",
+			contentType: MimeNdJson,
+			in: `{"id": "1", "source": "https://review.example/feed", "type": "type1"}
+{"id": "quarantine_fail", "source": "https://review.example/feed", "type": "type2"}
+{"id": "3", "source": "src3", "type": "type3"}
+`,
+			status: http.StatusOK,
+			out: streamResponse{
+				Accepted: 1,
+				Rejected: []lineResult{
+					{
+						Line:   1,
+						Id:     "1",
+						Reason: "quarantined for review, forbidden by prefix: source:https://review.example",
+					},
+					{
+						Line:   2,
+						Id:     "quarantine_fail",
+						Reason: "was not quarantined, retry later",
+					},
+				},
+			},
+		},
+		"schema violation": {
+			groupId: "group0",
+			in: `{"id": "1", "source": "src1", "type": "com_awakari_webapp"}
+{"id": "2", "source": "src2", "type": "type2"}`,
+			status: http.StatusOK,
+			out: streamResponse{
+				Accepted: 1,
+				Rejected: []lineResult{
+					{
+						Line: 1,
+						Id:   "1",
+					},
+				},
+			},
+		},
+		"limit reached": {
+			groupId: "limit_reached",
+			in: `{"id": "1", "source": "src1", "type": "type1"}
+{"id": "2", "source": "src2", "type": "type2"}
+{"id": "3", "source": "src3", "type": "type3"}
+{"id": "4", "source": "src4", "type": "type4"}`,
+			status: http.StatusOK,
+			out: streamResponse{
+				Failed: []lineResult{
+					{
+						Line: 1,
+						Id:   "1",
+					},
+					{
+						Line: 2,
+						Id:   "2",
+					},
+					{
+						Line:   3,
+						Reason: "usage limit reached, stopped",
+					},
+				},
+			},
+		},
+		"over limit": {
+			groupId: "over_limit",
+			in: `{"id": "1", "source": "src1", "type": "type1"}
+{"id": "2", "source": "src2", "type": "type2"}
+{"id": "3", "source": "src3", "type": "type3"}`,
+			status: http.StatusOK,
+			out: streamResponse{
+				Accepted: 1,
+				Failed: []lineResult{
+					{
+						Line:   2,
+						Id:     "2",
+						Reason: "user id user0: usage limit reached",
+					},
+					{
+						Line:   3,
+						Reason: "usage limit reached, stopped",
+					},
+				},
+			},
+		},
+		"limit reached at the end": {
+			groupId: "over_limit",
+			in: `{"id": "1", "source": "src1", "type": "type1"}
+{"id": "2", "source": "src2", "type": "type2"}`,
+			status: http.StatusOK,
+			out: streamResponse{
+				Accepted: 1,
+				Failed: []lineResult{
+					{
+						Line:   2,
+						Id:     "2",
+						Reason: "user id user0: usage limit reached",
+					},
+				},
+			},
+		},
+		"line too long": {
+			groupId: "group0",
+			in:      `{"id": "1", "source": "src1", "type": "type1", "text_data": "` + strings.Repeat("a", 1024) + `"}`,
+			status:  http.StatusOK,
+			out: streamResponse{
+				Rejected: []lineResult{
+					{
+						Line: 1,
+					},
+				},
+			},
+		},
+		"unsupported content type": {
+			contentType: "application/json",
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/stream", strings.NewReader(c.in))
+			if c.contentType != "" {
+				ctx.Request.Header.Set(headerContentType, c.contentType)
+			}
+			ctx.Set(model.KeyGroupId, c.groupId)
+			ctx.Set(model.KeyUserId, "user0")
+			h.WriteStream(ctx)
+			require.Equal(t, c.status, w.Code)
+			if c.status != http.StatusOK {
+				return
+			}
+			var out streamResponse
+			require.NoError(t, sonic.Unmarshal(w.Body.Bytes(), &out))
+			assert.Equal(t, c.out.Accepted, out.Accepted)
+			require.Len(t, out.Rejected, len(c.out.Rejected))
+			for i, r := range c.out.Rejected {
+				assert.Equal(t, r.Line, out.Rejected[i].Line)
+				assert.Equal(t, r.Id, out.Rejected[i].Id)
+				if r.Reason != "" {
+					assert.Equal(t, r.Reason, out.Rejected[i].Reason)
+				}
+			}
+			require.Len(t, out.Failed, len(c.out.Failed))
+			for i, r := range c.out.Failed {
+				assert.Equal(t, r.Line, out.Failed[i].Line)
+				assert.Equal(t, r.Id, out.Failed[i].Id)
+				if r.Reason != "" {
+					assert.Equal(t, r.Reason, out.Failed[i].Reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package pub
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bytedance/sonic"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MimeCeJson = "application/cloudevents+json"
+const MimeCeBatchJson = "application/cloudevents-batch+json"
+
+const ceAttrData = "data"
+const ceAttrDataBase64 = "data_base64"
+
+var ErrStructured = errors.New("invalid structured content mode event")
+
+// IsStructuredMode returns true when the request content type is the CloudEvents JSON format.
+func IsStructuredMode(header http.Header) bool {
+	return mediaType(header) == MimeCeJson
+}
+
+// IsStructuredBatchMode returns true when the request content type is the CloudEvents JSON batch format.
+func IsStructuredBatchMode(header http.Header) bool {
+	return mediaType(header) == MimeCeBatchJson
+}
+
+func mediaType(header http.Header) (t string) {
+	t, _, _ = mime.ParseMediaType(header.Get(headerContentType))
+	return
+}
+
+// UnmarshalStructured converts the event in the CloudEvents JSON format to the destination event.
+func UnmarshalStructured(src []byte, dst *pb.CloudEvent) (err error) {
+	var raw map[string]json.RawMessage
+	err = sonic.Unmarshal(src, &raw)
+	if err == nil {
+		err = convertStructured(raw, dst)
+	}
+	if err != nil && !errors.Is(err, ErrStructured) {
+		err = fmt.Errorf("%w: %s", ErrStructured, err)
+	}
+	return
+}
+
+// UnmarshalStructuredBatch converts the top-level JSON array of events in the CloudEvents JSON format.
+func UnmarshalStructuredBatch(src []byte) (dstBatch []*pb.CloudEvent, err error) {
+	var rawBatch []map[string]json.RawMessage
+	err = sonic.Unmarshal(src, &rawBatch)
+	if err == nil {
+		for i, raw := range rawBatch {
+			var dst pb.CloudEvent
+			err = convertStructured(raw, &dst)
+			if err != nil {
+				err = fmt.Errorf("event #%d: %w", i, err)
+				break
+			}
+			dstBatch = append(dstBatch, &dst)
+		}
+	}
+	if err != nil && !errors.Is(err, ErrStructured) {
+		err = fmt.Errorf("%w: %s", ErrStructured, err)
+	}
+	return
+}
+
+// IsJsonArray returns true if the specified JSON input is an array.
+func IsJsonArray(src []byte) bool {
+	src = bytes.TrimSpace(src)
+	return len(src) > 0 && src[0] == '['
+}
+
+func convertStructured(raw map[string]json.RawMessage, dst *pb.CloudEvent) (err error) {
+	dst.Attributes = make(map[string]*pb.CloudEventAttributeValue)
+	var contentType string
+	for name, v := range raw {
+		switch name {
+		case ceAttrId:
+			err = sonic.Unmarshal(v, &dst.Id)
+		case ceAttrSource:
+			err = sonic.Unmarshal(v, &dst.Source)
+		case ceAttrSpecVersion:
+			err = sonic.Unmarshal(v, &dst.SpecVersion)
+		case ceAttrType:
+			err = sonic.Unmarshal(v, &dst.Type)
+		case ceAttrData, ceAttrDataBase64:
+			// handled below, when the content type is known
+		case ceAttrDataContentType:
+			err = sonic.Unmarshal(v, &contentType)
+			if err == nil {
+				dst.Attributes[name] = &pb.CloudEventAttributeValue{
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: contentType,
+					},
+				}
+			}
+		case ceAttrTime:
+			var t time.Time
+			err = sonic.Unmarshal(v, &t)
+			if err == nil {
+				dst.Attributes[name] = &pb.CloudEventAttributeValue{
+					Attr: &pb.CloudEventAttributeValue_CeTimestamp{
+						CeTimestamp: timestamppb.New(t),
+					},
+				}
+			}
+		case ceAttrDataSchema:
+			var u string
+			err = sonic.Unmarshal(v, &u)
+			if err == nil {
+				dst.Attributes[name] = &pb.CloudEventAttributeValue{
+					Attr: &pb.CloudEventAttributeValue_CeUri{
+						CeUri: u,
+					},
+				}
+			}
+		default:
+			var attr *pb.CloudEventAttributeValue
+			attr, err = inferAttribute(v)
+			if attr != nil {
+				dst.Attributes[name] = attr
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("%w: attribute %s: %s", ErrStructured, name, err)
+			return
+		}
+	}
+	if v, ok := raw[ceAttrDataBase64]; ok {
+		var s string
+		var data []byte
+		err = sonic.Unmarshal(v, &s)
+		if err == nil {
+			data, err = base64.StdEncoding.DecodeString(s)
+		}
+		if err != nil {
+			err = fmt.Errorf("%w: attribute %s: %s", ErrStructured, ceAttrDataBase64, err)
+			return
+		}
+		dst.Data = &pb.CloudEvent_BinaryData{
+			BinaryData: data,
+		}
+	}
+	if v, ok := raw[ceAttrData]; ok {
+		if dst.Data != nil {
+			err = fmt.Errorf("%w: both %s and %s are present", ErrStructured, ceAttrData, ceAttrDataBase64)
+			return
+		}
+		v = bytes.TrimSpace(v)
+		var s string
+		switch {
+		case len(v) > 0 && v[0] == '"' && !isJsonContentType(contentType):
+			// a string value is the data itself unless the content type is explicitly JSON
+			err = sonic.Unmarshal(v, &s)
+			if err != nil {
+				err = fmt.Errorf("%w: attribute %s: %s", ErrStructured, ceAttrData, err)
+				return
+			}
+		default:
+			s = string(v)
+		}
+		dst.Data = &pb.CloudEvent_TextData{
+			TextData: s,
+		}
+	}
+	return
+}
+
+// inferAttribute converts the extension attribute JSON value to the corresponding CloudEvent attribute value type.
+// Returns nil value for JSON null, which means the attribute is absent.
+func inferAttribute(src json.RawMessage) (dst *pb.CloudEventAttributeValue, err error) {
+	src = bytes.TrimSpace(src)
+	if len(src) == 0 {
+		err = errors.New("empty value")
+		return
+	}
+	switch src[0] {
+	case 'n':
+		// null means the attribute is absent
+	case 't', 'f':
+		var b bool
+		err = sonic.Unmarshal(src, &b)
+		if err == nil {
+			dst = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeBoolean{
+					CeBoolean: b,
+				},
+			}
+		}
+	case '"':
+		var s string
+		err = sonic.Unmarshal(src, &s)
+		if err == nil {
+			dst = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeString{
+					CeString: s,
+				},
+			}
+		}
+	case '{', '[':
+		err = errors.New("object and array values are not supported")
+	default:
+		i, errInt := strconv.ParseInt(string(src), 10, 64)
+		switch {
+		case errInt == nil && i >= math.MinInt32 && i <= math.MaxInt32:
+			dst = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeInteger{
+					CeInteger: int32(i),
+				},
+			}
+		default:
+			// the number doesn't fit the CloudEvents integer type, keep it as is
+			_, err = strconv.ParseFloat(string(src), 64)
+			if err == nil {
+				dst = &pb.CloudEventAttributeValue{
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: string(src),
+					},
+				}
+			}
+		}
+	}
+	return
+}
+
+func isJsonContentType(contentType string) (ok bool) {
+	t, _, err := mime.ParseMediaType(contentType)
+	ok = err == nil && (t == "application/json" || t == "text/json" || strings.HasSuffix(t, "+json"))
+	return
+}
@@ -0,0 +1,99 @@
+package pub
+
+import (
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalStructured(t *testing.T) {
+	cases := map[string]struct {
+		in    string
+		check func(t *testing.T, evt *pb.CloudEvent)
+		err   error
+	}{
+		"text data": {
+			in: `{
+  "specversion": "1.0",
+  "id": "2qRgPkuvdypNEnrA7HdsWpASaco",
+  "source": "https://awakari.com/pub-msg.html",
+  "type": "com_awakari_webapp",
+  "time": "2024-12-19T17:52:59.216Z",
+  "datacontenttype": "text/plain",
+  "dataschema": "https://awakari.com/schema.json",
+  "title": "v1",
+  "flag": true,
+  "count": -42,
+  "big": 12345678901,
+  "absent": null,
+  "data": "test"
+}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, "2qRgPkuvdypNEnrA7HdsWpASaco", evt.Id)
+				assert.Equal(t, "1.0", evt.SpecVersion)
+				assert.Equal(t, "https://awakari.com/pub-msg.html", evt.Source)
+				assert.Equal(t, "com_awakari_webapp", evt.Type)
+				assert.Equal(t, "test", evt.GetTextData())
+				assert.Equal(t, "text/plain", evt.Attributes["datacontenttype"].GetCeString())
+				assert.Equal(t, "https://awakari.com/schema.json", evt.Attributes["dataschema"].GetCeUri())
+				assert.Equal(t, time.Date(2024, 12, 19, 17, 52, 59, 216_000_000, time.UTC), evt.Attributes["time"].GetCeTimestamp().AsTime())
+				assert.Equal(t, "v1", evt.Attributes["title"].GetCeString())
+				assert.Equal(t, true, evt.Attributes["flag"].GetCeBoolean())
+				assert.Equal(t, int32(-42), evt.Attributes["count"].GetCeInteger())
+				assert.Equal(t, "12345678901", evt.Attributes["big"].GetCeString())
+				assert.NotContains(t, evt.Attributes, "absent")
+			},
+		},
+		"json data": {
+			in: `{"specversion":"1.0","id":"1","source":"src1","type":"type1","data":{"foo":"bar"}}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, `{"foo":"bar"}`, evt.GetTextData())
+			},
+		},
+		"base64 data": {
+			in: `{"specversion":"1.0","id":"1","source":"src1","type":"type1","data_base64":"TWFueSBoYW5kcyBtYWtlIGxpZ2h0IHdvcmsu"}`,
+			check: func(t *testing.T, evt *pb.CloudEvent) {
+				assert.Equal(t, []byte("Many hands make light work."), evt.GetBinaryData())
+			},
+		},
+		"both data": {
+			in:  `{"specversion":"1.0","id":"1","source":"src1","type":"type1","data":"foo","data_base64":"Zm9v"}`,
+			err: ErrStructured,
+		},
+		"object attribute": {
+			in:  `{"specversion":"1.0","id":"1","source":"src1","type":"type1","foo":{"bar":1}}`,
+			err: ErrStructured,
+		},
+		"malformed": {
+			in:  `{"specversion":`,
+			err: ErrStructured,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var evt pb.CloudEvent
+			err := UnmarshalStructured([]byte(c.in), &evt)
+			assert.ErrorIs(t, err, c.err)
+			if c.check != nil {
+				c.check(t, &evt)
+			}
+		})
+	}
+}
+
+func TestUnmarshalStructuredBatch(t *testing.T) {
+	in := `[
+  {"specversion":"1.0","id":"1","source":"src1","type":"type1","data":"foo"},
+  {"specversion":"1.0","id":"2","source":"src2","type":"type2","count":1}
+]`
+	require.True(t, IsJsonArray([]byte(in)))
+	evts, err := UnmarshalStructuredBatch([]byte(in))
+	require.NoError(t, err)
+	require.Len(t, evts, 2)
+	assert.Equal(t, "1", evts[0].Id)
+	assert.Equal(t, "foo", evts[0].GetTextData())
+	assert.Equal(t, "2", evts[1].Id)
+	assert.Equal(t, int32(1), evts[1].Attributes["count"].GetCeInteger())
+}
@@ -0,0 +1,58 @@
+package pub
+
+import (
+	"fmt"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/bytedance/sonic"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"slices"
+)
+
+// WsProtocol is the application WebSocket subprotocol. The browser client offers it along with the bearer token
+// subprotocol, so the server has the subprotocol to select without echoing the token back.
+const WsProtocol = "awakari.v1"
+
+func (h handler) WriteWebSocket(ctx *gin.Context) {
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{WsProtocol},
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || slices.Contains(h.cfgHttp.WebSocket.Origins, origin)
+		},
+	}
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		h.log.Warn(fmt.Sprintf("failed to upgrade the websocket connection: %s", err))
+		return // the upgrader has already responded with the error
+	}
+	defer conn.Close()
+	conn.SetReadLimit(h.cfgHttp.WebSocket.FrameSizeMax)
+	for {
+		var frame []byte
+		_, frame, err = conn.ReadMessage()
+		if err != nil {
+			break // the connection is closed by the client or broken
+		}
+		var evt pb.CloudEvent
+		var ack wsAck
+		err = UnmarshalJson(frame, &evt)
+		switch err {
+		case nil:
+			ack.Id = evt.Id
+			var resp *publisher.SubmitMessagesResponse
+			ack.Status, resp, ack.Error = h.submit(ctx, []*pb.CloudEvent{&evt}, false)
+			ack.AckCount = resp.GetAckCount()
+		default:
+			ack.Status = http.StatusBadRequest
+			ack.Error = err.Error()
+		}
+		raw, _ := sonic.Marshal(ack)
+		err = conn.WriteMessage(websocket.TextMessage, raw)
+		if err != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package pub
+
+import (
+	"context"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_WriteWebSocket(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	blacklist := blacklists.Get("")
+	_ = blacklist.Put(context.TODO(), "source:https://spam.example", model.BlacklistValue{})
+	h := NewHandler(
+		publisher.NewServiceMock(),
+		config.WriterInternalConfig{RateLimitPerMinute: 1},
+		publisher.NewIngest(
+			publisher.NewServiceMock(),
+			model.NewReservedAttributes([]string{"awk*"}, false),
+			model.NewBlacklistPolicy(blacklists, "awkflagged", slog.Default()),
+			storage.NewSchemasMock(),
+			true,
+			slog.Default(),
+		),
+		storage.NewHooksMock(),
+		config.HttpConfig{
+			WebSocket: config.WebSocketConfig{
+				Origins:      []string{"https://awakari.com"},
+				FrameSizeMax: 1024,
+			},
+		},
+		slog.Default(),
+	)
+	r := gin.New()
+	r.GET("/v1/ws", func(ctx *gin.Context) {
+		ctx.Set(model.KeyGroupId, ctx.Query(model.KeyGroupId))
+		ctx.Set(model.KeyUserId, "user0")
+	}, h.WriteWebSocket)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	uri := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/ws?" + model.KeyGroupId + "="
+
+	t.Run("forbidden origin", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(uri+"group0", http.Header{"Origin": {"https://evil.example"}})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("subprotocol", func(t *testing.T) {
+		d := websocket.Dialer{
+			Subprotocols: []string{WsProtocol, "bearer.token0"},
+		}
+		conn, _, err := d.Dial(uri+"group0", http.Header{"Origin": {"https://awakari.com"}})
+		require.NoError(t, err)
+		defer conn.Close()
+		assert.Equal(t, WsProtocol, conn.Subprotocol())
+	})
+
+	cases := map[string]struct {
+		groupId string
+		frames  []string
+		acks    []wsAck
+	}{
+		"ok": {
+			groupId: "group0",
+			frames: []string{
+				`{"id": "1", "source": "src1", "type": "type1", "text_data": "foo"}`,
+				`{"specversion": "1.0", "id": "2", "source": "src2", "type": "type2"}`,
+				`{"id": "3", "source": "https://spam.example/feed", "type": "type3"}`,
+				`{"id": `,
+				`{"specversion": "1.0", "id": "4", "source": "src4", "type": "com_awakari_webapp", "title": "foo"}`,
+				`{"specversion": "1.0", "id": "5", "source": "src5", "type": "com_awakari_webapp"}`,
+			},
+			acks: []wsAck{
+				{
+					Id:       "1",
+					Status:   http.StatusOK,
+					AckCount: 1,
+				},
+				{
+					Id:       "2",
+					Status:   http.StatusOK,
+					AckCount: 1,
+				},
+				{
+					Id:     "3",
+					Status: http.StatusForbidden,
+					Error:  "forbidden by prefix: source:https://spam.example",
+				},
+				{
+					Status: http.StatusBadRequest,
+				},
+				{
+					Id:       "4",
+					Status:   http.StatusOK,
+					AckCount: 1,
+				},
+				{
+					Id:     "5",
+					Status: http.StatusBadRequest,
+					Error:  "event #0, id: 5: event doesn't conform the schema com_awakari_webapp v1: /attributes: missing properties: 'title'",
+				},
+			},
+		},
+		"limit reached": {
+			groupId: "limit_reached",
+			frames: []string{
+				`{"id": "1", "source": "src1", "type": "type1"}`,
+			},
+			acks: []wsAck{
+				{
+					Id:     "1",
+					Status: http.StatusTooManyRequests,
+					Error:  "rpc error: code = ResourceExhausted desc = limit reached",
+				},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			conn, _, err := websocket.DefaultDialer.Dial(uri+c.groupId, http.Header{"Origin": {"https://awakari.com"}})
+			require.NoError(t, err)
+			defer conn.Close()
+			for i, frame := range c.frames {
+				require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+				_, raw, err := conn.ReadMessage()
+				require.NoError(t, err)
+				var ack wsAck
+				require.NoError(t, sonic.Unmarshal(raw, &ack))
+				assert.Equal(t, c.acks[i].Id, ack.Id)
+				assert.Equal(t, c.acks[i].Status, ack.Status)
+				assert.Equal(t, c.acks[i].AckCount, ack.AckCount)
+				if c.acks[i].Error != "" {
+					assert.Equal(t, c.acks[i].Error, ack.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/api/http/grpc"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Handler interface {
+
+	// Register creates the next version of the schema for the event type and optional data schema. Only the owner
+	// of the first version may add the next versions.
+	Register(ctx *gin.Context)
+
+	// Read returns the requested schema version or the latest one when the version query param is not set.
+	Read(ctx *gin.Context)
+}
+
+type handler struct {
+	stor storage.Schemas
+}
+
+const keyQueryDataSchema = "dataschema"
+const keyQueryVersion = "version"
+
+func NewHandler(stor storage.Schemas) Handler {
+	return handler{
+		stor: stor,
+	}
+}
+
+func (h handler) Register(ctx *gin.Context) {
+	defer ctx.Request.Body.Close()
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	var p registerPayload
+	err = sonic.Unmarshal(body, &p)
+	if err == nil {
+		err = p.validate()
+	}
+	s := model.Schema{
+		Type:       p.Type,
+		DataSchema: p.DataSchema,
+		Value:      string(p.Schema),
+	}
+	if err == nil {
+		err = s.Compile()
+	}
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	_, groupId, userId := grpc.AuthRequestContext(ctx)
+	var latest model.Schema
+	latest, err = h.stor.Read(ctx, p.Type, p.DataSchema, 0)
+	switch {
+	case err == nil:
+		if latest.GroupId != groupId || latest.UserId != userId {
+			ctx.String(http.StatusForbidden, fmt.Sprintf("schema for type %s is owned by another user", p.Type))
+			return
+		}
+	case errors.Is(err, storage.ErrNotFound):
+		err = nil
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.Version = latest.Version + 1
+	s.GroupId = groupId
+	s.UserId = userId
+	s.CreatedAt = time.Now().UTC()
+	err = h.stor.Create(ctx, s)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusCreated, versionPayload{
+			Version: s.Version,
+		})
+	case errors.Is(err, storage.ErrConflict):
+		ctx.String(http.StatusConflict, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h handler) Read(ctx *gin.Context) {
+	var version uint64
+	var err error
+	if versionStr := ctx.Query(keyQueryVersion); versionStr != "" {
+		version, err = strconv.ParseUint(versionStr, 10, 32)
+		if err != nil || version == 0 {
+			ctx.String(http.StatusBadRequest, fmt.Sprintf("invalid version query param: %s", versionStr))
+			return
+		}
+	}
+	var s model.Schema
+	s, err = h.stor.Read(ctx, ctx.Param("type"), ctx.Query(keyQueryDataSchema), uint32(version))
+	switch {
+	case err == nil:
+		raw, _ := sonic.Marshal(schemaPayload{
+			Type:       s.Type,
+			DataSchema: s.DataSchema,
+			Version:    s.Version,
+			CreatedAt:  s.CreatedAt,
+			Schema:     []byte(s.Value),
+		})
+		ctx.Data(http.StatusOK, gin.MIMEJSON, raw)
+	case errors.Is(err, storage.ErrNotFound):
+		ctx.String(http.StatusNotFound, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
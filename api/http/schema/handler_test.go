@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Register(t *testing.T) {
+	h := NewHandler(storage.NewSchemasMock())
+	cases := map[string]struct {
+		userId string
+		in     string
+		status int
+		out    string
+	}{
+		"new type": {
+			userId: "user0",
+			in:     `{"type":"com_awakari_new","schema":{"type":"object"}}`,
+			status: http.StatusCreated,
+			out:    `{"version":1}`,
+		},
+		"next version": {
+			userId: "user0",
+			in:     `{"type":"com_awakari_webapp","schema":{"type":"object"}}`,
+			status: http.StatusCreated,
+			out:    `{"version":2}`,
+		},
+		"not owner": {
+			userId: "user1",
+			in:     `{"type":"com_awakari_webapp","schema":{"type":"object"}}`,
+			status: http.StatusForbidden,
+		},
+		"conflict": {
+			userId: "user0",
+			in:     `{"type":"conflict","schema":{"type":"object"}}`,
+			status: http.StatusConflict,
+		},
+		"missing type": {
+			userId: "user0",
+			in:     `{"schema":{"type":"object"}}`,
+			status: http.StatusBadRequest,
+		},
+		"invalid schema": {
+			userId: "user0",
+			in:     `{"type":"com_awakari_new","schema":{"type":42}}`,
+			status: http.StatusBadRequest,
+		},
+		"storage failure": {
+			userId: "user0",
+			in:     `{"type":"fail","schema":{"type":"object"}}`,
+			status: http.StatusInternalServerError,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/schema", strings.NewReader(c.in))
+			ctx.Set(model.KeyGroupId, "group0")
+			ctx.Set(model.KeyUserId, c.userId)
+			h.Register(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.out != "" {
+				assert.JSONEq(t, c.out, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Read(t *testing.T) {
+	h := NewHandler(storage.NewSchemasMock())
+	cases := map[string]struct {
+		typ     string
+		version string
+		status  int
+		out     string
+	}{
+		"latest": {
+			typ:    "com_awakari_webapp",
+			status: http.StatusOK,
+			out:    `{"type":"com_awakari_webapp","version":1,"createdAt":"2024-12-19T17:52:59Z","schema":{"type":"object","properties":{"attributes":{"type":"object","required":["title"]}}}}`,
+		},
+		"missing version": {
+			typ:     "com_awakari_webapp",
+			version: "2",
+			status:  http.StatusNotFound,
+		},
+		"invalid version": {
+			typ:     "com_awakari_webapp",
+			version: "0",
+			status:  http.StatusBadRequest,
+		},
+		"missing type": {
+			typ:    "com_awakari_new",
+			status: http.StatusNotFound,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			target := "/v1/schema/" + c.typ
+			if c.version != "" {
+				target += "?version=" + c.version
+			}
+			ctx.Request = httptest.NewRequest(http.MethodGet, target, nil)
+			ctx.Params = gin.Params{
+				{
+					Key:   "type",
+					Value: c.typ,
+				},
+			}
+			h.Read(ctx)
+			assert.Equal(t, c.status, w.Code)
+			if c.out != "" {
+				assert.JSONEq(t, c.out, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type registerPayload struct {
+	Type       string          `json:"type"`
+	DataSchema string          `json:"dataschema,omitempty"`
+	Schema     json.RawMessage `json:"schema"`
+}
+
+type versionPayload struct {
+	Version uint32 `json:"version"`
+}
+
+type schemaPayload struct {
+	Type       string          `json:"type"`
+	DataSchema string          `json:"dataschema,omitempty"`
+	Version    uint32          `json:"version"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	Schema     json.RawMessage `json:"schema"`
+}
+
+var errInvalidPayload = errors.New("invalid request payload")
+
+func (rp registerPayload) validate() (err error) {
+	switch {
+	case rp.Type == "":
+		err = fmt.Errorf("%w: missing event type", errInvalidPayload)
+	case len(rp.Schema) == 0:
+		err = fmt.Errorf("%w: missing schema", errInvalidPayload)
+	}
+	return
+}
@@ -0,0 +1,137 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/util"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/segmentio/ksuid"
+	"io"
+	"log/slog"
+	"unicode/utf8"
+)
+
+// Bridge subscribes the configured MQTT topic filters and publishes every received message as an event.
+type Bridge interface {
+	io.Closer
+}
+
+type bridge struct {
+	client paho.Client
+	svc    publisher.Service
+	ingest publisher.Ingest
+	cfg    config.MqttConfig
+	log    *slog.Logger
+}
+
+const specVersion = "1.0"
+const disconnectQuiesceMillis = 1_000
+
+var ErrConnect = errors.New("failed to connect the MQTT broker")
+
+func NewBridge(cfg config.MqttConfig, svc publisher.Service, ingest publisher.Ingest, log *slog.Logger) (b Bridge, err error) {
+	br := &bridge{
+		svc:    svc,
+		ingest: ingest,
+		cfg:    cfg,
+		log:    log,
+	}
+	opts := paho.
+		NewClientOptions().
+		AddBroker(cfg.Uri).
+		SetClientID(cfg.ClientId).
+		SetUsername(cfg.UserName).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(cfg.Timeout).
+		SetOnConnectHandler(br.subscribe).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Warn(fmt.Sprintf("mqtt: connection lost, reconnecting: %s", err))
+		})
+	br.client = paho.NewClient(opts)
+	t := br.client.Connect()
+	switch {
+	case !t.WaitTimeout(cfg.Timeout):
+		err = fmt.Errorf("%w: %s, timeout", ErrConnect, cfg.Uri)
+	case t.Error() != nil:
+		err = fmt.Errorf("%w: %s, %s", ErrConnect, cfg.Uri, t.Error())
+	}
+	if err == nil {
+		b = br
+	}
+	return
+}
+
+func (b *bridge) Close() error {
+	b.client.Disconnect(disconnectQuiesceMillis)
+	return nil
+}
+
+// subscribe is invoked on every (re)connect because the subscriptions don't survive the clean session.
+func (b *bridge) subscribe(client paho.Client) {
+	filters := make(map[string]byte, len(b.cfg.Topics))
+	for _, topic := range b.cfg.Topics {
+		filters[topic] = b.cfg.Qos
+	}
+	t := client.SubscribeMultiple(filters, b.handle)
+	var err error
+	switch {
+	case !t.WaitTimeout(b.cfg.Timeout):
+		err = errors.New("timeout")
+	default:
+		err = t.Error()
+	}
+	b.log.Log(context.TODO(), util.LogLevel(err), fmt.Sprintf("mqtt.Subscribe(%+v): err=%s", b.cfg.Topics, err))
+}
+
+func (b *bridge) handle(_ paho.Client, msg paho.Message) {
+	evt := b.convert(msg.Topic(), msg.Payload())
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
+	defer cancel()
+	var ackCount uint32
+	// the same checks as the events published via the other transports have
+	checked, err := b.ingest.Check(ctx, b.cfg.GroupId, b.cfg.UserId, []*pb.CloudEvent{evt})
+	if err == nil && len(checked.Accepted) == 0 {
+		// invalid, blacklisted or quarantined
+		err = errors.New(checked.Results[0].Reason)
+	}
+	var resp *publisher.SubmitMessagesResponse
+	if err == nil {
+		resp, err = b.svc.SubmitPermittedEvents(ctx, &publisher.SubmitMessagesRequest{
+			Msgs: checked.AcceptedEvents(),
+		}, b.cfg.GroupId, b.cfg.UserId)
+	}
+	if resp != nil {
+		ackCount = resp.AckCount
+	}
+	if err == nil && ackCount == 0 {
+		err = errors.New("was unable to submit")
+	}
+	b.log.Log(ctx, util.LogLevel(err), fmt.Sprintf("mqtt.handle(topic=%s, id=%s): ack=%d, err=%s", msg.Topic(), evt.Id, ackCount, err))
+}
+
+// convert makes the event from the MQTT message: the topic becomes the event source and the payload becomes the
+// text data when it's a valid UTF-8, binary data otherwise.
+func (b *bridge) convert(topic string, payload []byte) (evt *pb.CloudEvent) {
+	evt = &pb.CloudEvent{
+		Id:          ksuid.New().String(),
+		Source:      topic,
+		SpecVersion: specVersion,
+		Type:        b.cfg.Event.Type,
+	}
+	switch {
+	case utf8.Valid(payload):
+		evt.Data = &pb.CloudEvent_TextData{
+			TextData: string(payload),
+		}
+	default:
+		evt.Data = &pb.CloudEvent_BinaryData{
+			BinaryData: payload,
+		}
+	}
+	return
+}
@@ -0,0 +1,184 @@
+package mqtt
+
+import (
+	"context"
+	"github.com/awakari/pub/api/grpc/publisher"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/awakari/pub/storage"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+)
+
+var mqttUri = os.Getenv("MQTT_URI_TEST")
+
+func TestBridge_convert(t *testing.T) {
+	b := &bridge{
+		cfg: config.MqttConfig{
+			GroupId: "group0",
+			UserId:  "user0",
+		},
+	}
+	b.cfg.Event.Type = "com_awakari_mqtt_v1"
+	cases := map[string]struct {
+		payload []byte
+		text    string
+		bin     []byte
+	}{
+		"text": {
+			payload: []byte(`{"temp": 21.5}`),
+			text:    `{"temp": 21.5}`,
+		},
+		"binary": {
+			payload: []byte{0xff, 0xfe, 0x00},
+			bin:     []byte{0xff, 0xfe, 0x00},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			evt := b.convert("sensors/kitchen/temp", c.payload)
+			assert.NotEmpty(t, evt.Id)
+			assert.Equal(t, "sensors/kitchen/temp", evt.Source)
+			assert.Equal(t, "1.0", evt.SpecVersion)
+			assert.Equal(t, "com_awakari_mqtt_v1", evt.Type)
+			assert.Equal(t, c.text, evt.GetTextData())
+			assert.Equal(t, c.bin, evt.GetBinaryData())
+		})
+	}
+}
+
+type messageMock struct {
+	paho.Message
+	topic   string
+	payload []byte
+}
+
+func (mm messageMock) Topic() string {
+	return mm.topic
+}
+
+func (mm messageMock) Payload() []byte {
+	return mm.payload
+}
+
+func TestBridge_handle(t *testing.T) {
+	blacklists := model.NewBlacklistScopes()
+	_ = blacklists.Get("").Put(context.TODO(), "source:spam/", model.BlacklistValue{})
+	rec := publisherRecorder{
+		evts: make(chan *pb.CloudEvent, 1),
+	}
+	b := &bridge{
+		svc: rec,
+		ingest: publisher.NewIngest(
+			rec,
+			model.NewReservedAttributes([]string{"awk*"}, false),
+			model.NewBlacklistPolicy(blacklists, "awkflagged", slog.Default()),
+			storage.NewSchemasMock(),
+			false,
+			slog.Default(),
+		),
+		cfg: config.MqttConfig{
+			Timeout: time.Second,
+			GroupId: "group0",
+			UserId:  "user0",
+		},
+		log: slog.Default(),
+	}
+	b.cfg.Event.Type = "com_awakari_mqtt_v1"
+	cases := map[string]struct {
+		topic     string
+		published bool
+	}{
+		"ok": {
+			topic:     "sensors/kitchen/temp",
+			published: true,
+		},
+		"blacklisted": {
+			topic: "spam/device0",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			b.handle(nil, messageMock{
+				topic:   c.topic,
+				payload: []byte("hello"),
+			})
+			select {
+			case evt := <-rec.evts:
+				assert.True(t, c.published)
+				assert.Equal(t, c.topic, evt.Source)
+				assert.Equal(t, "group0", evt.Attributes[model.KeyCeGroupId].GetCeString())
+				assert.Equal(t, "user0", evt.Attributes[model.KeyCeUserId].GetCeString())
+			default:
+				assert.False(t, c.published)
+			}
+		})
+	}
+}
+
+type publisherRecorder struct {
+	evts chan *pb.CloudEvent
+}
+
+func (pr publisherRecorder) SubmitPermittedEvents(ctx context.Context, req *publisher.SubmitMessagesRequest, groupId, userId string) (resp *publisher.SubmitMessagesResponse, err error) {
+	for _, evt := range req.Msgs {
+		pr.evts <- evt
+	}
+	resp = &publisher.SubmitMessagesResponse{
+		AckCount: uint32(len(req.Msgs)),
+	}
+	return
+}
+
+func (pr publisherRecorder) SubmitInternalEvents(ctx context.Context, req *publisher.SubmitMessagesRequest) (resp *publisher.SubmitMessagesResponse, err error) {
+	return pr.SubmitPermittedEvents(ctx, req, "", "")
+}
+
+func (pr publisherRecorder) SubmitQuarantinedEvents(ctx context.Context, req *publisher.SubmitMessagesRequest) (resp *publisher.SubmitMessagesResponse, err error) {
+	return pr.SubmitPermittedEvents(ctx, req, "", "")
+}
+
+func TestNewBridge(t *testing.T) {
+	if mqttUri == "" {
+		t.Skip("MQTT_URI_TEST is not set")
+	}
+	cfg := config.MqttConfig{
+		Uri:      mqttUri,
+		ClientId: "pub-test-bridge",
+		Topics:   []string{"awakari/test/#"},
+		Qos:      1,
+		Timeout:  10 * time.Second,
+		GroupId:  "group0",
+		UserId:   "user0",
+	}
+	cfg.Event.Type = "com_awakari_mqtt_v1"
+	rec := publisherRecorder{
+		evts: make(chan *pb.CloudEvent, 1),
+	}
+	b, err := NewBridge(cfg, rec, publisher.NewIngest(rec, model.NewReservedAttributes(nil, false), model.NewBlacklistPolicy(model.NewBlacklistScopes(), "awkflagged", slog.Default()), storage.NewSchemasMock(), false, slog.Default()), slog.Default())
+	require.NoError(t, err)
+	defer b.Close()
+
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(mqttUri).SetClientID("pub-test-device"))
+	tc := client.Connect()
+	require.True(t, tc.WaitTimeout(cfg.Timeout))
+	require.NoError(t, tc.Error())
+	defer client.Disconnect(100)
+	tp := client.Publish("awakari/test/device0", 1, false, "hello")
+	require.True(t, tp.WaitTimeout(cfg.Timeout))
+	require.NoError(t, tp.Error())
+
+	select {
+	case evt := <-rec.evts:
+		assert.Equal(t, "awakari/test/device0", evt.Source)
+		assert.Equal(t, "hello", evt.GetTextData())
+	case <-time.After(cfg.Timeout):
+		t.Fatal("timeout waiting for the event")
+	}
+}
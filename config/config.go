@@ -16,11 +16,11 @@ type Config struct {
 		Writer WriterConfig
 		Events EventsConfig
 		TgBot  TgBotConfig
-		Http   struct {
-			Port uint16 `envconfig:"API_HTTP_PORT" default:"8080"`
-		}
-		Auth  AuthConfig
-		Usage UsageConfig
+		Http   HttpConfig
+		Grpc   GrpcConfig
+		Auth   AuthConfig
+		Usage  UsageConfig
+		Mqtt   MqttConfig
 	}
 	Db  DbConfig
 	Log struct {
@@ -28,6 +28,53 @@ type Config struct {
 	}
 }
 
+type HttpConfig struct {
+	Port      uint16 `envconfig:"API_HTTP_PORT" default:"8080"`
+	Stream    HttpStreamConfig
+	WebSocket WebSocketConfig
+	Hook      HookConfig
+	Admin     HttpAdminConfig
+}
+
+type HttpAdminConfig struct {
+	// UserIds are the users allowed to manage the blacklist. Nobody is allowed when empty.
+	UserIds []string `envconfig:"API_HTTP_ADMIN_USER_IDS" default:""`
+	// GroupUserIds are the "<group id>:<user id>" pairs of the users allowed to manage only their group blacklist.
+	GroupUserIds []string `envconfig:"API_HTTP_ADMIN_GROUP_USER_IDS" default:""`
+}
+
+type HookConfig struct {
+	Signature HookSignatureConfig
+}
+
+type HookSignatureConfig struct {
+	// Tolerance is the max allowed difference between the signed request timestamp and the current time.
+	Tolerance   time.Duration `envconfig:"API_HTTP_HOOK_SIGNATURE_TOLERANCE" default:"5m" required:"true"`
+	BodySizeMax int64         `envconfig:"API_HTTP_HOOK_SIGNATURE_BODY_SIZE_MAX" default:"1048576" required:"true"`
+}
+
+type WebSocketConfig struct {
+	Origins      []string `envconfig:"API_HTTP_WEBSOCKET_ORIGINS" default:"https://awakari.com" required:"true"`
+	FrameSizeMax int64    `envconfig:"API_HTTP_WEBSOCKET_FRAME_SIZE_MAX" default:"1048576" required:"true"`
+}
+
+type HttpStreamConfig struct {
+	BatchSize   uint32 `envconfig:"API_HTTP_STREAM_BATCH_SIZE" default:"100" required:"true"`
+	LineSizeMax uint32 `envconfig:"API_HTTP_STREAM_LINE_SIZE_MAX" default:"1048576" required:"true"`
+}
+
+type GrpcConfig struct {
+	Port   uint16 `envconfig:"API_GRPC_PORT" default:"50051"`
+	Stream StreamConfig
+}
+
+type StreamConfig struct {
+	ChunkSize     uint32        `envconfig:"API_GRPC_STREAM_CHUNK_SIZE" default:"100" required:"true"`
+	FlushInterval time.Duration `envconfig:"API_GRPC_STREAM_FLUSH_INTERVAL" default:"100ms" required:"true"`
+	Backoff       time.Duration `envconfig:"API_GRPC_STREAM_BACKOFF" default:"100ms" required:"true"`
+	RetryCount    uint32        `envconfig:"API_GRPC_STREAM_RETRY_COUNT" default:"10" required:"true"`
+}
+
 type FeedsConfig struct {
 	Uri string `envconfig:"API_SOURCE_FEEDS_URI" default:"source-feeds:50051" required:"true"`
 }
@@ -46,7 +93,31 @@ type ActivityPubConfig struct {
 }
 
 type WriterConfig struct {
-	Internal WriterInternalConfig
+	Event     WriterEventConfig
+	Internal  WriterInternalConfig
+	Reserved  WriterReservedConfig
+	Blacklist WriterBlacklistConfig
+}
+
+type WriterEventConfig struct {
+	// Lenient enables filling the missing event id and spec version instead of rejecting the event published via any
+	// transport. Enabled by default for one release to let the publishers fix the events, will be disabled by default
+	// next.
+	Lenient bool `envconfig:"API_WRITER_EVENT_LENIENT" default:"true" required:"true"`
+}
+
+type WriterBlacklistConfig struct {
+	// Tag is the boolean attribute set to the event matching the blacklist rule with the tag action.
+	// Should be reserved, so the regular publishers can't set it.
+	Tag string `envconfig:"API_WRITER_BLACKLIST_TAG" default:"awkflagged" required:"true"`
+}
+
+type WriterReservedConfig struct {
+	// Attributes are the names or the prefixes ending with "*" of the attributes the regular publishers can't set.
+	// The internal writer attribute name is always reserved.
+	Attributes []string `envconfig:"API_WRITER_RESERVED_ATTRIBUTES" default:"awk*,awakari*" required:"true"`
+	// Reject the event with the reserved attribute instead of stripping the attribute.
+	Reject bool `envconfig:"API_WRITER_RESERVED_REJECT" default:"false" required:"true"`
 }
 
 type EventsConfig struct {
@@ -59,7 +130,9 @@ type EventsConfig struct {
 		IdleTimeout time.Duration `envconfig:"API_EVENTS_CONN_IDLE_TIMEOUT" default:"15m" required:"true"`
 	}
 	Topic string `envconfig:"API_EVENTS_TOPIC" default:"published" required:"true"`
-	Limit uint32 `envconfig:"API_EVENTS_LIMIT" default:"100000" required:"true"`
+	// QuarantineTopic receives the events matching the blacklist rules with the quarantine action, for the review.
+	QuarantineTopic string `envconfig:"API_EVENTS_QUARANTINE_TOPIC" default:"quarantined" required:"true"`
+	Limit           uint32 `envconfig:"API_EVENTS_LIMIT" default:"100000" required:"true"`
 }
 
 type WriterInternalConfig struct {
@@ -87,6 +160,22 @@ type UsageConfig struct {
 	}
 }
 
+type MqttConfig struct {
+	Enabled  bool          `envconfig:"API_MQTT_ENABLED" default:"false" required:"true"`
+	Uri      string        `envconfig:"API_MQTT_URI" default:"tcp://mqtt:1883" required:"true"`
+	ClientId string        `envconfig:"API_MQTT_CLIENT_ID" default:"pub" required:"true"`
+	UserName string        `envconfig:"API_MQTT_USERNAME" default:""`
+	Password string        `envconfig:"API_MQTT_PASSWORD" default:""`
+	Topics   []string      `envconfig:"API_MQTT_TOPICS" default:"#" required:"true"`
+	Qos      byte          `envconfig:"API_MQTT_QOS" default:"1" required:"true"`
+	Timeout  time.Duration `envconfig:"API_MQTT_TIMEOUT" default:"10s" required:"true"`
+	Event    struct {
+		Type string `envconfig:"API_MQTT_EVENT_TYPE" default:"com_awakari_mqtt_v1" required:"true"`
+	}
+	GroupId string `envconfig:"API_MQTT_GROUP_ID" default:"default" required:"true"`
+	UserId  string `envconfig:"API_MQTT_USER_ID" default:""`
+}
+
 type DbConfig struct {
 	Uri      string `envconfig:"DB_URI" default:"mongodb://localhost:27017/?retryWrites=true&w=majority" required:"true"`
 	Name     string `envconfig:"DB_NAME" default:"pub" required:"true"`
@@ -95,6 +184,27 @@ type DbConfig struct {
 	Table    struct {
 		Blacklist struct {
 			Name string `envconfig:"DB_TABLE_NAME_BLACKLIST" default:"blacklist" required:"true"`
+			Sync BlacklistSyncConfig
+		}
+		Hooks struct {
+			Name string `envconfig:"DB_TABLE_NAME_HOOKS" default:"hooks" required:"true"`
+		}
+		Secrets struct {
+			Name string `envconfig:"DB_TABLE_NAME_SECRETS" default:"secrets" required:"true"`
+			// Key is the hex-encoded 32 bytes AES key to encrypt the webhook signing secrets at rest. The signed webhooks
+			// are disabled when empty.
+			Key string `envconfig:"DB_TABLE_SECRETS_KEY" default:""`
+		}
+		Replays struct {
+			Name string `envconfig:"DB_TABLE_NAME_REPLAYS" default:"replays" required:"true"`
+		}
+		Schemas struct {
+			Name  string `envconfig:"DB_TABLE_NAME_SCHEMAS" default:"schemas" required:"true"`
+			Cache struct {
+				Ttl time.Duration `envconfig:"DB_TABLE_SCHEMAS_CACHE_TTL" default:"1m" required:"true"`
+				// Size is the max count of the cached entries, the least recently used are evicted first.
+				Size uint32 `envconfig:"DB_TABLE_SCHEMAS_CACHE_SIZE" default:"10000" required:"true"`
+			}
 		}
 	}
 	Tls struct {
@@ -103,6 +213,14 @@ type DbConfig struct {
 	}
 }
 
+type BlacklistSyncConfig struct {
+	// Interval is the period of the full blacklist reload in addition to the watching the changes.
+	Interval time.Duration `envconfig:"DB_TABLE_BLACKLIST_SYNC_INTERVAL" default:"5m" required:"true"`
+	// Backoff is the initial delay before the watching is restarted after a failure, doubled on every consecutive
+	// failure up to the Interval.
+	Backoff time.Duration `envconfig:"DB_TABLE_BLACKLIST_SYNC_BACKOFF" default:"1s" required:"true"`
+}
+
 func NewConfigFromEnv() (cfg Config, err error) {
 	err = envconfig.Process("", &cfg)
 	return
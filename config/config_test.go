@@ -14,4 +14,5 @@ func TestConfig(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, uint16(8080), cfg.Api.Http.Port)
 	assert.Equal(t, 4, cfg.Log.Level)
+	assert.True(t, cfg.Api.Writer.Event.Lenient)
 }
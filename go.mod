@@ -5,10 +5,13 @@ go 1.23
 require (
 	github.com/bytedance/sonic v1.12.6
 	github.com/cloudevents/sdk-go/binding/format/protobuf/v2 v2.15.2
+	github.com/eclipse/paho.mqtt.golang v1.5.0
 	github.com/gin-gonic/gin v1.10.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/porfirion/trie v1.0.0
 	github.com/processout/grpc-go-pool v1.2.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/segmentio/ksuid v1.0.4
 	github.com/stretchr/testify v1.10.0
 	go.mongodb.org/mongo-driver v1.17.1
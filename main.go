@@ -14,8 +14,12 @@ import (
 	grpcSrcTg "github.com/awakari/pub/api/grpc/source/telegram"
 	"github.com/awakari/pub/api/grpc/tgbot"
 	auth2 "github.com/awakari/pub/api/http/auth"
+	httpBlacklist "github.com/awakari/pub/api/http/blacklist"
+	httpHook "github.com/awakari/pub/api/http/hook"
 	v2 "github.com/awakari/pub/api/http/pub"
 	httpSrc "github.com/awakari/pub/api/http/pub/src"
+	httpSchema "github.com/awakari/pub/api/http/schema"
+	"github.com/awakari/pub/api/mqtt"
 	"github.com/awakari/pub/config"
 	"github.com/awakari/pub/model"
 	"github.com/awakari/pub/storage"
@@ -162,32 +166,53 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("failed to initialize the blacklist storage: %s", err))
 	}
-	var cursor string
-	var page []model.BlacklistEntry
-	blacklist := model.NewPrefixes[model.BlacklistValue]()
-	for {
-		page, err = stor.GetPage(context.TODO(), 100, cursor)
-		if err != nil {
-			panic(err)
-		}
-		if len(page) == 0 {
-			break
-		}
-		cursor = page[len(page)-1].Prefix
-		for _, e := range page {
-			_ = blacklist.Put(context.TODO(), e.Prefix, e.Value)
-		}
+	defer stor.Close()
+	blacklists := model.NewBlacklistScopes()
+	syncBlacklist := storage.NewBlacklistSync(stor, blacklists, cfg.Db.Table.Blacklist.Sync, log)
+	err = syncBlacklist.Resync(context.TODO())
+	if err != nil {
+		panic(err)
 	}
-	stor.Close()
+	go syncBlacklist.Run(context.Background())
 	log.Info("loaded the blacklist")
+	policyBlacklist := model.NewBlacklistPolicy(blacklists, cfg.Api.Writer.Blacklist.Tag, log)
+	handlerBlacklist := httpBlacklist.NewHandler(stor, blacklists)
+
+	// init hooks
+	storHooks, err := storage.NewHooks(context.TODO(), cfg.Db)
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize the hooks storage: %s", err))
+	}
+	defer storHooks.Close()
+	storSecrets, err := storage.NewSecrets(context.TODO(), cfg.Db)
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize the webhook secrets storage: %s", err))
+	}
+	defer storSecrets.Close()
+	storReplays, err := storage.NewReplays(context.TODO(), cfg.Db)
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize the webhook replays storage: %s", err))
+	}
+	defer storReplays.Close()
+	handlerHook := httpHook.NewHandler(storHooks, storSecrets)
+
+	// init schemas
+	storSchemasDb, err := storage.NewSchemas(context.TODO(), cfg.Db)
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize the schemas storage: %s", err))
+	}
+	storSchemas := storage.NewSchemasCache(storSchemasDb, cfg.Db.Table.Schemas.Cache.Ttl, cfg.Db.Table.Schemas.Cache.Size)
+	defer storSchemas.Close()
+	// the registration reads the latest version bypassing the cache, otherwise the next version number may be stale
+	handlerSchema := httpSchema.NewHandler(storSchemasDb)
 
-	handlerPub := v2.NewHandler(
-		publisher.NewService(clientEvts, svcPermits, cfg.Api.Events),
-		cfg.Api.Writer.Internal,
-		blacklist,
-		log,
+	reserved := model.NewReservedAttributes(
+		append(cfg.Api.Writer.Reserved.Attributes, cfg.Api.Writer.Internal.Name),
+		cfg.Api.Writer.Reserved.Reject,
 	)
-	handlerSrc := httpSrc.NewHandler(svcSrcFeeds, svcSrcSites, svcSrcTg, svcSrcAp, svcTgBot, svcLimits, svcPermits)
+	svcPub := publisher.NewService(clientEvts, svcPermits, cfg.Api.Events)
+	ingest := publisher.NewIngest(svcPub, reserved, policyBlacklist, storSchemas, cfg.Api.Writer.Event.Lenient, log)
+	handlerPub := v2.NewHandler(svcPub, cfg.Api.Writer.Internal, ingest, storHooks, cfg.Api.Http, log)
 
 	connAuth, err := grpc.NewClient(cfg.Api.Auth.Uri, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
@@ -196,18 +221,41 @@ func main() {
 	clientAuth := grpcAuth.NewServiceClient(connAuth)
 	svcAuth := grpcAuth.NewService(clientAuth)
 	svcAuth = grpcAuth.NewLogging(svcAuth, log)
+
+	log.Info(fmt.Sprintf("starting to listen the grpc API @ port #%d...", cfg.Api.Grpc.Port))
+	go func() {
+		errGrpc := publisher.Serve(cfg.Api.Grpc.Port, publisher.NewController(svcPub, cfg.Api.Writer.Internal, svcAuth, ingest, cfg.Api.Grpc.Stream, log))
+		if errGrpc != nil {
+			panic(errGrpc)
+		}
+	}()
+	if cfg.Api.Mqtt.Enabled {
+		var bridgeMqtt mqtt.Bridge
+		bridgeMqtt, err = mqtt.NewBridge(cfg.Api.Mqtt, svcPub, ingest, log)
+		if err != nil {
+			panic(err)
+		}
+		defer bridgeMqtt.Close()
+		log.Info(fmt.Sprintf("started the MQTT bridge, topics: %+v", cfg.Api.Mqtt.Topics))
+	}
+
+	handlerSrc := httpSrc.NewHandler(svcSrcFeeds, svcSrcSites, svcSrcTg, svcSrcAp, svcTgBot, svcLimits, svcPermits)
+
 	handlerAuth := auth2.Handler{
 		Svc: svcAuth,
 	}
 
 	authSrcTg := auth2.NewTelegramValidator(svcSrcTg)
+	authAdmin := auth2.NewAdminValidator(cfg.Api.Http.Admin)
+	authHook := auth2.NewSignatureValidator(storHooks, storSecrets, storReplays, cfg.Api.Http.Hook.Signature, handlerAuth.Authorize)
 
 	// expose the profiling
 	//go func() {
 	//    _ = http.ListenAndServe("localhost:6060", nil)
 	//}()
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(auth2.StripQueryToken, gin.Logger(), gin.Recovery())
 	r.
 		Group("/v1/src/:type").
 		POST("", handlerAuth.Authorize, handlerSrc.Create).
@@ -217,10 +265,30 @@ func main() {
 	r.
 		Group("/v1/tg", handlerAuth.Authorize).
 		POST("", authSrcTg.ClientLogin)
+	r.
+		Group("/v1/hook", handlerAuth.Authorize).
+		POST("", handlerHook.Create).
+		GET("", handlerHook.List).
+		GET("/:id", handlerHook.Read).
+		PUT("/secret", handlerHook.PutSecret).
+		DELETE("/:id", handlerHook.Delete)
+	r.
+		Group("/v1/schema", handlerAuth.Authorize).
+		POST("", authAdmin.AuthorizeGlobal, handlerSchema.Register).
+		GET("/:type", handlerSchema.Read)
+	r.
+		Group("/v1/admin/blacklist", handlerAuth.Authorize, authAdmin.Authorize).
+		POST("", handlerBlacklist.Create).
+		GET("", handlerBlacklist.List).
+		GET("/entry", handlerBlacklist.Read).
+		DELETE("", handlerBlacklist.Delete)
+	r.POST("/v1/hook/:id", authHook.Authorize, handlerPub.WriteHook)
+	r.GET("/v1/ws", handlerAuth.AuthorizeWebSocket, handlerPub.WriteWebSocket)
 	r.
 		Group("/v1", handlerAuth.Authorize).
 		POST("", handlerPub.Write).
 		POST("/batch", handlerPub.WriteBatch).
+		POST("/stream", handlerPub.WriteStream).
 		POST("/internal", handlerPub.WriteInternal)
 	err = r.Run(fmt.Sprintf(":%d", cfg.Api.Http.Port))
 	if err != nil {
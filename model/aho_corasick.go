@@ -0,0 +1,88 @@
+package model
+
+// ahoCorasick finds all the occurrences of many patterns in a single pass over the input.
+type ahoCorasick struct {
+	nodes []acNode
+}
+
+type acNode struct {
+	next map[byte]int
+	fail int
+	// out are the ids of the patterns ending at this node, including the ones reachable via the failure links.
+	out []int
+}
+
+func newAhoCorasick(patterns []string) (ac *ahoCorasick) {
+	ac = &ahoCorasick{
+		nodes: []acNode{
+			{},
+		},
+	}
+	for id, p := range patterns {
+		var n int
+		for i := 0; i < len(p); i++ {
+			next, ok := ac.nodes[n].next[p[i]]
+			if !ok {
+				next = len(ac.nodes)
+				ac.nodes = append(ac.nodes, acNode{})
+				if ac.nodes[n].next == nil {
+					ac.nodes[n].next = make(map[byte]int)
+				}
+				ac.nodes[n].next[p[i]] = next
+			}
+			n = next
+		}
+		ac.nodes[n].out = append(ac.nodes[n].out, id)
+	}
+	// breadth-first to set the failure links, the parent's link is always ready before the child's
+	queue := make([]int, 0, len(ac.nodes))
+	for _, child := range ac.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for b, child := range ac.nodes[n].next {
+			queue = append(queue, child)
+			f := ac.nodes[n].fail
+			for {
+				if next, ok := ac.nodes[f].next[b]; ok {
+					ac.nodes[child].fail = next
+					break
+				}
+				if f == 0 {
+					break
+				}
+				f = ac.nodes[f].fail
+			}
+			fail := ac.nodes[child].fail
+			ac.nodes[child].out = append(ac.nodes[child].out, ac.nodes[fail].out...)
+		}
+	}
+	return
+}
+
+// find invokes the consumer with the pattern id and the end position of every occurrence until the consumer
+// returns false.
+func (ac *ahoCorasick) find(input string, consume func(id, end int) bool) {
+	var n int
+	for i := 0; i < len(input); i++ {
+		b := input[i]
+		for {
+			if next, ok := ac.nodes[n].next[b]; ok {
+				n = next
+				break
+			}
+			if n == 0 {
+				break
+			}
+			n = ac.nodes[n].fail
+		}
+		for _, id := range ac.nodes[n].out {
+			if !consume(id, i+1) {
+				return
+			}
+		}
+	}
+	return
+}
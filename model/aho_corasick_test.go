@@ -0,0 +1,44 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAhoCorasick_Find(t *testing.T) {
+	ac := newAhoCorasick([]string{"he", "she", "his", "hers", "spam"})
+	cases := map[string]struct {
+		in  string
+		out [][2]int
+	}{
+		"empty": {},
+		"none": {
+			in: "nothing",
+		},
+		"overlapping": {
+			in: "ushers",
+			out: [][2]int{
+				{1, 4},
+				{0, 4},
+				{3, 6},
+			},
+		},
+		"repeated": {
+			in: "spamspam",
+			out: [][2]int{
+				{4, 4},
+				{4, 8},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var out [][2]int
+			ac.find(c.in, func(id, end int) bool {
+				out = append(out, [2]int{id, end})
+				return true
+			})
+			assert.Equal(t, c.out, out)
+		})
+	}
+}
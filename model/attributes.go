@@ -0,0 +1,29 @@
+package model
+
+import (
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+// SetPublisherAttributes overwrites the event attributes identifying the publisher and the publishing time.
+func SetPublisherAttributes(evt *pb.CloudEvent, groupId, userId string, t time.Time) {
+	if evt.Attributes == nil {
+		evt.Attributes = make(map[string]*pb.CloudEventAttributeValue)
+	}
+	evt.Attributes[KeyCeGroupId] = &pb.CloudEventAttributeValue{
+		Attr: &pb.CloudEventAttributeValue_CeString{
+			CeString: groupId,
+		},
+	}
+	evt.Attributes[KeyCeUserId] = &pb.CloudEventAttributeValue{
+		Attr: &pb.CloudEventAttributeValue_CeString{
+			CeString: userId,
+		},
+	}
+	evt.Attributes[KeyCePubTime] = &pb.CloudEventAttributeValue{
+		Attr: &pb.CloudEventAttributeValue_CeTimestamp{
+			CeTimestamp: timestamppb.New(t.UTC()),
+		},
+	}
+}
@@ -1,13 +1,772 @@
 package model
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BlacklistKind defines how the blacklist rule is matched against the "<attribute name>:<value>" string.
+type BlacklistKind string
+
+const (
+	// BlacklistKindPrefix matches the rule as the prefix of the whole string. The default kind.
+	BlacklistKindPrefix BlacklistKind = "prefix"
+	// BlacklistKindGlob matches the whole value by the pattern with "*" (any sequence) and "?" (any char) wildcards.
+	BlacklistKindGlob BlacklistKind = "glob"
+	// BlacklistKindRegex matches the value by the RE2 regular expression, use the anchors to match the whole value.
+	BlacklistKindRegex BlacklistKind = "regex"
+	// BlacklistKindHost matches the URL value host by the domain, including the subdomains.
+	BlacklistKindHost BlacklistKind = "host"
+	// BlacklistKindKeyword matches the keyword or phrase found anywhere in the event text data and text attributes.
+	// The rule is the keyword itself, without the attribute name.
+	BlacklistKindKeyword BlacklistKind = "keyword"
+)
 
 type BlacklistValue struct {
 	CreatedAt time.Time
 	Reason    string
+	Kind      BlacklistKind
+	// WholeWord requires the keyword to be surrounded by the non-word characters. Only for the keyword kind.
+	WholeWord bool
+	// MatchCase disables the case folding. Only for the keyword kind.
+	MatchCase bool
+	// NotBefore is the time since when the rule applies, zero to apply immediately.
+	NotBefore time.Time
+	// ExpiresAt is the time when the rule stops to apply and gets evicted, zero for the permanent rule.
+	ExpiresAt time.Time
+	// Action is applied to the matching event, BlacklistActionReject if empty.
+	Action BlacklistAction
+	// RateLimit is the count of the matching events per minute allowed by the throttle action. Counted by every
+	// replica separately, so the total allowed count grows with the replica count.
+	RateLimit uint32
+	// Allow makes the allowlist rule, overriding the less specific blacklist rules. Only for the prefix and host
+	// kinds.
+	Allow bool
+}
+
+// Active returns true if the rule applies at the specified time.
+func (v BlacklistValue) Active(t time.Time) bool {
+	return !t.Before(v.NotBefore) && (v.ExpiresAt.IsZero() || t.Before(v.ExpiresAt))
 }
 
 type BlacklistEntry struct {
+	// Id is the storage record id, set only by the storage.
+	Id string
+	// GroupId scopes the rule to the group members' events, the rule is global when empty.
+	GroupId string
+	// Prefix is the rule: the "<attribute name>:<prefix>" string for the prefix kind,
+	// "<attribute name>:<pattern or domain>" for the others.
 	Prefix string
 	Value  BlacklistValue
 }
+
+// BlacklistMatch describes the event attribute matched by a blacklist rule.
+type BlacklistMatch struct {
+	Prefix    string
+	Kind      BlacklistKind
+	Action    BlacklistAction
+	RateLimit uint32
+	EventId   string
+	AttrName  string
+	AttrValue string
+	// GroupId is set when the rule is scoped to the publisher's group.
+	GroupId string
+	// Trace describes the blacklist rules overridden by the allowlist rules, also when no rule matches in the end.
+	Trace []string
+}
+
+// BlacklistDecision is the outcome of matching the attribute value against the rules.
+type BlacklistDecision struct {
+	// Rule is the most specific rule matching the value, empty if none.
+	Rule  string
+	Value BlacklistValue
+	// Overridden are the blacklist rules matching the value, but overridden by the allowlist rule.
+	Overridden []string
+}
+
+// Blacklist matches the event attribute values against the rules of every kind.
+type Blacklist interface {
+	// Put adds or replaces the rule. Returns ErrInvalidBlacklistRule if the rule can't be compiled.
+	// The rule is evicted at its expiration time, the already expired rule is not added.
+	Put(ctx context.Context, rule string, v BlacklistValue) (err error)
+	// PutAll is the bulk Put, replacing the pattern and keyword snapshots once for all the rules. The invalid rules
+	// are skipped, the returned error joins their errors.
+	PutAll(ctx context.Context, rules map[string]BlacklistValue) (err error)
+	// Delete removes the rule. Does nothing if the rule is missing.
+	Delete(ctx context.Context, rule string) (err error)
+	// Decide returns the most specific rule matching the attribute value, either blacklist or allowlist one.
+	// The URL value is also matched in the canonical form.
+	Decide(ctx context.Context, attrName, attrValue string, isUrl bool) (d BlacklistDecision)
+	// Match returns the blacklist rule deciding on the attribute value, not found if the value is allowed.
+	Match(ctx context.Context, attrName, attrValue string, isUrl bool) (rule string, v BlacklistValue, found bool)
+	// MatchContent returns the first keyword rule found in the text.
+	MatchContent(ctx context.Context, text string) (rule string, v BlacklistValue, found bool)
+}
+
+var ErrInvalidBlacklistRule = errors.New("invalid blacklist rule")
+
+type blacklist struct {
+	lock     *sync.Mutex
+	rules    map[string]BlacklistValue
+	prefixes Prefixes[BlacklistValue]
+	// hosts are by the "<attribute name>:<reversed domain labels>." key, so the suffix match is the prefix match.
+	hosts    Prefixes[blacklistRule]
+	patterns *atomic.Pointer[map[string]*blacklistPatterns]
+	keywords *atomic.Pointer[blacklistKeywords]
+	// evictions are the pending expiration timers by the rule.
+	evictions map[string]*time.Timer
+}
+
+type blacklistRule struct {
+	rule string
+	v    BlacklistValue
+}
+
+// blacklistPatterns are the glob and regex rules of the same attribute. The filter is built lazily, so the bulk
+// loading doesn't rebuild it on every rule.
+type blacklistPatterns struct {
+	rules  []blacklistPattern
+	filter func() *blacklistFilter
+}
+
+type blacklistPattern struct {
+	blacklistRule
+	re *regexp.Regexp
+	// literal is the lowercase string contained by every value matching the expression, empty if there's no such.
+	literal string
+}
+
+// blacklistFilter selects the candidate patterns by their literals found in the value, so only these are evaluated.
+type blacklistFilter struct {
+	ac *ahoCorasick
+	// idxs are the pattern indices by the literal id.
+	idxs []int
+	// always are the indices of the patterns without the literal.
+	always []int
+}
+
+func NewBlacklist() Blacklist {
+	patterns := &atomic.Pointer[map[string]*blacklistPatterns]{}
+	patterns.Store(&map[string]*blacklistPatterns{})
+	keywords := &atomic.Pointer[blacklistKeywords]{}
+	keywords.Store(&blacklistKeywords{})
+	return blacklist{
+		lock:      &sync.Mutex{},
+		rules:     make(map[string]BlacklistValue),
+		prefixes:  NewPrefixes[BlacklistValue](),
+		hosts:     NewPrefixes[blacklistRule](),
+		patterns:  patterns,
+		keywords:  keywords,
+		evictions: make(map[string]*time.Timer),
+	}
+}
+
+// ValidateBlacklistRule checks the rule kind and that the rule compiles.
+func ValidateBlacklistRule(rule string, kind BlacklistKind) (err error) {
+	switch kind {
+	case "", BlacklistKindPrefix:
+		if rule == "" {
+			err = fmt.Errorf("%w: empty prefix", ErrInvalidBlacklistRule)
+		}
+	case BlacklistKindHost:
+		_, err = hostKey(rule)
+	case BlacklistKindGlob, BlacklistKindRegex:
+		_, _, err = compilePattern(rule, kind)
+	case BlacklistKindKeyword:
+		err = validateKeyword(rule)
+	default:
+		err = fmt.Errorf("%w: unknown kind %s", ErrInvalidBlacklistRule, kind)
+	}
+	return
+}
+
+// ValidateBlacklistValue checks the rule and every option of the value.
+func ValidateBlacklistValue(rule string, v BlacklistValue) (err error) {
+	err = ValidateBlacklistRule(rule, v.Kind)
+	if err == nil {
+		err = ValidateBlacklistSchedule(v.NotBefore, v.ExpiresAt)
+	}
+	if err == nil {
+		err = ValidateBlacklistAction(v.Action, v.RateLimit)
+	}
+	if err == nil && v.Allow {
+		switch {
+		case v.Kind != "" && v.Kind != BlacklistKindPrefix && v.Kind != BlacklistKindHost:
+			err = fmt.Errorf("%w: allowlist rule should be of the %s or %s kind", ErrInvalidBlacklistRule, BlacklistKindPrefix, BlacklistKindHost)
+		case v.Action != "" || v.RateLimit > 0:
+			err = fmt.Errorf("%w: allowlist rule can't have the action", ErrInvalidBlacklistRule)
+		}
+	}
+	return
+}
+
+// ValidateBlacklistSchedule checks the rule expires after it starts to apply. The zero times are not set.
+func ValidateBlacklistSchedule(notBefore, expiresAt time.Time) (err error) {
+	if !notBefore.IsZero() && !expiresAt.IsZero() && !expiresAt.After(notBefore) {
+		err = fmt.Errorf("%w: expires at %s, not after the start at %s", ErrInvalidBlacklistRule, expiresAt, notBefore)
+	}
+	return
+}
+
+func (b blacklist) Put(ctx context.Context, rule string, v BlacklistValue) (err error) {
+	return b.PutAll(ctx, map[string]BlacklistValue{
+		rule: v,
+	})
+}
+
+func (b blacklist) PutAll(ctx context.Context, rules map[string]BlacklistValue) (err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	patterns := make(map[string][]blacklistPattern)
+	var keywords []blacklistKeyword
+	// replaced are the pattern and keyword rules to drop from the current snapshots
+	replaced := make(map[string]bool)
+	for rule, v := range rules {
+		if v.Kind == "" {
+			v.Kind = BlacklistKindPrefix
+		}
+		errRule := ValidateBlacklistValue(rule, v)
+		if errRule != nil {
+			err = errors.Join(err, errRule)
+			continue
+		}
+		expired := !v.ExpiresAt.IsZero() && !v.ExpiresAt.After(now)
+		if prev, found := b.rules[rule]; found && (prev.Kind != v.Kind || expired) {
+			b.delete(ctx, rule, prev)
+		}
+		if expired {
+			continue
+		}
+		switch v.Kind {
+		case BlacklistKindPrefix:
+			errRule = b.prefixes.Put(ctx, rule, v)
+		case BlacklistKindHost:
+			k, _ := hostKey(rule)
+			errRule = b.hosts.Put(ctx, k, blacklistRule{
+				rule: rule,
+				v:    v,
+			})
+		case BlacklistKindKeyword:
+			keywords = append(keywords, newBlacklistKeyword(rule, v))
+			replaced[rule] = true
+		default:
+			attrName, re, _ := compilePattern(rule, v.Kind)
+			patterns[attrName] = append(patterns[attrName], blacklistPattern{
+				blacklistRule: blacklistRule{
+					rule: rule,
+					v:    v,
+				},
+				re:      re,
+				literal: requiredLiteral(re),
+			})
+			replaced[rule] = true
+		}
+		if errRule != nil {
+			err = errors.Join(err, errRule)
+			continue
+		}
+		b.rules[rule] = v
+		var ttl time.Duration
+		if !v.ExpiresAt.IsZero() {
+			ttl = v.ExpiresAt.Sub(now)
+		}
+		b.scheduleEviction(rule, ttl)
+	}
+	if len(patterns) > 0 {
+		b.replacePatterns(patterns, replaced)
+	}
+	if len(keywords) > 0 {
+		b.replaceKeywords(keywords, replaced)
+	}
+	return
+}
+
+// scheduleEviction replaces the pending eviction of the rule, if any, by the new one after the delay.
+// The non-positive delay means the rule doesn't expire.
+func (b blacklist) scheduleEviction(rule string, delay time.Duration) {
+	if prev, found := b.evictions[rule]; found {
+		prev.Stop()
+		delete(b.evictions, rule)
+	}
+	if delay <= 0 {
+		return
+	}
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		// the rule might be replaced or deleted meanwhile
+		if b.evictions[rule] == t {
+			b.delete(context.Background(), rule, b.rules[rule])
+		}
+	})
+	b.evictions[rule] = t
+}
+
+func (b blacklist) Delete(ctx context.Context, rule string) (err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if v, found := b.rules[rule]; found {
+		b.delete(ctx, rule, v)
+	}
+	return
+}
+
+func (b blacklist) delete(ctx context.Context, rule string, v BlacklistValue) {
+	switch v.Kind {
+	case BlacklistKindPrefix:
+		_ = b.prefixes.Delete(ctx, rule)
+	case BlacklistKindHost:
+		k, _ := hostKey(rule)
+		_ = b.hosts.Delete(ctx, k)
+	case BlacklistKindKeyword:
+		b.updateKeywords(rule, func(rules []blacklistKeyword) []blacklistKeyword {
+			return rules
+		})
+	default:
+		attrName, _, _ := strings.Cut(rule, ":")
+		b.updatePatterns(attrName, rule, func(rules []blacklistPattern) []blacklistPattern {
+			return rules
+		})
+	}
+	delete(b.rules, rule)
+	b.scheduleEviction(rule, 0)
+}
+
+// updatePatterns replaces the attribute patterns snapshot with the rules without the specified one and modified.
+func (b blacklist) updatePatterns(attrName, rule string, modify func(rules []blacklistPattern) []blacklistPattern) {
+	src := *b.patterns.Load()
+	dst := make(map[string]*blacklistPatterns, len(src)+1)
+	for k, v := range src {
+		dst[k] = v
+	}
+	var rules []blacklistPattern
+	if prev, found := src[attrName]; found {
+		rules = make([]blacklistPattern, 0, len(prev.rules)+1)
+		for _, p := range prev.rules {
+			if p.rule != rule {
+				rules = append(rules, p)
+			}
+		}
+	}
+	rules = modify(rules)
+	switch len(rules) {
+	case 0:
+		delete(dst, attrName)
+	default:
+		dst[attrName] = &blacklistPatterns{
+			rules:  rules,
+			filter: sync.OnceValue(newBlacklistFilter(rules)),
+		}
+	}
+	b.patterns.Store(&dst)
+}
+
+// replacePatterns replaces the patterns snapshot of every attribute having the added rules, without the replaced ones.
+func (b blacklist) replacePatterns(added map[string][]blacklistPattern, replaced map[string]bool) {
+	src := *b.patterns.Load()
+	dst := make(map[string]*blacklistPatterns, len(src)+len(added))
+	for k, v := range src {
+		dst[k] = v
+	}
+	for attrName, addedRules := range added {
+		var rules []blacklistPattern
+		if prev, found := src[attrName]; found {
+			rules = make([]blacklistPattern, 0, len(prev.rules)+len(addedRules))
+			for _, p := range prev.rules {
+				if !replaced[p.rule] {
+					rules = append(rules, p)
+				}
+			}
+		}
+		rules = append(rules, addedRules...)
+		dst[attrName] = &blacklistPatterns{
+			rules:  rules,
+			filter: sync.OnceValue(newBlacklistFilter(rules)),
+		}
+	}
+	b.patterns.Store(&dst)
+}
+
+func (b blacklist) Match(ctx context.Context, attrName, attrValue string, isUrl bool) (rule string, v BlacklistValue, found bool) {
+	d := b.Decide(ctx, attrName, attrValue, isUrl)
+	if d.Rule != "" && !d.Value.Allow {
+		rule, v, found = d.Rule, d.Value, true
+	}
+	return
+}
+
+// blacklistCandidate is the active prefix or host rule matching the value.
+type blacklistCandidate struct {
+	blacklistRule
+	// specificity is the length of the matched value part. The host rule is measured as the "<scheme>://<domain>"
+	// prefix, so it's comparable with the prefix rules.
+	specificity int
+}
+
+func (b blacklist) Decide(ctx context.Context, attrName, attrValue string, isUrl bool) (d BlacklistDecision) {
+	values := []string{
+		attrValue,
+	}
+	var canonical, host string
+	if isUrl {
+		canonical, host = CanonicalUrl(attrValue)
+		if canonical != "" && canonical != attrValue {
+			values = append(values, canonical)
+		}
+	}
+	now := time.Now()
+	var candidates []blacklistCandidate
+	for _, value := range values {
+		rules, vs, _ := b.prefixes.FindAllPrefixes(ctx, attrName+":"+value)
+		for i, rule := range rules {
+			if vs[i].Active(now) {
+				candidates = append(candidates, blacklistCandidate{
+					blacklistRule: blacklistRule{
+						rule: rule,
+						v:    vs[i],
+					},
+					specificity: len(rule) - len(attrName) - 1,
+				})
+			}
+		}
+	}
+	if host != "" {
+		scheme, _, _ := strings.Cut(canonical, "://")
+		_, rs, _ := b.hosts.FindAllPrefixes(ctx, attrName+":"+reverseLabels(host)+".")
+		for _, r := range rs {
+			if r.v.Active(now) {
+				k, _ := hostKey(r.rule)
+				candidates = append(candidates, blacklistCandidate{
+					blacklistRule: r,
+					// the key is "<attribute name>:<reversed domain>."
+					specificity: len(scheme) + len("://") + len(k) - len(attrName) - 2,
+				})
+			}
+		}
+	}
+	// the most specific one, the blacklist rule wins the tie
+	best := -1
+	for i, c := range candidates {
+		if best < 0 || c.specificity > candidates[best].specificity || (c.specificity == candidates[best].specificity && !c.v.Allow) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		d.Rule, d.Value = candidates[best].rule, candidates[best].v
+		if !d.Value.Allow {
+			return
+		}
+		for _, c := range candidates {
+			if !c.v.Allow && !slices.Contains(d.Overridden, c.rule) {
+				d.Overridden = append(d.Overridden, c.rule)
+			}
+		}
+	}
+	// the patterns are less specific than any allowlist rule
+	if patterns, ok := (*b.patterns.Load())[attrName]; ok {
+		f := patterns.filter()
+		for _, value := range values {
+			p, found := f.match(patterns.rules, value, now)
+			if found {
+				switch d.Rule {
+				case "":
+					d.Rule, d.Value = p.rule, p.v
+				default:
+					d.Overridden = append(d.Overridden, p.rule)
+				}
+				break
+			}
+		}
+	}
+	return
+}
+
+func newBlacklistFilter(rules []blacklistPattern) func() *blacklistFilter {
+	return func() (f *blacklistFilter) {
+		f = &blacklistFilter{}
+		var literals []string
+		for i, p := range rules {
+			switch p.literal {
+			case "":
+				f.always = append(f.always, i)
+			default:
+				literals = append(literals, p.literal)
+				f.idxs = append(f.idxs, i)
+			}
+		}
+		f.ac = newAhoCorasick(literals)
+		return
+	}
+}
+
+// match returns the first pattern active at the time and matching the value.
+func (f *blacklistFilter) match(rules []blacklistPattern, value string, t time.Time) (p blacklistPattern, found bool) {
+	candidates := slices.Clone(f.always)
+	f.ac.find(strings.ToLower(value), func(id, _ int) bool {
+		candidates = append(candidates, f.idxs[id])
+		return true
+	})
+	slices.Sort(candidates)
+	prev := -1
+	for _, i := range candidates {
+		if i != prev && rules[i].v.Active(t) && rules[i].re.MatchString(value) {
+			p, found = rules[i], true
+			return
+		}
+		prev = i
+	}
+	return
+}
+
+// requiredLiteral returns the longest lowercase literal contained by every match of the expression, empty if none.
+// The URL schemes are not counted, because every URL value contains these.
+func requiredLiteral(re *regexp.Regexp) (literal string) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err == nil {
+		literal = requiredSubLiteral(parsed.Simplify())
+	}
+	return
+}
+
+func requiredSubLiteral(re *syntax.Regexp) (literal string) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		literal = trimScheme(strings.ToLower(string(re.Rune)))
+	case syntax.OpCapture, syntax.OpPlus:
+		literal = requiredSubLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			literal = requiredSubLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// the adjacent literals join
+		var run string
+		for _, sub := range re.Sub {
+			var l string
+			switch sub.Op {
+			case syntax.OpLiteral:
+				run += strings.ToLower(string(sub.Rune))
+				l = trimScheme(run)
+			default:
+				run = ""
+				l = requiredSubLiteral(sub)
+			}
+			if len(l) > len(literal) {
+				literal = l
+			}
+		}
+	}
+	return
+}
+
+func compilePattern(rule string, kind BlacklistKind) (attrName string, re *regexp.Regexp, err error) {
+	attrName, pattern, ok := strings.Cut(rule, ":")
+	if !ok || attrName == "" {
+		err = fmt.Errorf("%w: %s, expected <attribute name>:<pattern>", ErrInvalidBlacklistRule, rule)
+		return
+	}
+	if kind == BlacklistKindGlob {
+		pattern = globToRegex(pattern)
+	}
+	re, err = regexp.Compile(pattern)
+	if err != nil {
+		err = fmt.Errorf("%w: %s, %s", ErrInvalidBlacklistRule, rule, err)
+	}
+	return
+}
+
+func trimScheme(literal string) string {
+	for _, scheme := range []string{"https://", "http://"} {
+		if i := strings.Index(literal, scheme); i >= 0 {
+			// keep the longest side
+			before, after := literal[:i], literal[i+len(scheme):]
+			literal = after
+			if len(before) > len(after) {
+				literal = before
+			}
+		}
+	}
+	return literal
+}
+
+func globToRegex(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func hostKey(rule string) (k string, err error) {
+	attrName, domain, ok := strings.Cut(rule, ":")
+	domain = strings.Trim(strings.ToLower(domain), ".")
+	switch {
+	case !ok || attrName == "":
+		err = fmt.Errorf("%w: %s, expected <attribute name>:<domain>", ErrInvalidBlacklistRule, rule)
+	case domain == "" || strings.ContainsAny(domain, "/:?#@ "):
+		err = fmt.Errorf("%w: %s, invalid domain", ErrInvalidBlacklistRule, rule)
+	default:
+		k = attrName + ":" + reverseLabels(domain) + "."
+	}
+	return
+}
+
+func reverseLabels(host string) string {
+	labels := strings.Split(host, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
+// CanonicalUrl returns the absolute URL with the lowercase scheme and host, https instead of http, without "www.",
+// the default port, the user info, the query and the fragment. Returns the empty strings if the value is not an
+// absolute URL.
+func CanonicalUrl(value string) (canonical, host string) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "http" {
+		scheme = "https"
+	}
+	host = strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	host = strings.TrimPrefix(host, "www.")
+	hostPort := host
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		hostPort += ":" + port
+	}
+	canonical = scheme + "://" + hostPort + u.EscapedPath()
+	return
+}
+
+// Reason describes the rejection by the match.
+func (m BlacklistMatch) Reason() (reason string) {
+	switch m.Kind {
+	case "":
+		reason = fmt.Sprintf("forbidden by %s: %s", BlacklistKindPrefix, m.Prefix)
+	case BlacklistKindKeyword:
+		reason = fmt.Sprintf("forbidden content in %s: %s", m.AttrName, m.Prefix)
+	default:
+		reason = fmt.Sprintf("forbidden by %s: %s", m.Kind, m.Prefix)
+	}
+	switch m.Action {
+	case BlacklistActionQuarantine:
+		reason = "quarantined for review, " + reason
+	case BlacklistActionThrottle:
+		reason = fmt.Sprintf("over the rate limit of %d per minute, %s", m.RateLimit, reason)
+	}
+	return
+}
+
+// Outcome describes what happened to the event held back by the match, for the logs.
+func (m BlacklistMatch) Outcome() (outcome string) {
+	switch m.Action {
+	case BlacklistActionQuarantine:
+		outcome = "quarantined"
+	case BlacklistActionThrottle:
+		outcome = "throttled"
+	default:
+		outcome = "rejected"
+	}
+	return
+}
+
+func (m *BlacklistMatch) setValue(v BlacklistValue) {
+	m.Kind = v.Kind
+	m.Action = v.Action
+	m.RateLimit = v.RateLimit
+}
+
+func (m BlacklistMatch) String() (s string) {
+	s = fmt.Sprintf("blacklist %s, id: %s, attribute: %s=%s", m.Reason(), m.EventId, m.AttrName, m.AttrValue)
+	if m.GroupId != "" {
+		s += ", group: " + m.GroupId
+	}
+	return
+}
+
+// FindBlacklistMatch checks the event source, type and string/URI attribute values against the blacklist rules, then
+// the event text data and text attributes against the keyword rules.
+func FindBlacklistMatch(ctx context.Context, blacklist Blacklist, evt *pb.CloudEvent) (m BlacklistMatch, found bool) {
+	m.EventId = evt.Id
+	match := func(attrName, attrValue string, isUrl bool) bool {
+		d := blacklist.Decide(ctx, attrName, attrValue, isUrl)
+		switch {
+		case d.Rule == "":
+		case d.Value.Allow:
+			if len(d.Overridden) > 0 {
+				m.Trace = append(m.Trace, fmt.Sprintf("%s=%s allowed by %s over %s", attrName, attrValue, d.Rule, strings.Join(d.Overridden, ", ")))
+			}
+		default:
+			found = true
+			m.Prefix = d.Rule
+			m.setValue(d.Value)
+			m.AttrName = attrName
+			m.AttrValue = attrValue
+		}
+		return found
+	}
+	if match("source", evt.Source, true) || match("type", evt.Type, false) {
+		return
+	}
+	for k, v := range evt.Attributes {
+		var attrValue string
+		var isUrl bool
+		switch vt := v.Attr.(type) {
+		case *pb.CloudEventAttributeValue_CeString:
+			attrValue = vt.CeString
+		case *pb.CloudEventAttributeValue_CeUri:
+			attrValue = vt.CeUri
+			isUrl = true
+		case *pb.CloudEventAttributeValue_CeUriRef:
+			attrValue = vt.CeUriRef
+			isUrl = true
+		}
+		if attrValue != "" && match(k, attrValue, isUrl) {
+			return
+		}
+	}
+	matchContent := func(attrName, text string) bool {
+		var v BlacklistValue
+		m.Prefix, v, found = blacklist.MatchContent(ctx, text)
+		if found {
+			m.setValue(v)
+			m.AttrName = attrName
+			// don't expose the whole text
+			m.AttrValue = ""
+		}
+		return found
+	}
+	if txt := evt.GetTextData(); txt != "" && matchContent(AttrTextData, txt) {
+		return
+	}
+	for _, k := range BlacklistContentAttrs {
+		if txt := evt.Attributes[k].GetCeString(); txt != "" && matchContent(k, txt) {
+			return
+		}
+	}
+	return
+}
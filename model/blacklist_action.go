@@ -0,0 +1,165 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlacklistAction defines what happens to the event matching the blacklist rule.
+type BlacklistAction string
+
+const (
+	// BlacklistActionReject drops the event. The default action.
+	BlacklistActionReject BlacklistAction = "reject"
+	// BlacklistActionQuarantine publishes the event to the review topic instead of the regular one.
+	BlacklistActionQuarantine BlacklistAction = "quarantine"
+	// BlacklistActionTag sets the flag attribute and publishes the event as usual.
+	BlacklistActionTag BlacklistAction = "tag"
+	// BlacklistActionThrottle publishes the matching events until the rule rate limit is reached, drops the rest.
+	BlacklistActionThrottle BlacklistAction = "throttle"
+)
+
+// BlacklistVerdict is the outcome of the blacklist rule action for the event.
+type BlacklistVerdict int
+
+const (
+	// BlacklistVerdictPass means no rule matches or the throttled event is within the rate limit.
+	BlacklistVerdictPass BlacklistVerdict = iota
+	// BlacklistVerdictTag means the event is flagged and should be published as usual.
+	BlacklistVerdictTag
+	// BlacklistVerdictQuarantine means the event should be published to the review topic.
+	BlacklistVerdictQuarantine
+	// BlacklistVerdictReject means the event should be dropped.
+	BlacklistVerdictReject
+)
+
+// ValidateBlacklistAction checks the action is known and the rate limit is set for the throttle action only.
+func ValidateBlacklistAction(action BlacklistAction, rateLimit uint32) (err error) {
+	switch action {
+	case "", BlacklistActionReject, BlacklistActionQuarantine, BlacklistActionTag:
+		if rateLimit > 0 {
+			err = fmt.Errorf("%w: rate limit is only for the %s action", ErrInvalidBlacklistRule, BlacklistActionThrottle)
+		}
+	case BlacklistActionThrottle:
+		if rateLimit == 0 {
+			err = fmt.Errorf("%w: missing rate limit for the %s action", ErrInvalidBlacklistRule, action)
+		}
+	default:
+		err = fmt.Errorf("%w: unknown action %s", ErrInvalidBlacklistRule, action)
+	}
+	return
+}
+
+// BlacklistPolicy applies the actions of the blacklist rules to the events.
+type BlacklistPolicy interface {
+	// Apply finds the rule matching the event and applies its action. Sets the tag attribute of the event in place.
+	// The global rules are checked first, then the rules of the publisher's group.
+	Apply(ctx context.Context, groupId string, evt *pb.CloudEvent) (m BlacklistMatch, verdict BlacklistVerdict)
+}
+
+type blacklistPolicy struct {
+	scopes BlacklistScopes
+	tag    string
+	log    *slog.Logger
+	lock   *sync.Mutex
+	// window is the current minute of the throttle counts. The counts are not shared between the replicas.
+	window *time.Time
+	counts map[string]uint32
+}
+
+// NewBlacklistPolicy returns the policy setting the boolean tag attribute for the tag action. Logs the allowlist
+// overrides of the blacklist rules.
+func NewBlacklistPolicy(scopes BlacklistScopes, tag string, log *slog.Logger) BlacklistPolicy {
+	return blacklistPolicy{
+		scopes: scopes,
+		tag:    tag,
+		log:    log,
+		lock:   &sync.Mutex{},
+		window: &time.Time{},
+		counts: make(map[string]uint32),
+	}
+}
+
+func (bp blacklistPolicy) Apply(ctx context.Context, groupId string, evt *pb.CloudEvent) (m BlacklistMatch, verdict BlacklistVerdict) {
+	global, _ := bp.scopes.Find("")
+	m, found := FindBlacklistMatch(ctx, global, evt)
+	if !found && groupId != "" {
+		if group, ok := bp.scopes.Find(groupId); ok {
+			trace := m.Trace
+			m, found = FindBlacklistMatch(ctx, group, evt)
+			m.Trace = append(trace, m.Trace...)
+			if found {
+				m.GroupId = groupId
+			}
+		}
+	}
+	if len(m.Trace) > 0 {
+		bp.log.Info(fmt.Sprintf("blacklist decision for the event %s: %s", evt.Id, strings.Join(m.Trace, "; ")))
+	}
+	if !found {
+		return
+	}
+	switch m.Action {
+	case BlacklistActionTag:
+		if evt.Attributes == nil {
+			evt.Attributes = make(map[string]*pb.CloudEventAttributeValue)
+		}
+		evt.Attributes[bp.tag] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeBoolean{
+				CeBoolean: true,
+			},
+		}
+		verdict = BlacklistVerdictTag
+	case BlacklistActionQuarantine:
+		verdict = BlacklistVerdictQuarantine
+	case BlacklistActionThrottle:
+		if !bp.allow(m.GroupId+":"+m.Prefix, m.RateLimit) {
+			verdict = BlacklistVerdictReject
+		}
+	default:
+		verdict = BlacklistVerdictReject
+	}
+	return
+}
+
+// allow counts the event against the rule rate limit in the current minute window. The rule key includes the group id.
+func (bp blacklistPolicy) allow(rule string, limit uint32) (ok bool) {
+	window := time.Now().Truncate(time.Minute)
+	bp.lock.Lock()
+	defer bp.lock.Unlock()
+	if !window.Equal(*bp.window) {
+		*bp.window = window
+		clear(bp.counts)
+	}
+	if bp.counts[rule] < limit {
+		bp.counts[rule]++
+		ok = true
+	}
+	return
+}
+
+// FilterBlacklisted applies the blacklist policy to the batch. Returns the events to publish as usual, the events to
+// quarantine and the matches of both the quarantined and rejected events by the source batch index.
+func FilterBlacklisted(ctx context.Context, policy BlacklistPolicy, groupId string, evts []*pb.CloudEvent) (dst, quarantined []*pb.CloudEvent, filtered map[int]BlacklistMatch) {
+	dst = make([]*pb.CloudEvent, 0, len(evts))
+	for i, evt := range evts {
+		m, verdict := policy.Apply(ctx, groupId, evt)
+		switch verdict {
+		case BlacklistVerdictPass, BlacklistVerdictTag:
+			dst = append(dst, evt)
+			continue
+		case BlacklistVerdictQuarantine:
+			quarantined = append(quarantined, evt)
+		}
+		if filtered == nil {
+			filtered = make(map[int]BlacklistMatch)
+		}
+		filtered[i] = m
+	}
+	return
+}
@@ -0,0 +1,191 @@
+package model
+
+import (
+	"context"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+func TestValidateBlacklistAction(t *testing.T) {
+	cases := map[string]struct {
+		action    BlacklistAction
+		rateLimit uint32
+		err       error
+	}{
+		"default": {},
+		"reject": {
+			action: BlacklistActionReject,
+		},
+		"quarantine": {
+			action: BlacklistActionQuarantine,
+		},
+		"tag": {
+			action: BlacklistActionTag,
+		},
+		"throttle": {
+			action:    BlacklistActionThrottle,
+			rateLimit: 10,
+		},
+		"throttle without rate limit": {
+			action: BlacklistActionThrottle,
+			err:    ErrInvalidBlacklistRule,
+		},
+		"rate limit for other action": {
+			action:    BlacklistActionTag,
+			rateLimit: 10,
+			err:       ErrInvalidBlacklistRule,
+		},
+		"unknown": {
+			action: "ban",
+			err:    ErrInvalidBlacklistRule,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err := ValidateBlacklistAction(c.action, c.rateLimit)
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func newBlacklistPolicyTest(t *testing.T) BlacklistPolicy {
+	scopes := NewBlacklistScopes()
+	blacklist := scopes.Get("")
+	for rule, v := range map[string]BlacklistValue{
+		"source:https://spam.example": {},
+		"source:https://review.example": {
+			Action: BlacklistActionQuarantine,
+		},
+		"source:https://flag.example": {
+			Action: BlacklistActionTag,
+		},
+		"source:https://noisy.example": {
+			Action:    BlacklistActionThrottle,
+			RateLimit: 2,
+		},
+	} {
+		require.Nil(t, blacklist.Put(context.TODO(), rule, v))
+	}
+	for _, groupId := range []string{"group0", "group1"} {
+		require.Nil(t, scopes.Get(groupId).Put(context.TODO(), "source:https://chatty.example", BlacklistValue{
+			Action:    BlacklistActionThrottle,
+			RateLimit: 1,
+		}))
+	}
+	group := scopes.Get("group0")
+	require.Nil(t, group.Put(context.TODO(), "source:https://flag.example/group", BlacklistValue{
+		Allow: true,
+	}))
+	require.Nil(t, group.Put(context.TODO(), "source:https://example.com/group", BlacklistValue{}))
+	return NewBlacklistPolicy(scopes, "awkflagged", slog.Default())
+}
+
+func TestBlacklistPolicy_Apply(t *testing.T) {
+	policy := newBlacklistPolicyTest(t)
+	cases := map[string]struct {
+		groupId string
+		src     string
+		verdict BlacklistVerdict
+		tagged  bool
+		reason  string
+	}{
+		"pass": {
+			src: "https://example.com",
+		},
+		"reject": {
+			src:     "https://spam.example/feed",
+			verdict: BlacklistVerdictReject,
+			reason:  "forbidden by prefix: source:https://spam.example",
+		},
+		"quarantine": {
+			src:     "https://review.example/feed",
+			verdict: BlacklistVerdictQuarantine,
+			reason:  "quarantined for review, forbidden by prefix: source:https://review.example",
+		},
+		"tag": {
+			src:     "https://flag.example/feed",
+			verdict: BlacklistVerdictTag,
+			tagged:  true,
+		},
+		"group": {
+			groupId: "group0",
+			src:     "https://example.com/group/feed",
+			verdict: BlacklistVerdictReject,
+			reason:  "forbidden by prefix: source:https://example.com/group",
+		},
+		"other group": {
+			groupId: "group1",
+			src:     "https://example.com/group/feed",
+		},
+		"group allow doesn't override global": {
+			groupId: "group0",
+			src:     "https://flag.example/group/feed",
+			verdict: BlacklistVerdictTag,
+			tagged:  true,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			evt := newBlacklistTestEvent("1", c.src, "com_example", nil)
+			m, verdict := policy.Apply(context.TODO(), c.groupId, evt)
+			assert.Equal(t, c.verdict, verdict)
+			assert.Equal(t, c.tagged, evt.Attributes["awkflagged"].GetCeBoolean())
+			if c.reason != "" {
+				assert.Equal(t, c.reason, m.Reason())
+			}
+		})
+	}
+}
+
+func TestBlacklistPolicy_Apply_Throttle(t *testing.T) {
+	policy := newBlacklistPolicyTest(t)
+	var verdicts []BlacklistVerdict
+	var m BlacklistMatch
+	for range 3 {
+		var verdict BlacklistVerdict
+		m, verdict = policy.Apply(context.TODO(), "", newBlacklistTestEvent("1", "https://noisy.example/feed", "com_example", nil))
+		verdicts = append(verdicts, verdict)
+	}
+	assert.Equal(t, []BlacklistVerdict{BlacklistVerdictPass, BlacklistVerdictPass, BlacklistVerdictReject}, verdicts)
+	assert.Equal(t, "over the rate limit of 2 per minute, forbidden by prefix: source:https://noisy.example", m.Reason())
+	// the other rules are not affected
+	_, verdict := policy.Apply(context.TODO(), "", newBlacklistTestEvent("2", "https://example.com", "com_example", nil))
+	assert.Equal(t, BlacklistVerdictPass, verdict)
+}
+
+func TestBlacklistPolicy_Apply_ThrottleGroup(t *testing.T) {
+	policy := newBlacklistPolicyTest(t)
+	evt := newBlacklistTestEvent("1", "https://chatty.example/feed", "com_example", nil)
+	var verdicts []BlacklistVerdict
+	var m BlacklistMatch
+	for _, groupId := range []string{"group0", "group0", "group1", "group2"} {
+		var verdict BlacklistVerdict
+		m, verdict = policy.Apply(context.TODO(), groupId, evt)
+		verdicts = append(verdicts, verdict)
+		if groupId == "group0" {
+			assert.Equal(t, "group0", m.GroupId)
+		}
+	}
+	// every group has its own count of the same rule
+	assert.Equal(t, []BlacklistVerdict{BlacklistVerdictPass, BlacklistVerdictReject, BlacklistVerdictPass, BlacklistVerdictPass}, verdicts)
+}
+
+func TestFilterBlacklisted_Actions(t *testing.T) {
+	policy := newBlacklistPolicyTest(t)
+	evts := []*pb.CloudEvent{
+		newBlacklistTestEvent("1", "https://example.com", "com_example", nil),
+		newBlacklistTestEvent("2", "https://review.example/feed", "com_example", nil),
+		newBlacklistTestEvent("3", "https://flag.example/feed", "com_example", nil),
+		newBlacklistTestEvent("4", "https://spam.example/feed", "com_example", nil),
+	}
+	out, quarantined, filtered := FilterBlacklisted(context.TODO(), policy, "", evts)
+	assert.Equal(t, []*pb.CloudEvent{evts[0], evts[2]}, out)
+	assert.Equal(t, []*pb.CloudEvent{evts[1]}, quarantined)
+	assert.Len(t, filtered, 2)
+	assert.Equal(t, BlacklistActionQuarantine, filtered[1].Action)
+	assert.Equal(t, "source:https://spam.example", filtered[3].Prefix)
+	assert.True(t, evts[2].Attributes["awkflagged"].GetCeBoolean())
+}
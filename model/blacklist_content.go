@@ -0,0 +1,163 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BlacklistContentAttrs are the event text attributes checked by the keyword rules in addition to the text data.
+var BlacklistContentAttrs = []string{
+	"title",
+	"summary",
+}
+
+// AttrTextData is the pseudo attribute name of the event text data in the blacklist matches.
+const AttrTextData = "text_data"
+
+// blacklistKeywords are the keyword rules. The matcher is built lazily, so the bulk loading doesn't rebuild it on
+// every rule.
+type blacklistKeywords struct {
+	rules   []blacklistKeyword
+	matcher func() *keywordMatcher
+}
+
+type blacklistKeyword struct {
+	blacklistRule
+	// keyword is lowercase unless the rule matches the case.
+	keyword string
+}
+
+// keywordMatcher finds the case-folded and the case-sensitive keywords in a single pass each.
+type keywordMatcher struct {
+	folded    *ahoCorasick
+	foldedIdx []int
+	exact     *ahoCorasick
+	exactIdx  []int
+}
+
+func validateKeyword(rule string) (err error) {
+	if strings.TrimSpace(rule) == "" {
+		err = fmt.Errorf("%w: empty keyword", ErrInvalidBlacklistRule)
+	}
+	return
+}
+
+func newBlacklistKeyword(rule string, v BlacklistValue) (k blacklistKeyword) {
+	k.rule = rule
+	k.v = v
+	k.keyword = rule
+	if !v.MatchCase {
+		k.keyword = strings.ToLower(rule)
+	}
+	return
+}
+
+// updateKeywords replaces the keywords snapshot with the rules without the specified one and modified.
+func (b blacklist) updateKeywords(rule string, modify func(rules []blacklistKeyword) []blacklistKeyword) {
+	src := b.keywords.Load()
+	rules := make([]blacklistKeyword, 0, len(src.rules)+1)
+	for _, k := range src.rules {
+		if k.rule != rule {
+			rules = append(rules, k)
+		}
+	}
+	rules = modify(rules)
+	b.keywords.Store(&blacklistKeywords{
+		rules:   rules,
+		matcher: sync.OnceValue(newKeywordMatcher(rules)),
+	})
+}
+
+// replaceKeywords replaces the keywords snapshot with the added rules, without the replaced ones.
+func (b blacklist) replaceKeywords(added []blacklistKeyword, replaced map[string]bool) {
+	src := b.keywords.Load()
+	rules := make([]blacklistKeyword, 0, len(src.rules)+len(added))
+	for _, k := range src.rules {
+		if !replaced[k.rule] {
+			rules = append(rules, k)
+		}
+	}
+	rules = append(rules, added...)
+	b.keywords.Store(&blacklistKeywords{
+		rules:   rules,
+		matcher: sync.OnceValue(newKeywordMatcher(rules)),
+	})
+}
+
+func newKeywordMatcher(rules []blacklistKeyword) func() *keywordMatcher {
+	return func() (m *keywordMatcher) {
+		m = &keywordMatcher{}
+		var folded, exact []string
+		for i, k := range rules {
+			switch k.v.MatchCase {
+			case true:
+				exact = append(exact, k.keyword)
+				m.exactIdx = append(m.exactIdx, i)
+			default:
+				folded = append(folded, k.keyword)
+				m.foldedIdx = append(m.foldedIdx, i)
+			}
+		}
+		m.folded = newAhoCorasick(folded)
+		m.exact = newAhoCorasick(exact)
+		return
+	}
+}
+
+func (b blacklist) MatchContent(ctx context.Context, text string) (rule string, v BlacklistValue, found bool) {
+	keywords := b.keywords.Load()
+	if len(keywords.rules) == 0 {
+		return
+	}
+	m := keywords.matcher()
+	now := time.Now()
+	var k blacklistKeyword
+	k, found = m.find(keywords.rules, strings.ToLower(text), m.folded, m.foldedIdx, now)
+	if !found {
+		k, found = m.find(keywords.rules, text, m.exact, m.exactIdx, now)
+	}
+	if found {
+		rule, v = k.rule, k.v
+	}
+	return
+}
+
+func (m *keywordMatcher) find(rules []blacklistKeyword, text string, ac *ahoCorasick, idxs []int, t time.Time) (k blacklistKeyword, found bool) {
+	ac.find(text, func(id, end int) bool {
+		candidate := rules[idxs[id]]
+		if !candidate.v.Active(t) {
+			return true
+		}
+		if !candidate.v.WholeWord || isWordBounded(text, end-len(candidate.keyword), end) {
+			k, found = candidate, true
+		}
+		return !found
+	})
+	return
+}
+
+// isWordBounded returns true if the text has no word characters right before the start and right after the end.
+func isWordBounded(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
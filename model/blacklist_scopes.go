@@ -0,0 +1,53 @@
+package model
+
+import "sync"
+
+// BlacklistScopes holds the global blacklist and the blacklists of the groups, so the group rules apply only to the
+// events published by the group members.
+type BlacklistScopes interface {
+	// Get returns the blacklist of the group, the global one when the group id is empty. Creates the missing one.
+	Get(groupId string) Blacklist
+	// Find returns the existing blacklist of the group, the global one when the group id is empty.
+	Find(groupId string) (b Blacklist, found bool)
+}
+
+type blacklistScopes struct {
+	global Blacklist
+	lock   *sync.RWMutex
+	groups map[string]Blacklist
+}
+
+func NewBlacklistScopes() BlacklistScopes {
+	return blacklistScopes{
+		global: NewBlacklist(),
+		lock:   &sync.RWMutex{},
+		groups: make(map[string]Blacklist),
+	}
+}
+
+func (bs blacklistScopes) Get(groupId string) (b Blacklist) {
+	if groupId == "" {
+		return bs.global
+	}
+	b, found := bs.Find(groupId)
+	if !found {
+		bs.lock.Lock()
+		defer bs.lock.Unlock()
+		b, found = bs.groups[groupId]
+		if !found {
+			b = NewBlacklist()
+			bs.groups[groupId] = b
+		}
+	}
+	return
+}
+
+func (bs blacklistScopes) Find(groupId string) (b Blacklist, found bool) {
+	if groupId == "" {
+		return bs.global, true
+	}
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
+	b, found = bs.groups[groupId]
+	return
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBlacklistScopes(t *testing.T) {
+	scopes := NewBlacklistScopes()
+	global, found := scopes.Find("")
+	require.True(t, found)
+	assert.Equal(t, global, scopes.Get(""))
+	_, found = scopes.Find("group0")
+	assert.False(t, found)
+	require.Nil(t, scopes.Get("group0").Put(context.TODO(), "source:https://offtopic.example", BlacklistValue{}))
+	group, found := scopes.Find("group0")
+	require.True(t, found)
+	_, _, found = group.Match(context.TODO(), "source", "https://offtopic.example/feed", true)
+	assert.True(t, found)
+	_, _, found = global.Match(context.TODO(), "source", "https://offtopic.example/feed", true)
+	assert.False(t, found)
+}
@@ -0,0 +1,925 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func newBlacklistTest() Blacklist {
+	return newBlacklistScopesTest().Get("")
+}
+
+// newBlacklistScopesTest returns the global rules and the rules of the "group0".
+func newBlacklistScopesTest() BlacklistScopes {
+	scopes := NewBlacklistScopes()
+	_ = scopes.Get("group0").Put(context.TODO(), "source:https://offtopic.example", BlacklistValue{})
+	blacklist := scopes.Get("")
+	for rule, kind := range map[string]BlacklistKind{
+		"source:https://spam.example":   "",
+		"type:com_spam":                 BlacklistKindPrefix,
+		"author:spammer":                BlacklistKindPrefix,
+		"link:https://phishing.example": BlacklistKindPrefix,
+		"source:ads.example":            BlacklistKindHost,
+		"source:https://*/casino/*":     BlacklistKindGlob,
+		"title:(?i)free\\s+money":       BlacklistKindRegex,
+	} {
+		_ = blacklist.Put(context.TODO(), rule, BlacklistValue{
+			Kind: kind,
+		})
+	}
+	for rule, v := range map[string]BlacklistValue{
+		"viagra": {},
+		"casino": {
+			WholeWord: true,
+		},
+		"ScamCoin": {
+			MatchCase: true,
+		},
+	} {
+		v.Kind = BlacklistKindKeyword
+		_ = blacklist.Put(context.TODO(), rule, v)
+	}
+	return scopes
+}
+
+func newBlacklistTestEvent(id, src, typ string, attrs map[string]*pb.CloudEventAttributeValue) *pb.CloudEvent {
+	return &pb.CloudEvent{
+		Id:         id,
+		Source:     src,
+		Type:       typ,
+		Attributes: attrs,
+	}
+}
+
+func TestFindBlacklistMatch(t *testing.T) {
+	blacklist := newBlacklistTest()
+	cases := map[string]struct {
+		in    *pb.CloudEvent
+		found bool
+		m     BlacklistMatch
+	}{
+		"none": {
+			in: newBlacklistTestEvent("1", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"author": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "john",
+					},
+				},
+			}),
+		},
+		"source": {
+			in:    newBlacklistTestEvent("1", "https://spam.example/feed", "com_example", nil),
+			found: true,
+			m: BlacklistMatch{
+				Kind:      BlacklistKindPrefix,
+				Prefix:    "source:https://spam.example",
+				EventId:   "1",
+				AttrName:  "source",
+				AttrValue: "https://spam.example/feed",
+			},
+		},
+		"type": {
+			in:    newBlacklistTestEvent("2", "https://example.com", "com_spam_ads", nil),
+			found: true,
+			m: BlacklistMatch{
+				Kind:      BlacklistKindPrefix,
+				Prefix:    "type:com_spam",
+				EventId:   "2",
+				AttrName:  "type",
+				AttrValue: "com_spam_ads",
+			},
+		},
+		"type doesn't match source prefix": {
+			in: newBlacklistTestEvent("3", "com_spam", "com_example", nil),
+		},
+		"string attribute": {
+			in: newBlacklistTestEvent("4", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"author": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "spammer123",
+					},
+				},
+			}),
+			found: true,
+			m: BlacklistMatch{
+				Kind:      BlacklistKindPrefix,
+				Prefix:    "author:spammer",
+				EventId:   "4",
+				AttrName:  "author",
+				AttrValue: "spammer123",
+			},
+		},
+		"uri attribute": {
+			in: newBlacklistTestEvent("5", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"link": {
+					Attr: &pb.CloudEventAttributeValue_CeUri{
+						CeUri: "https://phishing.example/login",
+					},
+				},
+			}),
+			found: true,
+			m: BlacklistMatch{
+				Kind:      BlacklistKindPrefix,
+				Prefix:    "link:https://phishing.example",
+				EventId:   "5",
+				AttrName:  "link",
+				AttrValue: "https://phishing.example/login",
+			},
+		},
+		"uri reference attribute": {
+			in: newBlacklistTestEvent("6", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"link": {
+					Attr: &pb.CloudEventAttributeValue_CeUriRef{
+						CeUriRef: "https://phishing.example/login",
+					},
+				},
+			}),
+			found: true,
+			m: BlacklistMatch{
+				Kind:      BlacklistKindPrefix,
+				Prefix:    "link:https://phishing.example",
+				EventId:   "6",
+				AttrName:  "link",
+				AttrValue: "https://phishing.example/login",
+			},
+		},
+		"source http www variant": {
+			in:    newBlacklistTestEvent("8", "http://WWW.spam.example:80/feed?q=1", "com_example", nil),
+			found: true,
+			m: BlacklistMatch{
+				Prefix:    "source:https://spam.example",
+				Kind:      BlacklistKindPrefix,
+				EventId:   "8",
+				AttrName:  "source",
+				AttrValue: "http://WWW.spam.example:80/feed?q=1",
+			},
+		},
+		"source subdomain": {
+			in:    newBlacklistTestEvent("9", "https://cdn.ads.example/feed", "com_example", nil),
+			found: true,
+			m: BlacklistMatch{
+				Prefix:    "source:ads.example",
+				Kind:      BlacklistKindHost,
+				EventId:   "9",
+				AttrName:  "source",
+				AttrValue: "https://cdn.ads.example/feed",
+			},
+		},
+		"source host is not a subdomain": {
+			in: newBlacklistTestEvent("10", "https://goodads.example/feed", "com_example", nil),
+		},
+		"source host in query": {
+			in: newBlacklistTestEvent("11", "https://example.com/?ref=https://ads.example", "com_example", nil),
+		},
+		"source glob": {
+			in:    newBlacklistTestEvent("12", "https://example.com/casino/1", "com_example", nil),
+			found: true,
+			m: BlacklistMatch{
+				Prefix:    "source:https://*/casino/*",
+				Kind:      BlacklistKindGlob,
+				EventId:   "12",
+				AttrName:  "source",
+				AttrValue: "https://example.com/casino/1",
+			},
+		},
+		"title regex": {
+			in: newBlacklistTestEvent("13", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"title": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "Get FREE   money now",
+					},
+				},
+			}),
+			found: true,
+			m: BlacklistMatch{
+				Prefix:    "title:(?i)free\\s+money",
+				Kind:      BlacklistKindRegex,
+				EventId:   "13",
+				AttrName:  "title",
+				AttrValue: "Get FREE   money now",
+			},
+		},
+		"host rule doesn't apply to string attribute": {
+			in: newBlacklistTestEvent("14", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"source": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "https://ads.example",
+					},
+				},
+			}),
+		},
+		"keyword in text data": {
+			in: &pb.CloudEvent{
+				Id:     "15",
+				Source: "https://example.com",
+				Type:   "com_example",
+				Data: &pb.CloudEvent_TextData{
+					TextData: "Cheap VIAGRA here",
+				},
+			},
+			found: true,
+			m: BlacklistMatch{
+				Prefix:   "viagra",
+				Kind:     BlacklistKindKeyword,
+				EventId:  "15",
+				AttrName: AttrTextData,
+			},
+		},
+		"whole word keyword in summary": {
+			in: newBlacklistTestEvent("16", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"summary": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "Online Casino, no deposit",
+					},
+				},
+			}),
+			found: true,
+			m: BlacklistMatch{
+				Prefix:   "casino",
+				Kind:     BlacklistKindKeyword,
+				EventId:  "16",
+				AttrName: "summary",
+			},
+		},
+		"whole word keyword inside another word": {
+			in: newBlacklistTestEvent("17", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"title": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "Casinos of Monaco",
+					},
+				},
+			}),
+		},
+		"match case keyword": {
+			in: newBlacklistTestEvent("18", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"title": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "Buy ScamCoin today",
+					},
+				},
+			}),
+			found: true,
+			m: BlacklistMatch{
+				Prefix:   "ScamCoin",
+				Kind:     BlacklistKindKeyword,
+				EventId:  "18",
+				AttrName: "title",
+			},
+		},
+		"match case keyword in other case": {
+			in: newBlacklistTestEvent("19", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"title": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "What is scamcoin",
+					},
+				},
+			}),
+		},
+		"keyword in other attribute is ignored": {
+			in: newBlacklistTestEvent("20", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"author": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "viagra",
+					},
+				},
+			}),
+		},
+		"non-string attribute is ignored": {
+			in: newBlacklistTestEvent("7", "https://example.com", "com_example", map[string]*pb.CloudEventAttributeValue{
+				"author": {
+					Attr: &pb.CloudEventAttributeValue_CeBytes{
+						CeBytes: []byte("spammer"),
+					},
+				},
+			}),
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			m, found := FindBlacklistMatch(context.TODO(), blacklist, c.in)
+			assert.Equal(t, c.found, found)
+			if c.found {
+				assert.Equal(t, c.m, m)
+			}
+		})
+	}
+}
+
+func TestFilterBlacklisted(t *testing.T) {
+	cases := map[string]struct {
+		groupId  string
+		in       []*pb.CloudEvent
+		out      []string
+		filtered map[int]string
+	}{
+		"empty": {
+			out: []string{},
+		},
+		"none blacklisted": {
+			in: []*pb.CloudEvent{
+				newBlacklistTestEvent("1", "src1", "type1", nil),
+				newBlacklistTestEvent("2", "src2", "type2", nil),
+			},
+			out: []string{"1", "2"},
+		},
+		"first blacklisted": {
+			in: []*pb.CloudEvent{
+				newBlacklistTestEvent("1", "https://spam.example/feed", "type1", nil),
+				newBlacklistTestEvent("2", "src2", "type2", nil),
+				newBlacklistTestEvent("3", "src3", "type3", nil),
+			},
+			out: []string{"2", "3"},
+			filtered: map[int]string{
+				0: "source:https://spam.example",
+			},
+		},
+		"mixed": {
+			in: []*pb.CloudEvent{
+				newBlacklistTestEvent("1", "src1", "type1", nil),
+				newBlacklistTestEvent("2", "src2", "com_spam", nil),
+				newBlacklistTestEvent("3", "src3", "type3", nil),
+				newBlacklistTestEvent("4", "src4", "type4", map[string]*pb.CloudEventAttributeValue{
+					"author": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "spammer",
+						},
+					},
+				}),
+				newBlacklistTestEvent("5", "src5", "type5", nil),
+			},
+			out: []string{"1", "3", "5"},
+			filtered: map[int]string{
+				1: "type:com_spam",
+				3: "author:spammer",
+			},
+		},
+		"all blacklisted": {
+			in: []*pb.CloudEvent{
+				newBlacklistTestEvent("1", "https://spam.example/feed", "type1", nil),
+				newBlacklistTestEvent("2", "src2", "com_spam", nil),
+			},
+			out: []string{},
+			filtered: map[int]string{
+				0: "source:https://spam.example",
+				1: "type:com_spam",
+			},
+		},
+		"group rule": {
+			groupId: "group0",
+			in: []*pb.CloudEvent{
+				newBlacklistTestEvent("1", "https://offtopic.example/feed", "type1", nil),
+				newBlacklistTestEvent("2", "https://spam.example/feed", "type2", nil),
+				newBlacklistTestEvent("3", "src3", "type3", nil),
+			},
+			out: []string{"3"},
+			filtered: map[int]string{
+				0: "source:https://offtopic.example",
+				1: "source:https://spam.example",
+			},
+		},
+		"other group": {
+			groupId: "group1",
+			in: []*pb.CloudEvent{
+				newBlacklistTestEvent("1", "https://offtopic.example/feed", "type1", nil),
+			},
+			out: []string{"1"},
+		},
+	}
+	policy := NewBlacklistPolicy(newBlacklistScopesTest(), "awkflagged", slog.Default())
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			out, quarantined, filtered := FilterBlacklisted(context.TODO(), policy, c.groupId, c.in)
+			assert.Empty(t, quarantined)
+			ids := make([]string, 0, len(out))
+			for _, evt := range out {
+				ids = append(ids, evt.Id)
+			}
+			assert.Equal(t, c.out, ids)
+			assert.Len(t, filtered, len(c.filtered))
+			for i, prefix := range c.filtered {
+				assert.Equal(t, prefix, filtered[i].Prefix)
+				assert.Equal(t, c.in[i].Id, filtered[i].EventId)
+				if prefix == "source:https://offtopic.example" {
+					assert.Equal(t, c.groupId, filtered[i].GroupId)
+				} else {
+					assert.Empty(t, filtered[i].GroupId)
+				}
+			}
+		})
+	}
+}
+
+func TestBlacklist_Put(t *testing.T) {
+	cases := map[string]struct {
+		rule   string
+		kind   BlacklistKind
+		allow  bool
+		action BlacklistAction
+		err    error
+	}{
+		"prefix": {
+			rule: "source:https://spam.example",
+		},
+		"empty prefix": {
+			err: ErrInvalidBlacklistRule,
+		},
+		"unknown kind": {
+			rule: "source:https://spam.example",
+			kind: "fuzzy",
+			err:  ErrInvalidBlacklistRule,
+		},
+		"host": {
+			rule: "source:spam.example",
+			kind: BlacklistKindHost,
+		},
+		"host with path": {
+			rule: "source:spam.example/feed",
+			kind: BlacklistKindHost,
+			err:  ErrInvalidBlacklistRule,
+		},
+		"glob without attribute": {
+			rule: "*spam*",
+			kind: BlacklistKindGlob,
+			err:  ErrInvalidBlacklistRule,
+		},
+		"invalid regex": {
+			rule: "title:(spam",
+			kind: BlacklistKindRegex,
+			err:  ErrInvalidBlacklistRule,
+		},
+		"keyword": {
+			rule: "free money",
+			kind: BlacklistKindKeyword,
+		},
+		"blank keyword": {
+			rule: "  ",
+			kind: BlacklistKindKeyword,
+			err:  ErrInvalidBlacklistRule,
+		},
+		"allow host": {
+			rule:  "source:good.example",
+			kind:  BlacklistKindHost,
+			allow: true,
+		},
+		"allow glob": {
+			rule:  "source:*good*",
+			kind:  BlacklistKindGlob,
+			allow: true,
+			err:   ErrInvalidBlacklistRule,
+		},
+		"allow with action": {
+			rule:   "source:https://good.example",
+			allow:  true,
+			action: BlacklistActionTag,
+			err:    ErrInvalidBlacklistRule,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err := NewBlacklist().Put(context.TODO(), c.rule, BlacklistValue{
+				Kind:   c.kind,
+				Allow:  c.allow,
+				Action: c.action,
+			})
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func TestBlacklist_PutAll(t *testing.T) {
+	b := NewBlacklist()
+	require.Nil(t, b.Put(context.TODO(), "source:*old*", BlacklistValue{
+		Kind:   BlacklistKindGlob,
+		Reason: "old",
+	}))
+	err := b.PutAll(context.TODO(), map[string]BlacklistValue{
+		"source:https://spam.example": {},
+		"source:*old*": {
+			Kind:   BlacklistKindGlob,
+			Reason: "replaced",
+		},
+		"source:*new*": {
+			Kind: BlacklistKindGlob,
+		},
+		"type:[": {
+			Kind: BlacklistKindRegex,
+		},
+		"spam": {
+			Kind: BlacklistKindKeyword,
+		},
+		"eggs": {
+			Kind: BlacklistKindKeyword,
+		},
+	})
+	assert.ErrorIs(t, err, ErrInvalidBlacklistRule)
+	rule, _, _ := b.Match(context.TODO(), "source", "https://spam.example/feed", true)
+	assert.Equal(t, "source:https://spam.example", rule)
+	rule, v, _ := b.Match(context.TODO(), "source", "https://old.example/feed", true)
+	assert.Equal(t, "source:*old*", rule)
+	assert.Equal(t, "replaced", v.Reason)
+	rule, _, _ = b.Match(context.TODO(), "source", "https://new.example/feed", true)
+	assert.Equal(t, "source:*new*", rule)
+	_, _, found := b.Match(context.TODO(), "type", "[", false)
+	assert.False(t, found)
+	rule, _, _ = b.MatchContent(context.TODO(), "spam and eggs")
+	assert.NotEmpty(t, rule)
+	rule, _, _ = b.MatchContent(context.TODO(), "only eggs")
+	assert.Equal(t, "eggs", rule)
+	// the replaced pattern is not duplicated
+	assert.Len(t, (*b.(blacklist).patterns.Load())["source"].rules, 2)
+}
+
+func TestBlacklist_Delete(t *testing.T) {
+	blacklist := newBlacklistTest()
+	cases := map[string]struct {
+		rule      string
+		attrName  string
+		attrValue string
+	}{
+		"prefix": {
+			rule:      "type:com_spam",
+			attrName:  "type",
+			attrValue: "com_spam_ads",
+		},
+		"host": {
+			rule:      "source:ads.example",
+			attrName:  "source",
+			attrValue: "https://ads.example",
+		},
+		"glob": {
+			rule:      "source:https://*/casino/*",
+			attrName:  "source",
+			attrValue: "https://example.com/casino/1",
+		},
+		"regex": {
+			rule:      "title:(?i)free\\s+money",
+			attrName:  "title",
+			attrValue: "free money",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			_, _, found := blacklist.Match(context.TODO(), c.attrName, c.attrValue, true)
+			require.True(t, found)
+			require.Nil(t, blacklist.Delete(context.TODO(), c.rule))
+			_, _, found = blacklist.Match(context.TODO(), c.attrName, c.attrValue, true)
+			assert.False(t, found)
+		})
+	}
+	// keyword
+	_, _, found := blacklist.MatchContent(context.TODO(), "viagra")
+	require.True(t, found)
+	require.Nil(t, blacklist.Delete(context.TODO(), "viagra"))
+	_, _, found = blacklist.MatchContent(context.TODO(), "viagra")
+	assert.False(t, found)
+	_, _, found = blacklist.MatchContent(context.TODO(), "casino")
+	assert.True(t, found)
+	// the kind change replaces the rule
+	require.Nil(t, blacklist.Put(context.TODO(), "author:spammer", BlacklistValue{Kind: BlacklistKindRegex}))
+	_, _, found = blacklist.Match(context.TODO(), "author", "not a spammer", false)
+	assert.True(t, found)
+	_, _, found = blacklist.Match(context.TODO(), "author", "spammer123", false)
+	assert.True(t, found)
+	require.Nil(t, blacklist.Put(context.TODO(), "author:spammer", BlacklistValue{Kind: BlacklistKindGlob}))
+	_, _, found = blacklist.Match(context.TODO(), "author", "not a spammer", false)
+	assert.False(t, found)
+}
+
+func TestBlacklist_Schedule(t *testing.T) {
+	now := time.Now()
+	cases := map[string]struct {
+		v     BlacklistValue
+		err   error
+		found bool
+	}{
+		"permanent": {
+			found: true,
+		},
+		"active": {
+			v: BlacklistValue{
+				NotBefore: now.Add(-time.Hour),
+				ExpiresAt: now.Add(time.Hour),
+			},
+			found: true,
+		},
+		"scheduled": {
+			v: BlacklistValue{
+				NotBefore: now.Add(time.Hour),
+			},
+		},
+		"expired": {
+			v: BlacklistValue{
+				ExpiresAt: now.Add(-time.Hour),
+			},
+		},
+		"expires before start": {
+			v: BlacklistValue{
+				NotBefore: now.Add(time.Hour),
+				ExpiresAt: now.Add(time.Minute),
+			},
+			err: ErrInvalidBlacklistRule,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			for _, kind := range []BlacklistKind{BlacklistKindPrefix, BlacklistKindHost, BlacklistKindGlob, BlacklistKindKeyword} {
+				blacklist := NewBlacklist()
+				rule := "source:spam.example"
+				switch kind {
+				case BlacklistKindPrefix:
+					rule = "source:https://spam.example"
+				case BlacklistKindGlob:
+					rule = "source:https://spam.example/*"
+				case BlacklistKindKeyword:
+					rule = "spam"
+				}
+				v := c.v
+				v.Kind = kind
+				err := blacklist.Put(context.TODO(), rule, v)
+				assert.ErrorIs(t, err, c.err)
+				var found bool
+				switch kind {
+				case BlacklistKindKeyword:
+					_, _, found = blacklist.MatchContent(context.TODO(), "spam")
+				default:
+					_, _, found = blacklist.Match(context.TODO(), "source", "https://spam.example/feed", true)
+				}
+				assert.Equal(t, c.found, found, kind)
+			}
+		})
+	}
+}
+
+func TestBlacklist_Expire(t *testing.T) {
+	b := NewBlacklist()
+	require.Nil(t, b.Put(context.TODO(), "source:https://spam.example", BlacklistValue{}))
+	// the longer expiring prefix shadows the permanent one until evicted
+	require.Nil(t, b.Put(context.TODO(), "source:https://spam.example/feed", BlacklistValue{
+		ExpiresAt: time.Now().Add(100 * time.Millisecond),
+	}))
+	rule, _, found := b.Match(context.TODO(), "source", "https://spam.example/feed", true)
+	require.True(t, found)
+	assert.Equal(t, "source:https://spam.example/feed", rule)
+	assert.Eventually(t, func() bool {
+		rule, _, _ = b.Match(context.TODO(), "source", "https://spam.example/feed", true)
+		return rule == "source:https://spam.example"
+	}, time.Second, 10*time.Millisecond)
+	// evicted from the trie, not only inactive
+	assert.Eventually(t, func() bool {
+		return b.(blacklist).prefixes.Len() == 1
+	}, time.Second, 10*time.Millisecond)
+	// the replacement by the permanent rule cancels the eviction
+	require.Nil(t, b.Put(context.TODO(), "source:https://ads.example", BlacklistValue{
+		ExpiresAt: time.Now().Add(100 * time.Millisecond),
+	}))
+	require.Nil(t, b.Put(context.TODO(), "source:https://ads.example", BlacklistValue{}))
+	time.Sleep(200 * time.Millisecond)
+	_, _, found = b.Match(context.TODO(), "source", "https://ads.example", true)
+	assert.True(t, found)
+	// the expired replacement removes the rule
+	require.Nil(t, b.Put(context.TODO(), "source:https://ads.example", BlacklistValue{
+		ExpiresAt: time.Now().Add(-time.Second),
+	}))
+	_, _, found = b.Match(context.TODO(), "source", "https://ads.example", true)
+	assert.False(t, found)
+}
+
+func TestBlacklist_MatchContent(t *testing.T) {
+	blacklist := NewBlacklist()
+	for rule, v := range map[string]BlacklistValue{
+		"free money": {},
+		"Bet":        {WholeWord: true, MatchCase: true},
+		"café":       {WholeWord: true},
+	} {
+		v.Kind = BlacklistKindKeyword
+		require.Nil(t, blacklist.Put(context.TODO(), rule, v))
+	}
+	cases := map[string]struct {
+		in   string
+		rule string
+	}{
+		"none": {
+			in: "nothing to see here",
+		},
+		"phrase folded": {
+			in:   "Get FREE MONEY now",
+			rule: "free money",
+		},
+		"phrase inside words": {
+			in:   "carefree moneybags",
+			rule: "free money",
+		},
+		"whole word at start": {
+			in:   "Bet now",
+			rule: "Bet",
+		},
+		"whole word at end": {
+			in:   "Place your Bet",
+			rule: "Bet",
+		},
+		"whole word prefix of another word": {
+			in: "Better luck next time",
+		},
+		"whole word suffix of another word": {
+			in: "AlphaBet soup",
+		},
+		"match case": {
+			in: "place your bet",
+		},
+		"whole word multibyte": {
+			in:   "Le CAFÉ.",
+			rule: "café",
+		},
+		"whole word followed by multibyte letter": {
+			in: "caféé",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			rule, v, found := blacklist.MatchContent(context.TODO(), c.in)
+			assert.Equal(t, c.rule != "", found)
+			assert.Equal(t, c.rule, rule)
+			if found {
+				assert.Equal(t, BlacklistKindKeyword, v.Kind)
+			}
+		})
+	}
+}
+
+func newAllowlistTest(t *testing.T) Blacklist {
+	blacklist := NewBlacklist()
+	for rule, v := range map[string]BlacklistValue{
+		"source:https://t.me": {},
+		"source:https://t.me/goodchannel": {
+			Allow: true,
+		},
+		"source:https://t.me/goodchannel/spam": {},
+		"source:bad.example": {
+			Kind: BlacklistKindHost,
+		},
+		"source:news.bad.example": {
+			Kind:  BlacklistKindHost,
+			Allow: true,
+		},
+		"source:tie.example": {
+			Kind:  BlacklistKindHost,
+			Allow: true,
+		},
+		"source:https://tie.example": {},
+		"source:*goodchannel*casino*": {
+			Kind: BlacklistKindGlob,
+		},
+	} {
+		require.Nil(t, blacklist.Put(context.TODO(), rule, v))
+	}
+	return blacklist
+}
+
+func TestBlacklist_Decide(t *testing.T) {
+	blacklist := newAllowlistTest(t)
+	cases := map[string]struct {
+		in         string
+		rule       string
+		allow      bool
+		overridden []string
+	}{
+		"none": {
+			in: "https://example.com",
+		},
+		"less specific block": {
+			in:   "https://t.me/otherchannel",
+			rule: "source:https://t.me",
+		},
+		"allow overrides less specific block": {
+			in:    "https://t.me/goodchannel/post1",
+			rule:  "source:https://t.me/goodchannel",
+			allow: true,
+			overridden: []string{
+				"source:https://t.me",
+			},
+		},
+		"more specific block overrides allow": {
+			in:   "https://t.me/goodchannel/spam/post1",
+			rule: "source:https://t.me/goodchannel/spam",
+		},
+		"allow host overrides less specific host": {
+			in:    "https://www.news.bad.example/feed",
+			rule:  "source:news.bad.example",
+			allow: true,
+			overridden: []string{
+				"source:bad.example",
+			},
+		},
+		"block wins the tie": {
+			in:   "https://tie.example/feed",
+			rule: "source:https://tie.example",
+		},
+		"allow overrides pattern": {
+			in:    "https://t.me/goodchannel/casino",
+			rule:  "source:https://t.me/goodchannel",
+			allow: true,
+			overridden: []string{
+				"source:https://t.me",
+				"source:*goodchannel*casino*",
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			d := blacklist.Decide(context.TODO(), "source", c.in, true)
+			assert.Equal(t, c.rule, d.Rule)
+			assert.Equal(t, c.allow, d.Value.Allow)
+			assert.Equal(t, c.overridden, d.Overridden)
+			_, _, found := blacklist.Match(context.TODO(), "source", c.in, true)
+			assert.Equal(t, c.rule != "" && !c.allow, found)
+		})
+	}
+}
+
+func TestFindBlacklistMatch_Allow(t *testing.T) {
+	blacklist := newAllowlistTest(t)
+	m, found := FindBlacklistMatch(context.TODO(), blacklist, newBlacklistTestEvent("1", "https://t.me/goodchannel/post1", "com_example", nil))
+	assert.False(t, found)
+	assert.Equal(t, []string{"source=https://t.me/goodchannel/post1 allowed by source:https://t.me/goodchannel over source:https://t.me"}, m.Trace)
+}
+
+func TestCanonicalUrl(t *testing.T) {
+	cases := map[string]struct {
+		in        string
+		canonical string
+		host      string
+	}{
+		"not url": {
+			in: "src1",
+		},
+		"relative": {
+			in: "/feed",
+		},
+		"https": {
+			in:        "https://example.com/feed",
+			canonical: "https://example.com/feed",
+			host:      "example.com",
+		},
+		"http www port query fragment": {
+			in:        "HTTP://user@WWW.Example.com.:80/Feed?q=1#top",
+			canonical: "https://example.com/Feed",
+			host:      "example.com",
+		},
+		"custom port": {
+			in:        "https://example.com:8443/feed",
+			canonical: "https://example.com:8443/feed",
+			host:      "example.com",
+		},
+		"other scheme": {
+			in:        "ftp://files.example.com/spam",
+			canonical: "ftp://files.example.com/spam",
+			host:      "files.example.com",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			canonical, host := CanonicalUrl(c.in)
+			assert.Equal(t, c.canonical, canonical)
+			assert.Equal(t, c.host, host)
+		})
+	}
+}
+
+func BenchmarkBlacklist_Match(b *testing.B) {
+	blacklist := NewBlacklist()
+	for i := 0; i < 1_000; i++ {
+		_ = blacklist.Put(context.TODO(), fmt.Sprintf("source:https://spam%d.example", i), BlacklistValue{})
+		_ = blacklist.Put(context.TODO(), fmt.Sprintf("source:spam%d.example", i), BlacklistValue{Kind: BlacklistKindHost})
+		_ = blacklist.Put(context.TODO(), fmt.Sprintf("source:https://*/spam%d/*", i), BlacklistValue{Kind: BlacklistKindGlob})
+		_ = blacklist.Put(context.TODO(), fmt.Sprintf("source:spam%d[a-z]+", i), BlacklistValue{Kind: BlacklistKindRegex})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, _ = blacklist.Match(context.TODO(), "source", "http://www.example.com/feed?q=1", true)
+	}
+}
+
+func TestRequiredLiteral(t *testing.T) {
+	cases := map[string]string{
+		"spam":                    "spam",
+		"(?i)Free\\s+Money":       "money",
+		"^https://.*/casino/.*$":  "/casino/",
+		"^https://spam\\.example": "spam.example",
+		"(spam|ads)":              "",
+		"a*":                      "",
+		"(?:ab)+c":                "ab",
+		"x{2,}yz":                 "yz",
+	}
+	for in, out := range cases {
+		t.Run(in, func(t *testing.T) {
+			assert.Equal(t, out, requiredLiteral(regexp.MustCompile(in)))
+		})
+	}
+}
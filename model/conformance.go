@@ -0,0 +1,75 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/segmentio/ksuid"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const CeSpecVersion = "1.0"
+
+// CeAttrNameLenMax is the max extension attribute name length recommended by the CloudEvents 1.0 spec.
+const CeAttrNameLenMax = 20
+
+var ErrNonConformant = errors.New("event doesn't conform CloudEvents 1.0")
+
+var patternCeAttrName = regexp.MustCompile(fmt.Sprintf("^[a-z0-9]{1,%d}$", CeAttrNameLenMax))
+
+// ValidateConformance checks the event against CloudEvents 1.0 and returns ErrNonConformant listing all violations.
+// In the lenient mode, the missing id is filled with the new ksuid and the missing spec version with "1.0".
+func ValidateConformance(evt *pb.CloudEvent, lenient bool) (err error) {
+	if lenient {
+		if evt.Id == "" {
+			evt.Id = ksuid.New().String()
+		}
+		if evt.SpecVersion == "" {
+			evt.SpecVersion = CeSpecVersion
+		}
+	}
+	var violations []string
+	switch evt.SpecVersion {
+	case CeSpecVersion:
+	case "":
+		violations = append(violations, "specversion: missing")
+	default:
+		violations = append(violations, fmt.Sprintf("specversion: unsupported %q, expected %q", evt.SpecVersion, CeSpecVersion))
+	}
+	if evt.Id == "" {
+		violations = append(violations, "id: missing")
+	}
+	switch evt.Source {
+	case "":
+		violations = append(violations, "source: missing")
+	default:
+		if _, errSrc := url.Parse(evt.Source); errSrc != nil {
+			violations = append(violations, fmt.Sprintf("source: not a URI-reference: %q", evt.Source))
+		}
+	}
+	if evt.Type == "" {
+		violations = append(violations, "type: missing")
+	}
+	var violationsAttrs []string
+	for k, v := range evt.Attributes {
+		if !patternCeAttrName.MatchString(k) {
+			violationsAttrs = append(violationsAttrs, fmt.Sprintf("attribute %q: name should be lowercase alphanumeric, at most %d characters", k, CeAttrNameLenMax))
+		}
+		if k == "dataschema" {
+			u, errUri := url.Parse(v.GetCeUri() + v.GetCeString())
+			if errUri != nil || !u.IsAbs() {
+				violationsAttrs = append(violationsAttrs, fmt.Sprintf("attribute %q: not an absolute URI", k))
+			}
+		}
+	}
+	// attributes are iterated in the random order
+	sort.Strings(violationsAttrs)
+	violations = append(violations, violationsAttrs...)
+	if len(violations) > 0 {
+		err = fmt.Errorf("%w: %s", ErrNonConformant, strings.Join(violations, "; "))
+	}
+	return
+}
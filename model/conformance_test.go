@@ -0,0 +1,122 @@
+package model
+
+import (
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateConformance(t *testing.T) {
+	cases := map[string]struct {
+		in      *pb.CloudEvent
+		lenient bool
+		err     error
+		msg     string
+	}{
+		"ok": {
+			in: &pb.CloudEvent{
+				Id:          "1",
+				Source:      "https://awakari.com/pub-msg.html",
+				SpecVersion: "1.0",
+				Type:        "com_awakari_webapp",
+				Attributes: map[string]*pb.CloudEventAttributeValue{
+					"title": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "foo",
+						},
+					},
+					"dataschema": {
+						Attr: &pb.CloudEventAttributeValue_CeUri{
+							CeUri: "https://awakari.com/schema.json",
+						},
+					},
+				},
+			},
+		},
+		"relative source": {
+			in: &pb.CloudEvent{
+				Id:          "1",
+				Source:      "/sensors/tn-1234567/alerts",
+				SpecVersion: "1.0",
+				Type:        "type0",
+			},
+		},
+		"all violations": {
+			in: &pb.CloudEvent{
+				Source: "http://[::1",
+				Attributes: map[string]*pb.CloudEventAttributeValue{
+					"Title": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "foo",
+						},
+					},
+					"averyveryverylongname0": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "foo",
+						},
+					},
+					"dataschema": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "schema.json",
+						},
+					},
+				},
+			},
+			err: ErrNonConformant,
+			msg: `event doesn't conform CloudEvents 1.0: specversion: missing; id: missing; source: not a URI-reference: "http://[::1"; type: missing; attribute "Title": name should be lowercase alphanumeric, at most 20 characters; attribute "averyveryverylongname0": name should be lowercase alphanumeric, at most 20 characters; attribute "dataschema": not an absolute URI`,
+		},
+		"dataschema urn": {
+			in: &pb.CloudEvent{
+				Id:          "1",
+				Source:      "src0",
+				SpecVersion: "1.0",
+				Type:        "type0",
+				Attributes: map[string]*pb.CloudEventAttributeValue{
+					"dataschema": {
+						Attr: &pb.CloudEventAttributeValue_CeUri{
+							CeUri: "urn:schema:x",
+						},
+					},
+				},
+			},
+		},
+		"unsupported spec version": {
+			in: &pb.CloudEvent{
+				Id:          "1",
+				Source:      "src0",
+				SpecVersion: "0.3",
+				Type:        "type0",
+			},
+			err: ErrNonConformant,
+			msg: `event doesn't conform CloudEvents 1.0: specversion: unsupported "0.3", expected "1.0"`,
+		},
+		"lenient fills id and spec version": {
+			in: &pb.CloudEvent{
+				Source: "src0",
+				Type:   "type0",
+			},
+			lenient: true,
+		},
+		"lenient doesn't fill source": {
+			in: &pb.CloudEvent{
+				Type: "type0",
+			},
+			lenient: true,
+			err:     ErrNonConformant,
+			msg:     "event doesn't conform CloudEvents 1.0: source: missing",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err := ValidateConformance(c.in, c.lenient)
+			assert.ErrorIs(t, err, c.err)
+			if c.msg != "" {
+				assert.Equal(t, c.msg, err.Error())
+			}
+			if c.lenient {
+				assert.NotEmpty(t, c.in.Id)
+				assert.Equal(t, CeSpecVersion, c.in.SpecVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytedance/sonic"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/segmentio/ksuid"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hook is the inbound webhook bound to the mapping that converts an arbitrary JSON payload to an event.
+type Hook struct {
+	Id        string
+	GroupId   string
+	UserId    string
+	CreatedAt time.Time
+	Mapping   HookMapping
+}
+
+// HookMapping contains the expressions to extract the event fields from the webhook payload.
+// An expression is either:
+//   - a path starting with "$", e.g. "$.repository.html_url" or "$.commits[0]['id']"
+//   - a template where every "{$...}" placeholder is replaced by the path value, e.g. "github_{$.action}"
+//   - a literal value otherwise.
+type HookMapping struct {
+
+	// Id is the expression for the event id. A new unique id is generated when empty.
+	Id string `json:"id,omitempty"`
+
+	Source string `json:"source"`
+
+	Type string `json:"type"`
+
+	TextData string `json:"textData,omitempty"`
+
+	// Attributes maps the event attribute names to the expressions. Every attribute value is a string.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+var ErrInvalidHookMapping = errors.New("invalid hook mapping")
+var ErrHookPayload = errors.New("failed to map the hook payload")
+
+const hookSpecVersion = "1.0"
+
+// Validate checks the required expressions are present and every expression is well-formed.
+func (m HookMapping) Validate() (err error) {
+	switch {
+	case m.Source == "":
+		err = fmt.Errorf("%w: empty source", ErrInvalidHookMapping)
+	case m.Type == "":
+		err = fmt.Errorf("%w: empty type", ErrInvalidHookMapping)
+	}
+	exprs := map[string]string{
+		"id":       m.Id,
+		"source":   m.Source,
+		"type":     m.Type,
+		"textData": m.TextData,
+	}
+	for k, v := range m.Attributes {
+		exprs["attributes."+k] = v
+	}
+	for k, expr := range exprs {
+		if err != nil {
+			break
+		}
+		_, errExpr := evalExpr(expr, nil)
+		if errors.Is(errExpr, errPathSyntax) {
+			err = fmt.Errorf("%w: %s: %s", ErrInvalidHookMapping, k, errExpr)
+		}
+	}
+	return
+}
+
+// Apply converts the JSON payload to the event.
+func (m HookMapping) Apply(payload []byte) (evt *pb.CloudEvent, err error) {
+	var doc any
+	err = sonic.Unmarshal(payload, &doc)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrHookPayload, err)
+		return
+	}
+	evt = &pb.CloudEvent{
+		SpecVersion: hookSpecVersion,
+		Attributes:  make(map[string]*pb.CloudEventAttributeValue),
+	}
+	if evt.Id, err = evalField("id", m.Id, doc); err == nil && evt.Id == "" {
+		evt.Id = ksuid.New().String()
+	}
+	if err == nil {
+		evt.Source, err = evalField("source", m.Source, doc)
+	}
+	if err == nil {
+		evt.Type, err = evalField("type", m.Type, doc)
+	}
+	var txt string
+	if err == nil {
+		txt, err = evalField("textData", m.TextData, doc)
+	}
+	if err == nil && txt != "" {
+		evt.Data = &pb.CloudEvent_TextData{
+			TextData: txt,
+		}
+	}
+	for k, expr := range m.Attributes {
+		if err != nil {
+			break
+		}
+		var v string
+		v, err = evalField("attributes."+k, expr, doc)
+		if err == nil && v != "" {
+			evt.Attributes[k] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeString{
+					CeString: v,
+				},
+			}
+		}
+	}
+	if err == nil {
+		switch {
+		case evt.Source == "":
+			err = fmt.Errorf("%w: empty source", ErrHookPayload)
+		case evt.Type == "":
+			err = fmt.Errorf("%w: empty type", ErrHookPayload)
+		}
+	}
+	return
+}
+
+func evalField(name, expr string, doc any) (v string, err error) {
+	v, err = evalExpr(expr, doc)
+	if err != nil {
+		err = fmt.Errorf("%w: %s: %s", ErrHookPayload, name, err)
+	}
+	return
+}
+
+var errPathSyntax = errors.New("path syntax error")
+
+func evalExpr(expr string, doc any) (v string, err error) {
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		v, err = evalPathString(expr, doc)
+	case strings.Contains(expr, "{$"):
+		v, err = evalTemplate(expr, doc)
+	default:
+		v = expr
+	}
+	return
+}
+
+func evalTemplate(tmpl string, doc any) (v string, err error) {
+	var sb strings.Builder
+	for {
+		start := strings.Index(tmpl, "{$")
+		if start < 0 {
+			sb.WriteString(tmpl)
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			err = fmt.Errorf("%w: unclosed placeholder in %s", errPathSyntax, tmpl)
+			return
+		}
+		end += start
+		sb.WriteString(tmpl[:start])
+		var pv string
+		pv, err = evalPathString(tmpl[start+1:end], doc)
+		if err != nil {
+			return
+		}
+		sb.WriteString(pv)
+		tmpl = tmpl[end+1:]
+	}
+	v = sb.String()
+	return
+}
+
+func evalPathString(path string, doc any) (v string, err error) {
+	var segs []any
+	segs, err = parsePath(path)
+	if err == nil && doc != nil {
+		var node any
+		node = lookupPath(segs, doc)
+		v, err = nodeString(node)
+	}
+	return
+}
+
+// parsePath splits the path like "$.a.b[0]['c d']" to the segments: field names (string) and array indices (int).
+func parsePath(path string) (segs []any, err error) {
+	if !strings.HasPrefix(path, "$") {
+		err = fmt.Errorf("%w: %s doesn't start with $", errPathSyntax, path)
+		return
+	}
+	rest := path[1:]
+	for len(rest) > 0 && err == nil {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				err = fmt.Errorf("%w: empty field name in %s", errPathSyntax, path)
+				break
+			}
+			segs = append(segs, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				err = fmt.Errorf("%w: unclosed bracket in %s", errPathSyntax, path)
+				break
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segs = append(segs, inner[1:len(inner)-1])
+			default:
+				var i int
+				i, err = strconv.Atoi(inner)
+				if err != nil || i < 0 {
+					err = fmt.Errorf("%w: invalid index %s in %s", errPathSyntax, inner, path)
+					break
+				}
+				segs = append(segs, i)
+			}
+		default:
+			err = fmt.Errorf("%w: unexpected %q in %s", errPathSyntax, rest[0], path)
+		}
+	}
+	return
+}
+
+// lookupPath returns nil when the path doesn't exist in the document.
+func lookupPath(segs []any, doc any) (node any) {
+	node = doc
+	for _, seg := range segs {
+		switch s := seg.(type) {
+		case string:
+			obj, ok := node.(map[string]any)
+			if !ok {
+				return nil
+			}
+			node = obj[s]
+		case int:
+			arr, ok := node.([]any)
+			if !ok || s >= len(arr) {
+				return nil
+			}
+			node = arr[s]
+		}
+	}
+	return
+}
+
+func nodeString(node any) (v string, err error) {
+	switch n := node.(type) {
+	case nil:
+	case string:
+		v = n
+	case bool:
+		v = strconv.FormatBool(n)
+	case float64:
+		v = strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		v, err = sonic.MarshalString(n)
+	}
+	return
+}
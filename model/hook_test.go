@@ -0,0 +1,149 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHookMapping_Validate(t *testing.T) {
+	cases := map[string]struct {
+		in  HookMapping
+		err error
+	}{
+		"ok": {
+			in: HookMapping{
+				Id:       "$.id",
+				Source:   "$.repository['html_url']",
+				Type:     "com_github_{$.action}",
+				TextData: "$.commits[0].message",
+				Attributes: map[string]string{
+					"author": "$.sender.login",
+				},
+			},
+		},
+		"missing source": {
+			in: HookMapping{
+				Type: "type0",
+			},
+			err: ErrInvalidHookMapping,
+		},
+		"missing type": {
+			in: HookMapping{
+				Source: "src0",
+			},
+			err: ErrInvalidHookMapping,
+		},
+		"unclosed bracket": {
+			in: HookMapping{
+				Source: "$.commits[0",
+				Type:   "type0",
+			},
+			err: ErrInvalidHookMapping,
+		},
+		"unclosed placeholder": {
+			in: HookMapping{
+				Source: "src0",
+				Type:   "type_{$.action",
+			},
+			err: ErrInvalidHookMapping,
+		},
+		"invalid attribute": {
+			in: HookMapping{
+				Source: "src0",
+				Type:   "type0",
+				Attributes: map[string]string{
+					"foo": "$..bar",
+				},
+			},
+			err: ErrInvalidHookMapping,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err := c.in.Validate()
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func TestHookMapping_Apply(t *testing.T) {
+	m := HookMapping{
+		Id:       "$.head_commit.id",
+		Source:   "$.repository.html_url",
+		Type:     "com_github_{$.action}",
+		TextData: "$.commits[1]['message']",
+		Attributes: map[string]string{
+			"forced":  "$.forced",
+			"size":    "$.size",
+			"sender":  "$.sender",
+			"absent":  "$.missing.field",
+			"literal": "github",
+		},
+	}
+	cases := map[string]struct {
+		in    string
+		id    string
+		src   string
+		typ   string
+		txt   string
+		attrs map[string]string
+		err   error
+	}{
+		"ok": {
+			in: `{
+  "action": "push",
+  "forced": false,
+  "size": 2,
+  "sender": {"login": "john"},
+  "repository": {"html_url": "https://github.com/awakari/pub"},
+  "head_commit": {"id": "abc"},
+  "commits": [{"message": "first"}, {"message": "second"}]
+}`,
+			id:  "abc",
+			src: "https://github.com/awakari/pub",
+			typ: "com_github_push",
+			txt: "second",
+			attrs: map[string]string{
+				"forced":  "false",
+				"size":    "2",
+				"sender":  `{"login":"john"}`,
+				"literal": "github",
+			},
+		},
+		"missing source": {
+			in:  `{"action": "push"}`,
+			err: ErrHookPayload,
+		},
+		"malformed": {
+			in:  `{"action": `,
+			err: ErrHookPayload,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			evt, err := m.Apply([]byte(c.in))
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.id, evt.Id)
+				assert.Equal(t, "1.0", evt.SpecVersion)
+				assert.Equal(t, c.src, evt.Source)
+				assert.Equal(t, c.typ, evt.Type)
+				assert.Equal(t, c.txt, evt.GetTextData())
+				assert.Len(t, evt.Attributes, len(c.attrs))
+				for name, v := range c.attrs {
+					assert.Equal(t, v, evt.Attributes[name].GetCeString())
+				}
+			}
+		})
+	}
+}
+
+func TestHookMapping_Apply_GeneratesId(t *testing.T) {
+	m := HookMapping{
+		Source: "src0",
+		Type:   "type0",
+	}
+	evt, err := m.Apply([]byte(`{}`))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, evt.Id)
+}
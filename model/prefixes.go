@@ -2,35 +2,293 @@ package model
 
 import (
 	"context"
-	"github.com/porfirion/trie"
+	"iter"
+	"sync"
+	"sync/atomic"
 )
 
 type Prefixes[T any] interface {
 	Put(ctx context.Context, prefix string, v T) (err error)
+	// Delete removes the exact prefix. Does nothing if the prefix is missing.
+	Delete(ctx context.Context, prefix string) (err error)
+	// FindOnePrefix returns the longest prefix of the input.
 	FindOnePrefix(ctx context.Context, input string) (prefix string, v T, err error)
+	// FindAllPrefixes returns every prefix of the input, from the shortest to the longest.
+	FindAllPrefixes(ctx context.Context, input string) (prefixes []string, vs []T, err error)
+	// Len returns the count of the stored prefixes.
+	Len() int
+	// All iterates the stored prefixes in the lexicographical order.
+	All() iter.Seq2[string, T]
 }
 
+// prefixes is the copy-on-write radix tree: the lookups read the current immutable snapshot without locking, while
+// the writers are serialized and replace the snapshot copying only the nodes along the changed path.
 type prefixes[T any] struct {
-	t *trie.Trie[T]
+	lock *sync.Mutex
+	snap *atomic.Pointer[prefixesSnapshot[T]]
+}
+
+type prefixesSnapshot[T any] struct {
+	root *prefixNode[T]
+	len  int
+}
+
+type prefixNode[T any] struct {
+	// edge is the part of the prefix between the parent node and this one, empty only for the root.
+	edge string
+	v    T
+	set  bool
+	// children are sorted by the first edge byte, which is unique among the siblings.
+	children []*prefixNode[T]
 }
 
 func NewPrefixes[T any]() Prefixes[T] {
+	snap := &atomic.Pointer[prefixesSnapshot[T]]{}
+	snap.Store(&prefixesSnapshot[T]{
+		root: &prefixNode[T]{},
+	})
 	return prefixes[T]{
-		t: &trie.Trie[T]{},
+		lock: &sync.Mutex{},
+		snap: snap,
 	}
 }
 
 func (p prefixes[T]) Put(ctx context.Context, prefix string, v T) (err error) {
-	p.t.PutString(prefix, v)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s := p.snap.Load()
+	root, added := s.root.put(prefix, v)
+	next := &prefixesSnapshot[T]{
+		root: root,
+		len:  s.len,
+	}
+	if added {
+		next.len++
+	}
+	p.snap.Store(next)
+	return
+}
+
+func (p prefixes[T]) Delete(ctx context.Context, prefix string) (err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s := p.snap.Load()
+	root, deleted := s.root.delete(prefix)
+	if deleted {
+		if root == nil {
+			root = &prefixNode[T]{}
+		}
+		p.snap.Store(&prefixesSnapshot[T]{
+			root: root,
+			len:  s.len - 1,
+		})
+	}
 	return
 }
 
 func (p prefixes[T]) FindOnePrefix(ctx context.Context, input string) (prefix string, v T, err error) {
-	var length int
-	var ok bool
-	v, length, ok = p.t.SearchPrefixInString(input)
-	if ok {
+	length, n := p.snap.Load().root.longest(input)
+	if n != nil {
 		prefix = input[:length]
+		v = n.v
+	}
+	return
+}
+
+func (p prefixes[T]) FindAllPrefixes(ctx context.Context, input string) (prefixes []string, vs []T, err error) {
+	p.snap.Load().root.walk(input, func(length int, n *prefixNode[T]) {
+		prefixes = append(prefixes, input[:length])
+		vs = append(vs, n.v)
+	})
+	return
+}
+
+func (p prefixes[T]) Len() int {
+	return p.snap.Load().len
+}
+
+func (p prefixes[T]) All() iter.Seq2[string, T] {
+	root := p.snap.Load().root
+	return func(yield func(string, T) bool) {
+		root.all("", yield)
+	}
+}
+
+// longest returns the deepest node having the value along the input path, nil if none.
+func (n *prefixNode[T]) longest(input string) (length int, dst *prefixNode[T]) {
+	var pos int
+	for {
+		if n.set {
+			length, dst = pos, n
+		}
+		if pos == len(input) {
+			return
+		}
+		i, found := n.child(input[pos])
+		if !found {
+			return
+		}
+		n = n.children[i]
+		if len(input)-pos < len(n.edge) || input[pos:pos+len(n.edge)] != n.edge {
+			return
+		}
+		pos += len(n.edge)
+	}
+}
+
+// walk invokes the consumer for every node having the value along the input path, from the root to the deepest.
+func (n *prefixNode[T]) walk(input string, consume func(length int, n *prefixNode[T])) {
+	var pos int
+	for {
+		if n.set {
+			consume(pos, n)
+		}
+		if pos == len(input) {
+			return
+		}
+		i, found := n.child(input[pos])
+		if !found {
+			return
+		}
+		n = n.children[i]
+		if len(input)-pos < len(n.edge) || input[pos:pos+len(n.edge)] != n.edge {
+			return
+		}
+		pos += len(n.edge)
+	}
+}
+
+// child returns the index of the child by the first edge byte, or the index to insert such child if not found.
+func (n *prefixNode[T]) child(b byte) (i int, found bool) {
+	j := len(n.children)
+	for i < j {
+		m := int(uint(i+j) >> 1)
+		if n.children[m].edge[0] < b {
+			i = m + 1
+		} else {
+			j = m
+		}
+	}
+	found = i < len(n.children) && n.children[i].edge[0] == b
+	return
+}
+
+// put returns the copy of the node with the value set by the key relative to the node.
+func (n *prefixNode[T]) put(key string, v T) (dst *prefixNode[T], added bool) {
+	cp := *n
+	dst = &cp
+	if key == "" {
+		added = !n.set
+		dst.v = v
+		dst.set = true
+		return
+	}
+	i, found := n.child(key[0])
+	if !found {
+		added = true
+		dst.children = make([]*prefixNode[T], 0, len(n.children)+1)
+		dst.children = append(dst.children, n.children[:i]...)
+		dst.children = append(dst.children, &prefixNode[T]{
+			edge: key,
+			v:    v,
+			set:  true,
+		})
+		dst.children = append(dst.children, n.children[i:]...)
+		return
+	}
+	c := n.children[i]
+	l := commonPrefixLen(key, c.edge)
+	if l < len(c.edge) {
+		// split the child edge
+		tail := *c
+		tail.edge = c.edge[l:]
+		c = &prefixNode[T]{
+			edge: c.edge[:l],
+			children: []*prefixNode[T]{
+				&tail,
+			},
+		}
+	}
+	var next *prefixNode[T]
+	next, added = c.put(key[l:], v)
+	dst.children = append([]*prefixNode[T]{}, n.children...)
+	dst.children[i] = next
+	return
+}
+
+// delete returns the copy of the node without the value by the key relative to the node, nil if the node is empty.
+// Returns the same node if the key is missing.
+func (n *prefixNode[T]) delete(key string) (dst *prefixNode[T], deleted bool) {
+	dst = n
+	if key == "" {
+		if n.set {
+			cp := *n
+			var v T
+			cp.v = v
+			cp.set = false
+			dst = cp.compact()
+			deleted = true
+		}
+		return
+	}
+	i, found := n.child(key[0])
+	if !found {
+		return
+	}
+	c := n.children[i]
+	if len(key) < len(c.edge) || key[:len(c.edge)] != c.edge {
+		return
+	}
+	var next *prefixNode[T]
+	next, deleted = c.delete(key[len(c.edge):])
+	if deleted {
+		cp := *n
+		switch next {
+		case nil:
+			cp.children = make([]*prefixNode[T], 0, len(n.children)-1)
+			cp.children = append(cp.children, n.children[:i]...)
+			cp.children = append(cp.children, n.children[i+1:]...)
+		default:
+			cp.children = append([]*prefixNode[T]{}, n.children...)
+			cp.children[i] = next
+		}
+		dst = cp.compact()
+	}
+	return
+}
+
+// compact removes the node without the value and children or merges it with the only child.
+// The root node (empty edge) is never merged.
+func (n *prefixNode[T]) compact() (dst *prefixNode[T]) {
+	dst = n
+	switch {
+	case n.set:
+	case len(n.children) == 0:
+		dst = nil
+	case len(n.children) == 1 && n.edge != "":
+		merged := *n.children[0]
+		merged.edge = n.edge + merged.edge
+		dst = &merged
+	}
+	return
+}
+
+func (n *prefixNode[T]) all(prefix string, yield func(string, T) bool) bool {
+	prefix += n.edge
+	if n.set && !yield(prefix, n.v) {
+		return false
+	}
+	for _, c := range n.children {
+		if !c.all(prefix, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+func commonPrefixLen(a, b string) (l int) {
+	for l < len(a) && l < len(b) && a[l] == b[l] {
+		l++
 	}
 	return
 }
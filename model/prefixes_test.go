@@ -2,8 +2,13 @@ package model
 
 import (
 	"context"
+	"fmt"
+	"github.com/porfirion/trie"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -43,3 +48,294 @@ func TestPrefixes_FindOnePrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestPrefixes_Delete(t *testing.T) {
+	p := NewPrefixes[bool]()
+	require.Nil(t, p.Put(context.TODO(), "foo", false))
+	require.Nil(t, p.Put(context.TODO(), "foobar", true))
+	require.Nil(t, p.Put(context.TODO(), "bar", true))
+	cases := map[string]struct {
+		prefix string
+		in     string
+		out    string
+	}{
+		"missing": {
+			prefix: "baz",
+			in:     "foo42",
+			out:    "foo",
+		},
+		"shorter remains": {
+			prefix: "foobar",
+			in:     "foobar42",
+			out:    "foo",
+		},
+		"removed": {
+			prefix: "bar",
+			in:     "bar42",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			require.Nil(t, p.Delete(context.TODO(), c.prefix))
+			prefix, _, err := p.FindOnePrefix(context.TODO(), c.in)
+			assert.Nil(t, err)
+			assert.Equal(t, c.out, prefix)
+		})
+	}
+}
+
+func TestPrefixes_FindAllPrefixes(t *testing.T) {
+	p := NewPrefixes[int]()
+	require.Nil(t, p.Put(context.TODO(), "source:https://t.me/", 1))
+	require.Nil(t, p.Put(context.TODO(), "source:https://t.me/spam", 2))
+	require.Nil(t, p.Put(context.TODO(), "source:https://t.me/spammer", 3))
+	require.Nil(t, p.Put(context.TODO(), "source:https://example.com", 4))
+	cases := map[string]struct {
+		in       string
+		prefixes []string
+		vs       []int
+	}{
+		"none": {
+			in: "source:https://t.m",
+		},
+		"one": {
+			in:       "source:https://t.me/good",
+			prefixes: []string{"source:https://t.me/"},
+			vs:       []int{1},
+		},
+		"all": {
+			in: "source:https://t.me/spammer/42",
+			prefixes: []string{
+				"source:https://t.me/",
+				"source:https://t.me/spam",
+				"source:https://t.me/spammer",
+			},
+			vs: []int{1, 2, 3},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			prefixes, vs, err := p.FindAllPrefixes(context.TODO(), c.in)
+			assert.Nil(t, err)
+			assert.Equal(t, c.prefixes, prefixes)
+			assert.Equal(t, c.vs, vs)
+		})
+	}
+}
+
+func TestPrefixes_All(t *testing.T) {
+	p := NewPrefixes[int]()
+	for i, prefix := range []string{"foobar", "bar", "foo", "", "ba"} {
+		require.Nil(t, p.Put(context.TODO(), prefix, i))
+	}
+	require.Nil(t, p.Put(context.TODO(), "bar", 42))
+	assert.Equal(t, 5, p.Len())
+	var prefixes []string
+	var vs []int
+	for prefix, v := range p.All() {
+		prefixes = append(prefixes, prefix)
+		vs = append(vs, v)
+	}
+	assert.Equal(t, []string{"", "ba", "bar", "foo", "foobar"}, prefixes)
+	assert.Equal(t, []int{3, 4, 42, 2, 0}, vs)
+	// the iteration is not affected by the later changes
+	seq := p.All()
+	require.Nil(t, p.Delete(context.TODO(), "foo"))
+	assert.Equal(t, 4, p.Len())
+	var count int
+	for range seq {
+		count++
+	}
+	assert.Equal(t, 5, count)
+}
+
+func TestPrefixes_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	alphabet := "abc"
+	randStr := func() string {
+		b := make([]byte, rnd.Intn(6))
+		for i := range b {
+			b[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	p := NewPrefixes[int]()
+	expected := make(map[string]int)
+	for i := 0; i < 10_000; i++ {
+		prefix := randStr()
+		switch rnd.Intn(3) {
+		case 0:
+			require.Nil(t, p.Delete(context.TODO(), prefix))
+			delete(expected, prefix)
+		default:
+			require.Nil(t, p.Put(context.TODO(), prefix, i))
+			expected[prefix] = i
+		}
+		require.Equal(t, len(expected), p.Len())
+		input := randStr()
+		var longest string
+		for k := range expected {
+			if strings.HasPrefix(input, k) && len(k) > len(longest) {
+				longest = k
+			}
+		}
+		prefix, v, _ := p.FindOnePrefix(context.TODO(), input)
+		require.Equal(t, longest, prefix, input)
+		if _, found := expected[longest]; found {
+			require.Equal(t, expected[longest], v)
+		}
+	}
+	actual := make(map[string]int)
+	for k, v := range p.All() {
+		actual[k] = v
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestPrefixes_Concurrent(t *testing.T) {
+	p := NewPrefixes[int]()
+	require.Nil(t, p.Put(context.TODO(), "source:https://spam.example", 0))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1_000; j++ {
+				prefix, _, _ := p.FindOnePrefix(context.TODO(), "source:https://spam.example/feed")
+				assert.Equal(t, "source:https://spam.example", prefix)
+			}
+		}()
+	}
+	for i := 0; i < 1_000; i++ {
+		prefix := fmt.Sprintf("source:https://spam%d.example", i)
+		require.Nil(t, p.Put(context.TODO(), prefix, i))
+		require.Nil(t, p.Delete(context.TODO(), prefix))
+	}
+	wg.Wait()
+	assert.Equal(t, 1, p.Len())
+}
+
+// lockedTrie is the former implementation kept as the benchmark baseline.
+type lockedTrie[T any] struct {
+	lock *sync.RWMutex
+	t    *trie.Trie[T]
+}
+
+func (lt lockedTrie[T]) Put(prefix string, v T) {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+	lt.t.PutString(prefix, v)
+}
+
+func (lt lockedTrie[T]) FindOnePrefix(input string) (prefix string, v T) {
+	lt.lock.RLock()
+	defer lt.lock.RUnlock()
+	v, length, ok := lt.t.SearchPrefixInString(input)
+	if ok {
+		prefix = input[:length]
+	}
+	return
+}
+
+const benchPrefixCount = 10_000
+
+func benchPrefix(i int) string {
+	return fmt.Sprintf("source:https://spam%d.example", i)
+}
+
+func BenchmarkPrefixes_FindOnePrefix(b *testing.B) {
+	p := NewPrefixes[int]()
+	for i := 0; i < benchPrefixCount; i++ {
+		_ = p.Put(context.TODO(), benchPrefix(i), i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			_, _, _ = p.FindOnePrefix(context.TODO(), benchPrefix(i%benchPrefixCount)+"/feed")
+			i++
+		}
+	})
+}
+
+func BenchmarkLockedTrie_FindOnePrefix(b *testing.B) {
+	lt := lockedTrie[int]{
+		lock: &sync.RWMutex{},
+		t:    &trie.Trie[int]{},
+	}
+	for i := 0; i < benchPrefixCount; i++ {
+		lt.Put(benchPrefix(i), i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			_, _ = lt.FindOnePrefix(benchPrefix(i%benchPrefixCount) + "/feed")
+			i++
+		}
+	})
+}
+
+func BenchmarkPrefixes_FindOnePrefixWhilePut(b *testing.B) {
+	p := NewPrefixes[int]()
+	for i := 0; i < benchPrefixCount; i++ {
+		_ = p.Put(context.TODO(), benchPrefix(i), i)
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go func() {
+		for i := 0; ctx.Err() == nil; i++ {
+			_ = p.Put(context.TODO(), benchPrefix(i%benchPrefixCount), i)
+		}
+	}()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			_, _, _ = p.FindOnePrefix(context.TODO(), benchPrefix(i%benchPrefixCount)+"/feed")
+			i++
+		}
+	})
+}
+
+func BenchmarkLockedTrie_FindOnePrefixWhilePut(b *testing.B) {
+	lt := lockedTrie[int]{
+		lock: &sync.RWMutex{},
+		t:    &trie.Trie[int]{},
+	}
+	for i := 0; i < benchPrefixCount; i++ {
+		lt.Put(benchPrefix(i), i)
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go func() {
+		for i := 0; ctx.Err() == nil; i++ {
+			lt.Put(benchPrefix(i%benchPrefixCount), i)
+		}
+	}()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			_, _ = lt.FindOnePrefix(benchPrefix(i%benchPrefixCount) + "/feed")
+			i++
+		}
+	})
+}
+
+func BenchmarkPrefixes_Put(b *testing.B) {
+	p := NewPrefixes[int]()
+	for i := 0; i < b.N; i++ {
+		_ = p.Put(context.TODO(), benchPrefix(i), i)
+	}
+}
+
+func BenchmarkLockedTrie_Put(b *testing.B) {
+	lt := lockedTrie[int]{
+		lock: &sync.RWMutex{},
+		t:    &trie.Trie[int]{},
+	}
+	for i := 0; i < b.N; i++ {
+		lt.Put(benchPrefix(i), i)
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"sort"
+	"strings"
+)
+
+// ReservedAttributes is the policy protecting the attributes reserved for Awakari, e.g. the targeted delivery ones,
+// from being set by the regular publishers.
+type ReservedAttributes struct {
+	names    map[string]bool
+	prefixes []string
+	reject   bool
+}
+
+var ErrReservedAttribute = errors.New("reserved attribute")
+
+// NewReservedAttributes creates the policy from the patterns: either the exact attribute name or the prefix ending
+// with "*", e.g. "awk*". When reject is false, the reserved attributes are stripped from the event instead of
+// rejecting it.
+func NewReservedAttributes(patterns []string, reject bool) (ra ReservedAttributes) {
+	ra.names = make(map[string]bool)
+	ra.reject = reject
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "":
+		case strings.HasSuffix(p, "*"):
+			ra.prefixes = append(ra.prefixes, strings.TrimSuffix(p, "*"))
+		default:
+			ra.names[p] = true
+		}
+	}
+	return
+}
+
+func (ra ReservedAttributes) Reject() bool {
+	return ra.reject
+}
+
+func (ra ReservedAttributes) isReserved(name string) (reserved bool) {
+	reserved = ra.names[name]
+	for _, p := range ra.prefixes {
+		if reserved {
+			break
+		}
+		reserved = strings.HasPrefix(name, p)
+	}
+	return
+}
+
+// Apply returns the sorted names of the reserved attributes found in the event. The found attributes are stripped
+// from the event unless the policy rejects, then ErrReservedAttribute is returned.
+func (ra ReservedAttributes) Apply(evt *pb.CloudEvent) (found []string, err error) {
+	for k := range evt.Attributes {
+		if ra.isReserved(k) {
+			found = append(found, k)
+		}
+	}
+	if len(found) == 0 {
+		return
+	}
+	sort.Strings(found)
+	switch ra.reject {
+	case true:
+		err = fmt.Errorf("%w: %s", ErrReservedAttribute, strings.Join(found, ", "))
+	default:
+		for _, k := range found {
+			delete(evt.Attributes, k)
+		}
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReservedAttributes_Apply(t *testing.T) {
+	patterns := []string{"awk*", "awakari*", "internal", ""}
+	cases := map[string]struct {
+		reject bool
+		attrs  []string
+		found  []string
+		left   []string
+		err    error
+	}{
+		"none": {
+			attrs: []string{"title", "summary"},
+			left:  []string{"title", "summary"},
+		},
+		"strip": {
+			attrs: []string{"title", KeyToGroupId, KeyToUserId, "internal", KeyCeGroupId},
+			found: []string{KeyCeGroupId, KeyToGroupId, KeyToUserId, "internal"},
+			left:  []string{"title"},
+		},
+		"exact name only": {
+			attrs: []string{"internalfoo"},
+			left:  []string{"internalfoo"},
+		},
+		"reject": {
+			reject: true,
+			attrs:  []string{"title", "awkinternal"},
+			found:  []string{"awkinternal"},
+			left:   []string{"title", "awkinternal"},
+			err:    ErrReservedAttribute,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ra := NewReservedAttributes(patterns, c.reject)
+			evt := &pb.CloudEvent{
+				Attributes: make(map[string]*pb.CloudEventAttributeValue),
+			}
+			for _, a := range c.attrs {
+				evt.Attributes[a] = &pb.CloudEventAttributeValue{
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "foo",
+					},
+				}
+			}
+			found, err := ra.Apply(evt)
+			assert.ErrorIs(t, err, c.err)
+			assert.Equal(t, c.found, found)
+			assert.Len(t, evt.Attributes, len(c.left))
+			for _, a := range c.left {
+				assert.Contains(t, evt.Attributes, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/bytedance/sonic"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is the JSON Schema version registered for the events of the specified type and, optionally, data schema.
+// The schema validates the JSON document like:
+//
+//	{
+//	  "id": "...",
+//	  "source": "...",
+//	  "type": "...",
+//	  "specversion": "1.0",
+//	  "attributes": {"title": "...", "flag": true, "count": 1},
+//	  "data": ...
+//	}
+//
+// where "data" is the parsed JSON when the text data content type is JSON, the string when it's another text and the
+// base64 encoded string for the binary data.
+type Schema struct {
+	Type       string
+	DataSchema string
+	Version    uint32
+	GroupId    string
+	UserId     string
+	CreatedAt  time.Time
+	Value      string
+	compiled   *jsonschema.Schema
+}
+
+var ErrInvalidSchema = errors.New("invalid schema")
+var ErrSchemaViolation = errors.New("event doesn't conform the schema")
+
+const schemaUrl = "schema.json"
+
+// Compile parses the schema value. Validate compiles the schema implicitly when not compiled yet.
+func (s *Schema) Compile() (err error) {
+	c := jsonschema.NewCompiler()
+	// the schema is registered by the user, so it's not allowed to make the service read the files or the network
+	c.LoadURL = rejectExternalRef
+	err = c.AddResource(schemaUrl, strings.NewReader(s.Value))
+	if err == nil {
+		s.compiled, err = c.Compile(schemaUrl)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+	}
+	return
+}
+
+func rejectExternalRef(url string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("external reference is not allowed: %s", url)
+}
+
+// Validate returns ErrSchemaViolation listing every violation with the instance path, e.g. "/attributes/title".
+func (s *Schema) Validate(evt *pb.CloudEvent) (err error) {
+	if s.compiled == nil {
+		err = s.Compile()
+	}
+	if err == nil {
+		err = s.compiled.Validate(schemaInstance(evt))
+	}
+	var errValidation *jsonschema.ValidationError
+	if errors.As(err, &errValidation) {
+		var violations []string
+		collectViolations(errValidation, &violations)
+		sort.Strings(violations)
+		err = fmt.Errorf("%w %s v%d: %s", ErrSchemaViolation, s.Type, s.Version, strings.Join(violations, "; "))
+	}
+	return
+}
+
+func collectViolations(src *jsonschema.ValidationError, dst *[]string) {
+	if len(src.Causes) == 0 {
+		loc := src.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		*dst = append(*dst, fmt.Sprintf("%s: %s", loc, src.Message))
+		return
+	}
+	for _, c := range src.Causes {
+		collectViolations(c, dst)
+	}
+}
+
+func schemaInstance(evt *pb.CloudEvent) (doc map[string]any) {
+	attrs := make(map[string]any, len(evt.Attributes))
+	for k, v := range evt.Attributes {
+		switch vt := v.Attr.(type) {
+		case *pb.CloudEventAttributeValue_CeBoolean:
+			attrs[k] = vt.CeBoolean
+		case *pb.CloudEventAttributeValue_CeInteger:
+			attrs[k] = float64(vt.CeInteger)
+		case *pb.CloudEventAttributeValue_CeString:
+			attrs[k] = vt.CeString
+		case *pb.CloudEventAttributeValue_CeUri:
+			attrs[k] = vt.CeUri
+		case *pb.CloudEventAttributeValue_CeUriRef:
+			attrs[k] = vt.CeUriRef
+		case *pb.CloudEventAttributeValue_CeBytes:
+			attrs[k] = base64.StdEncoding.EncodeToString(vt.CeBytes)
+		case *pb.CloudEventAttributeValue_CeTimestamp:
+			attrs[k] = vt.CeTimestamp.AsTime().Format(time.RFC3339Nano)
+		}
+	}
+	doc = map[string]any{
+		"id":          evt.Id,
+		"source":      evt.Source,
+		"type":        evt.Type,
+		"specversion": evt.SpecVersion,
+		"attributes":  attrs,
+	}
+	switch d := evt.Data.(type) {
+	case *pb.CloudEvent_TextData:
+		var data any = d.TextData
+		if strings.Contains(evt.Attributes["datacontenttype"].GetCeString(), "json") {
+			var parsed any
+			if sonic.UnmarshalString(d.TextData, &parsed) == nil {
+				data = parsed
+			}
+		}
+		doc["data"] = data
+	case *pb.CloudEvent_BinaryData:
+		doc["data"] = base64.StdEncoding.EncodeToString(d.BinaryData)
+	}
+	return
+}
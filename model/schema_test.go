@@ -0,0 +1,136 @@
+package model
+
+import (
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const schemaWebapp = `{
+  "type": "object",
+  "properties": {
+    "attributes": {
+      "type": "object",
+      "properties": {
+        "title": {"type": "string", "minLength": 1},
+        "count": {"type": "integer", "minimum": 0}
+      },
+      "required": ["title"]
+    },
+    "data": {
+      "type": "object",
+      "properties": {
+        "price": {"type": "number"}
+      },
+      "required": ["price"]
+    }
+  }
+}`
+
+func TestSchema_Compile(t *testing.T) {
+	cases := map[string]struct {
+		in  string
+		err error
+	}{
+		"ok": {
+			in: schemaWebapp,
+		},
+		"malformed": {
+			in:  `{"type": `,
+			err: ErrInvalidSchema,
+		},
+		"invalid keyword value": {
+			in:  `{"type": 42}`,
+			err: ErrInvalidSchema,
+		},
+		"internal ref": {
+			in: `{"definitions": {"title": {"type": "string"}}, "properties": {"title": {"$ref": "#/definitions/title"}}}`,
+		},
+		"draft meta schema": {
+			in: `{"$schema": "http://json-schema.org/draft-07/schema#", "type": "object"}`,
+		},
+		"file ref": {
+			in:  `{"$ref": "file:///etc/passwd"}`,
+			err: ErrInvalidSchema,
+		},
+		"http ref": {
+			in:  `{"properties": {"title": {"$ref": "https://example.com/title.json"}}}`,
+			err: ErrInvalidSchema,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			s := Schema{
+				Value: c.in,
+			}
+			err := s.Compile()
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	s := Schema{
+		Type:    "com_awakari_webapp",
+		Version: 2,
+		Value:   schemaWebapp,
+	}
+	cases := map[string]struct {
+		attrs map[string]*pb.CloudEventAttributeValue
+		data  string
+		err   error
+		msg   string
+	}{
+		"ok": {
+			attrs: map[string]*pb.CloudEventAttributeValue{
+				"title": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "foo",
+					},
+				},
+				"count": {
+					Attr: &pb.CloudEventAttributeValue_CeInteger{
+						CeInteger: 1,
+					},
+				},
+			},
+			data: `{"price": 1.5}`,
+		},
+		"missing attribute and wrong type": {
+			attrs: map[string]*pb.CloudEventAttributeValue{
+				"count": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "one",
+					},
+				},
+			},
+			data: `{"price": "1.5"}`,
+			err:  ErrSchemaViolation,
+			msg:  "event doesn't conform the schema com_awakari_webapp v2: /attributes/count: expected integer, but got string; /attributes: missing properties: 'title'; /data/price: expected number, but got string",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			c.attrs["datacontenttype"] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeString{
+					CeString: "application/json",
+				},
+			}
+			evt := &pb.CloudEvent{
+				Id:          "1",
+				Source:      "src1",
+				SpecVersion: "1.0",
+				Type:        "com_awakari_webapp",
+				Attributes:  c.attrs,
+				Data: &pb.CloudEvent_TextData{
+					TextData: c.data,
+				},
+			}
+			err := s.Validate(evt)
+			assert.ErrorIs(t, err, c.err)
+			if c.msg != "" {
+				assert.Equal(t, c.msg, err.Error())
+			}
+		})
+	}
+}
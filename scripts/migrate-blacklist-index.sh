@@ -0,0 +1,20 @@
+#!/bin/bash
+
+# Drops the unique prefix index created before the group blacklist entries were introduced, so the groups may have the
+# same prefixes. Run once after the rollout completes: the previous version pods rely on the index to keep the global
+# prefixes unique. Until then, adding the group entry with the prefix already used by another group or the global entry
+# fails with the conflict.
+#
+# Usage: DB_URI=mongodb://... [DB_NAME=pub] [DB_TABLE_NAME_BLACKLIST=blacklist] ./scripts/migrate-blacklist-index.sh
+
+DB_NAME=${DB_NAME:-pub}
+DB_TABLE_NAME_BLACKLIST=${DB_TABLE_NAME_BLACKLIST:-blacklist}
+mongosh "${DB_URI}" --quiet --eval "
+const coll = db.getSiblingDB('${DB_NAME}').getCollection('${DB_TABLE_NAME_BLACKLIST}');
+if (coll.getIndexes().some(idx => idx.name === 'prefix_1')) {
+  coll.dropIndex('prefix_1');
+  print('dropped the legacy prefix index');
+} else {
+  print('the legacy prefix index is missing, nothing to do');
+}
+"
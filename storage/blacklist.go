@@ -2,11 +2,12 @@ package storage
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/awakari/pub/config"
 	"github.com/awakari/pub/model"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"io"
@@ -15,18 +16,100 @@ import (
 
 type Blacklist interface {
 	io.Closer
-	GetPage(ctx context.Context, limit uint32, cursor string) (p []model.BlacklistEntry, err error)
+	Put(ctx context.Context, e model.BlacklistEntry) (err error)
+
+	// Get returns the entry of the group, the global one when the group id is empty.
+	Get(ctx context.Context, groupId, prefix string) (v model.BlacklistValue, err error)
+
+	// Delete removes the entry of the group, the global one when the group id is empty.
+	Delete(ctx context.Context, groupId, prefix string) (err error)
+
+	// GetPage returns the page of the group entries ordered by prefix, the global entries when the group id is empty.
+	GetPage(ctx context.Context, groupId string, limit uint32, cursor string) (p []model.BlacklistEntry, err error)
+
+	// GetGroupIds returns the ids of the groups having own entries.
+	GetGroupIds(ctx context.Context) (groupIds []string, err error)
+
+	// Watch blocks and invokes the consumer for every entry change until the context is done or the watching fails.
+	// Returns ErrWatchUnsupported when the storage doesn't provide the change stream, e.g. the standalone Mongo.
+	Watch(ctx context.Context, consume func(c BlacklistChange)) (err error)
+}
+
+// BlacklistChange is either the added/updated entry or the deleted one. The deleted entry has only the id when the
+// storage doesn't keep the previous entry state, e.g. when expired.
+type BlacklistChange struct {
+	Entry   model.BlacklistEntry
+	Deleted bool
 }
 
 type blacklistMongoEntry struct {
+	Id primitive.ObjectID `bson:"_id,omitempty"`
+	// GroupId is missing for the global entries.
+	GroupId   string    `bson:"groupId,omitempty"`
 	Prefix    string    `bson:"prefix"`
 	CreatedAt time.Time `bson:"created"`
 	Reason    string    `bson:"reason"`
+	Kind      string    `bson:"kind,omitempty"`
+	WholeWord bool      `bson:"wholeWord,omitempty"`
+	MatchCase bool      `bson:"matchCase,omitempty"`
+	NotBefore time.Time `bson:"notBefore,omitempty"`
+	// ExpiresAt is indexed by TTL, so the expired entries are removed by the database.
+	ExpiresAt time.Time `bson:"expires,omitempty"`
+	Action    string    `bson:"action,omitempty"`
+	RateLimit uint32    `bson:"rateLimit,omitempty"`
+	Allow     bool      `bson:"allow,omitempty"`
+}
+
+type blacklistMongoChange struct {
+	OperationType            string               `bson:"operationType"`
+	FullDocument             *blacklistMongoEntry `bson:"fullDocument"`
+	FullDocumentBeforeChange *blacklistMongoEntry `bson:"fullDocumentBeforeChange"`
+	DocumentKey              blacklistMongoKey    `bson:"documentKey"`
+}
+
+type blacklistMongoKey struct {
+	Id primitive.ObjectID `bson:"_id"`
 }
 
+const attrId = "_id"
+const attrGroupId = "groupId"
 const attrPrefix = "prefix"
 const attrCreated = "created"
 const attrReason = "reason"
+const attrKind = "kind"
+const attrWholeWord = "wholeWord"
+const attrMatchCase = "matchCase"
+const attrNotBefore = "notBefore"
+const attrExpires = "expires"
+const attrAction = "action"
+const attrRateLimit = "rateLimit"
+const attrAllow = "allow"
+
+const opDelete = "delete"
+
+// codeChangeStreamUnsupported is returned by the standalone Mongo: "$changeStream stage is only supported on replica
+// sets".
+const codeChangeStreamUnsupported = 40573
+
+var ErrWatchUnsupported = errors.New("watching the changes is not supported")
+
+var pipelineWatch = mongo.Pipeline{
+	{
+		{
+			Key: "$match",
+			Value: bson.M{
+				"operationType": bson.M{
+					"$in": bson.A{
+						"insert",
+						"update",
+						"replace",
+						opDelete,
+					},
+				},
+			},
+		},
+	},
+}
 
 type blacklistMongo struct {
 	conn *mongo.Client
@@ -34,8 +117,22 @@ type blacklistMongo struct {
 	coll *mongo.Collection
 }
 
-var optsSrvApi = options.ServerAPI(options.ServerAPIVersion1)
+var sortPage = bson.D{
+	{
+		Key:   attrPrefix,
+		Value: 1,
+	},
+}
+
 var projPage = bson.D{
+	{
+		Key:   attrId,
+		Value: 1,
+	},
+	{
+		Key:   attrGroupId,
+		Value: 1,
+	},
 	{
 		Key:   attrPrefix,
 		Value: 1,
@@ -48,25 +145,42 @@ var projPage = bson.D{
 		Key:   attrReason,
 		Value: 1,
 	},
+	{
+		Key:   attrKind,
+		Value: 1,
+	},
+	{
+		Key:   attrWholeWord,
+		Value: 1,
+	},
+	{
+		Key:   attrMatchCase,
+		Value: 1,
+	},
+	{
+		Key:   attrNotBefore,
+		Value: 1,
+	},
+	{
+		Key:   attrExpires,
+		Value: 1,
+	},
+	{
+		Key:   attrAction,
+		Value: 1,
+	},
+	{
+		Key:   attrRateLimit,
+		Value: 1,
+	},
+	{
+		Key:   attrAllow,
+		Value: 1,
+	},
 }
 
 func NewBlacklist(ctx context.Context, cfgDb config.DbConfig) (s Blacklist, err error) {
-	clientOpts := options.
-		Client().
-		ApplyURI(cfgDb.Uri).
-		SetServerAPIOptions(optsSrvApi)
-	if cfgDb.Tls.Enabled {
-		clientOpts = clientOpts.SetTLSConfig(&tls.Config{InsecureSkipVerify: cfgDb.Tls.Insecure})
-	}
-	if len(cfgDb.UserName) > 0 {
-		auth := options.Credential{
-			Username:    cfgDb.UserName,
-			Password:    cfgDb.Password,
-			PasswordSet: len(cfgDb.Password) > 0,
-		}
-		clientOpts = clientOpts.SetAuth(auth)
-	}
-	conn, err := mongo.Connect(ctx, clientOpts)
+	conn, err := connect(ctx, cfgDb)
 	var sm blacklistMongo
 	if err == nil {
 		db := conn.Database(cfgDb.Name)
@@ -86,6 +200,10 @@ func (sm blacklistMongo) ensureIndices(ctx context.Context) ([]string, error) {
 	return sm.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
 			Keys: bson.D{
+				{
+					Key:   attrGroupId,
+					Value: 1,
+				},
 				{
 					Key:   attrPrefix,
 					Value: 1,
@@ -95,6 +213,17 @@ func (sm blacklistMongo) ensureIndices(ctx context.Context) ([]string, error) {
 				Index().
 				SetUnique(true),
 		},
+		{
+			Keys: bson.D{
+				{
+					Key:   attrExpires,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetExpireAfterSeconds(0),
+		},
 	})
 }
 
@@ -102,33 +231,177 @@ func (sm blacklistMongo) Close() error {
 	return sm.conn.Disconnect(context.TODO())
 }
 
-func (sm blacklistMongo) GetPage(ctx context.Context, limit uint32, cursor string) (p []model.BlacklistEntry, err error) {
-	q := bson.M{
-		attrPrefix: bson.M{
-			"$gt": cursor,
-		},
+func (sm blacklistMongo) Put(ctx context.Context, e model.BlacklistEntry) (err error) {
+	rec := blacklistMongoEntry{
+		GroupId:   e.GroupId,
+		Prefix:    e.Prefix,
+		CreatedAt: e.Value.CreatedAt,
+		Reason:    e.Value.Reason,
+		Kind:      string(e.Value.Kind),
+		WholeWord: e.Value.WholeWord,
+		MatchCase: e.Value.MatchCase,
+		NotBefore: e.Value.NotBefore,
+		ExpiresAt: e.Value.ExpiresAt,
+		Action:    string(e.Value.Action),
+		RateLimit: e.Value.RateLimit,
+		Allow:     e.Value.Allow,
+	}
+	_, err = sm.coll.InsertOne(ctx, rec)
+	err = decodeMongoError(err)
+	return
+}
+
+func (sm blacklistMongo) Get(ctx context.Context, groupId, prefix string) (v model.BlacklistValue, err error) {
+	q := queryGroup(groupId)
+	q[attrPrefix] = prefix
+	var rec blacklistMongoEntry
+	err = sm.coll.FindOne(ctx, q).Decode(&rec)
+	err = decodeMongoError(err)
+	if err == nil {
+		v = rec.entry().Value
+	}
+	return
+}
+
+func (sm blacklistMongo) Delete(ctx context.Context, groupId, prefix string) (err error) {
+	q := queryGroup(groupId)
+	q[attrPrefix] = prefix
+	var result *mongo.DeleteResult
+	result, err = sm.coll.DeleteOne(ctx, q)
+	err = decodeMongoError(err)
+	if err == nil && result.DeletedCount < 1 {
+		err = fmt.Errorf("%w: blacklist prefix %s", ErrNotFound, prefix)
+	}
+	return
+}
+
+func (sm blacklistMongo) GetPage(ctx context.Context, groupId string, limit uint32, cursor string) (p []model.BlacklistEntry, err error) {
+	q := queryGroup(groupId)
+	q[attrPrefix] = bson.M{
+		"$gt": cursor,
 	}
 	optsList := options.
 		Find().
 		SetLimit(int64(limit)).
 		SetShowRecordID(false).
+		SetSort(sortPage).
 		SetProjection(projPage)
 	var cur *mongo.Cursor
 	cur, err = sm.coll.Find(ctx, q, optsList)
 	if err == nil {
+		defer cur.Close(ctx)
 		for cur.Next(ctx) {
 			var e blacklistMongoEntry
 			err = errors.Join(err, cur.Decode(&e))
 			if err == nil {
-				p = append(p, model.BlacklistEntry{
-					Prefix: e.Prefix,
-					Value: model.BlacklistValue{
-						CreatedAt: e.CreatedAt,
-						Reason:    e.Reason,
-					},
-				})
+				p = append(p, e.entry())
 			}
 		}
 	}
 	return
 }
+
+func (sm blacklistMongo) GetGroupIds(ctx context.Context) (groupIds []string, err error) {
+	var vals []any
+	vals, err = sm.coll.Distinct(ctx, attrGroupId, bson.M{})
+	err = decodeMongoError(err)
+	for _, val := range vals {
+		if groupId, ok := val.(string); ok && groupId != "" {
+			groupIds = append(groupIds, groupId)
+		}
+	}
+	return
+}
+
+// queryGroup matches the entries of the group, or the global entries without the group id when it's empty.
+func queryGroup(groupId string) (q bson.M) {
+	q = bson.M{
+		attrGroupId: nil,
+	}
+	if groupId != "" {
+		q[attrGroupId] = groupId
+	}
+	return
+}
+
+func (sm blacklistMongo) Watch(ctx context.Context, consume func(c BlacklistChange)) (err error) {
+	optsWatch := options.
+		ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+	var cs *mongo.ChangeStream
+	cs, err = sm.coll.Watch(ctx, pipelineWatch, optsWatch)
+	if err == nil {
+		defer cs.Close(context.TODO())
+		for err == nil && cs.Next(ctx) {
+			var rec blacklistMongoChange
+			err = cs.Decode(&rec)
+			if err == nil {
+				rec.consume(consume)
+			}
+		}
+		if err == nil {
+			err = cs.Err()
+		}
+	}
+	var errSrv mongo.ServerError
+	switch {
+	case errors.As(err, &errSrv) && errSrv.HasErrorCode(codeChangeStreamUnsupported):
+		err = fmt.Errorf("%w: %s", ErrWatchUnsupported, err)
+	default:
+		err = decodeMongoError(err)
+	}
+	return
+}
+
+func (rec blacklistMongoChange) consume(consume func(c BlacklistChange)) {
+	before := rec.FullDocumentBeforeChange
+	after := rec.FullDocument
+	switch {
+	case rec.OperationType == opDelete:
+		c := BlacklistChange{
+			Entry: model.BlacklistEntry{
+				Id: rec.DocumentKey.Id.Hex(),
+			},
+			Deleted: true,
+		}
+		if before != nil {
+			c.Entry = before.entry()
+		}
+		consume(c)
+	case after != nil:
+		if before != nil && (before.GroupId != after.GroupId || before.Prefix != after.Prefix) {
+			consume(BlacklistChange{
+				Entry:   before.entry(),
+				Deleted: true,
+			})
+		}
+		consume(BlacklistChange{
+			Entry: after.entry(),
+		})
+	}
+	return
+}
+
+func (e blacklistMongoEntry) entry() (entry model.BlacklistEntry) {
+	entry = model.BlacklistEntry{
+		GroupId: e.GroupId,
+		Prefix:  e.Prefix,
+		Value: model.BlacklistValue{
+			CreatedAt: e.CreatedAt.UTC(),
+			Reason:    e.Reason,
+			Kind:      model.BlacklistKind(e.Kind),
+			WholeWord: e.WholeWord,
+			MatchCase: e.MatchCase,
+			NotBefore: e.NotBefore.UTC(),
+			ExpiresAt: e.ExpiresAt.UTC(),
+			Action:    model.BlacklistAction(e.Action),
+			RateLimit: e.RateLimit,
+			Allow:     e.Allow,
+		},
+	}
+	if !e.Id.IsZero() {
+		entry.Id = e.Id.Hex()
+	}
+	return
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/pub/model"
+	"time"
+)
+
+type blacklistMock struct {
+}
+
+func NewBlacklistMock() Blacklist {
+	return blacklistMock{}
+}
+
+func (bm blacklistMock) Close() error {
+	return nil
+}
+
+func (bm blacklistMock) Put(ctx context.Context, e model.BlacklistEntry) (err error) {
+	switch e.Prefix {
+	case "conflict":
+		err = fmt.Errorf("%w: blacklist prefix %s", ErrConflict, e.Prefix)
+	case "fail":
+		err = ErrInternal
+	}
+	return
+}
+
+func (bm blacklistMock) Get(ctx context.Context, groupId, prefix string) (v model.BlacklistValue, err error) {
+	switch prefix {
+	case "missing":
+		err = fmt.Errorf("%w: blacklist prefix %s", ErrNotFound, prefix)
+	case "fail":
+		err = ErrInternal
+	default:
+		v = model.BlacklistValue{
+			CreatedAt: time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC),
+			Reason:    "spam",
+		}
+	}
+	return
+}
+
+func (bm blacklistMock) Delete(ctx context.Context, groupId, prefix string) (err error) {
+	switch prefix {
+	case "missing":
+		err = fmt.Errorf("%w: blacklist prefix %s", ErrNotFound, prefix)
+	case "fail":
+		err = ErrInternal
+	}
+	return
+}
+
+func (bm blacklistMock) GetPage(ctx context.Context, groupId string, limit uint32, cursor string) (p []model.BlacklistEntry, err error) {
+	switch {
+	case cursor == "fail":
+		err = ErrInternal
+	case cursor != "":
+	case groupId == "group0":
+		p = []model.BlacklistEntry{
+			{
+				Id:      "blacklist1",
+				GroupId: groupId,
+				Prefix:  "source:https://group.example",
+				Value: model.BlacklistValue{
+					CreatedAt: time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC),
+					Reason:    "off-topic",
+				},
+			},
+		}
+	case groupId == "":
+		p = []model.BlacklistEntry{
+			{
+				Id:     "blacklist0",
+				Prefix: "source:https://spam.example",
+				Value: model.BlacklistValue{
+					CreatedAt: time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC),
+					Reason:    "spam",
+				},
+			},
+		}
+	}
+	return
+}
+
+func (bm blacklistMock) GetGroupIds(ctx context.Context) (groupIds []string, err error) {
+	groupIds = []string{
+		"group0",
+	}
+	return
+}
+
+func (bm blacklistMock) Watch(ctx context.Context, consume func(c BlacklistChange)) (err error) {
+	consume(BlacklistChange{
+		Entry: model.BlacklistEntry{
+			Id:     "blacklist2",
+			Prefix: "source:https://new.example",
+			Value: model.BlacklistValue{
+				CreatedAt: time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC),
+				Reason:    "spam",
+			},
+		},
+	})
+	// deleted without the previous entry state
+	consume(BlacklistChange{
+		Entry: model.BlacklistEntry{
+			Id: "blacklist0",
+		},
+		Deleted: true,
+	})
+	consume(BlacklistChange{
+		Entry: model.BlacklistEntry{
+			Id: "unknown",
+		},
+		Deleted: true,
+	})
+	<-ctx.Done()
+	err = ctx.Err()
+	return
+}
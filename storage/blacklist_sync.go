@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// BlacklistSync keeps the in-memory blacklist in sync with the storage, so the changes made via any replica apply
+// to every replica.
+type BlacklistSync interface {
+
+	// Resync reloads the global and group blacklists and removes the previously loaded prefixes that are not stored
+	// anymore.
+	Resync(ctx context.Context) (err error)
+
+	// Run blocks and applies the storage changes as they happen, falling back to the periodic full reload. The failed
+	// watching is restarted with the exponential backoff, and not restarted at all when the storage doesn't support it.
+	// Returns when the context is done.
+	Run(ctx context.Context)
+}
+
+type blacklistSync struct {
+	stor Blacklist
+	dst  model.BlacklistScopes
+	cfg  config.BlacklistSyncConfig
+	log  *slog.Logger
+	lock *sync.Mutex
+	// loaded are the storage record ids by the key, ids are the keys by the storage record id
+	loaded map[blacklistKey]string
+	ids    map[string]blacklistKey
+}
+
+// blacklistKey is the prefix unique within the group, the group id is empty for the global prefix.
+type blacklistKey struct {
+	groupId string
+	prefix  string
+}
+
+const blacklistSyncPageLimit = 100
+
+func NewBlacklistSync(stor Blacklist, dst model.BlacklistScopes, cfg config.BlacklistSyncConfig, log *slog.Logger) BlacklistSync {
+	return blacklistSync{
+		stor:   stor,
+		dst:    dst,
+		cfg:    cfg,
+		log:    log,
+		lock:   &sync.Mutex{},
+		loaded: make(map[blacklistKey]string),
+		ids:    make(map[string]blacklistKey),
+	}
+}
+
+func (bs blacklistSync) Resync(ctx context.Context) (err error) {
+	// the changes watched meanwhile wait for the reload, so the older state loaded doesn't overwrite these
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+	found := make(map[blacklistKey]model.BlacklistEntry)
+	var groupIds []string
+	groupIds, err = bs.stor.GetGroupIds(ctx)
+	// the global entries first
+	groupIds = append([]string{""}, groupIds...)
+	for _, groupId := range groupIds {
+		if err != nil {
+			break
+		}
+		err = bs.load(ctx, groupId, found)
+	}
+	if err == nil {
+		for k := range bs.loaded {
+			if _, stored := found[k]; !stored {
+				if b, ok := bs.dst.Find(k.groupId); ok {
+					_ = b.Delete(ctx, k.prefix)
+				}
+				bs.forget(k)
+			}
+		}
+		rulesByGroup := make(map[string]map[string]model.BlacklistValue)
+		for k, e := range found {
+			rules, ok := rulesByGroup[k.groupId]
+			if !ok {
+				rules = make(map[string]model.BlacklistValue)
+				rulesByGroup[k.groupId] = rules
+			}
+			rules[k.prefix] = e.Value
+			bs.remember(k, e.Id)
+		}
+		for groupId, rules := range rulesByGroup {
+			if errPut := bs.dst.Get(groupId).PutAll(ctx, rules); errPut != nil {
+				bs.log.Error(fmt.Sprintf("blacklist rules skipped, group: %s: %s", groupId, errPut))
+			}
+		}
+		bs.log.Debug(fmt.Sprintf("blacklist resync: %d prefixes in %d groups", len(found), len(groupIds)-1))
+	}
+	return
+}
+
+func (bs blacklistSync) load(ctx context.Context, groupId string, found map[blacklistKey]model.BlacklistEntry) (err error) {
+	var cursor string
+	var page []model.BlacklistEntry
+	for {
+		page, err = bs.stor.GetPage(ctx, groupId, blacklistSyncPageLimit, cursor)
+		if err != nil || len(page) == 0 {
+			break
+		}
+		cursor = page[len(page)-1].Prefix
+		for _, e := range page {
+			found[blacklistKey{groupId: groupId, prefix: e.Prefix}] = e
+		}
+	}
+	return
+}
+
+// remember tracks the loaded key by the storage record id, so it's found when deleted without the previous state.
+func (bs blacklistSync) remember(k blacklistKey, id string) {
+	if prevId, found := bs.loaded[k]; found && prevId != id {
+		delete(bs.ids, prevId)
+	}
+	bs.loaded[k] = id
+	if id != "" {
+		bs.ids[id] = k
+	}
+}
+
+func (bs blacklistSync) forget(k blacklistKey) {
+	if id, found := bs.loaded[k]; found {
+		delete(bs.ids, id)
+		delete(bs.loaded, k)
+	}
+}
+
+func (bs blacklistSync) Run(ctx context.Context) {
+	go bs.resyncPeriodically(ctx)
+	backoff := bs.cfg.Backoff
+	for {
+		started := time.Now()
+		err := bs.stor.Watch(ctx, func(c BlacklistChange) {
+			bs.apply(ctx, c)
+		})
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, ErrWatchUnsupported):
+			bs.log.Warn(fmt.Sprintf("blacklist changes can't be watched, reloading every %s only: %s", bs.cfg.Interval, err))
+			<-ctx.Done()
+			return
+		case time.Since(started) >= bs.cfg.Interval:
+			// the watching was healthy for a while, so it's not the consecutive failure
+			backoff = bs.cfg.Backoff
+		}
+		bs.log.Warn(fmt.Sprintf("blacklist watch failed, restarting in %s: %s", backoff, err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, bs.cfg.Interval)
+		// catch up the changes missed while not watching
+		if err = bs.Resync(ctx); err != nil {
+			bs.log.Error(fmt.Sprintf("blacklist resync failed: %s", err))
+		}
+	}
+}
+
+func (bs blacklistSync) resyncPeriodically(ctx context.Context) {
+	t := time.NewTicker(bs.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := bs.Resync(ctx); err != nil {
+				bs.log.Error(fmt.Sprintf("blacklist resync failed: %s", err))
+			}
+		}
+	}
+}
+
+func (bs blacklistSync) apply(ctx context.Context, c BlacklistChange) {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+	k := blacklistKey{
+		groupId: c.Entry.GroupId,
+		prefix:  c.Entry.Prefix,
+	}
+	switch {
+	case c.Deleted:
+		if k.prefix == "" {
+			// the previous entry state is unknown, find the loaded key by the id
+			var found bool
+			k, found = bs.ids[c.Entry.Id]
+			if !found {
+				return
+			}
+		}
+		if b, ok := bs.dst.Find(k.groupId); ok {
+			_ = b.Delete(ctx, k.prefix)
+		}
+		bs.forget(k)
+		bs.log.Info(fmt.Sprintf("blacklist prefix removed: %s, group: %s", k.prefix, k.groupId))
+	default:
+		if err := bs.dst.Get(k.groupId).Put(ctx, k.prefix, c.Entry.Value); err != nil {
+			bs.log.Error(fmt.Sprintf("blacklist rule skipped: %s", err))
+			return
+		}
+		bs.remember(k, c.Entry.Id)
+		bs.log.Info(fmt.Sprintf("blacklist prefix added: %s, group: %s", k.prefix, k.groupId))
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBlacklistSync_Resync(t *testing.T) {
+	scopes := model.NewBlacklistScopes()
+	dst := scopes.Get("")
+	require.Nil(t, dst.Put(context.TODO(), "type:spam", model.BlacklistValue{}))
+	bs := NewBlacklistSync(NewBlacklistMock(), scopes, config.BlacklistSyncConfig{}, slog.Default())
+	require.Nil(t, bs.Resync(context.TODO()))
+	prefix, v, _ := dst.Match(context.TODO(), "source", "https://spam.example/feed", true)
+	assert.Equal(t, "source:https://spam.example", prefix)
+	assert.Equal(t, "spam", v.Reason)
+	// the prefix was not loaded by the sync, keep it
+	prefix, _, _ = dst.Match(context.TODO(), "type", "spam", false)
+	assert.Equal(t, "type:spam", prefix)
+	// the group prefix is loaded into the group blacklist only
+	prefix, _, _ = dst.Match(context.TODO(), "source", "https://group.example/feed", true)
+	assert.Empty(t, prefix)
+	group, found := scopes.Find("group0")
+	require.True(t, found)
+	prefix, v, _ = group.Match(context.TODO(), "source", "https://group.example/feed", true)
+	assert.Equal(t, "source:https://group.example", prefix)
+	assert.Equal(t, "off-topic", v.Reason)
+}
+
+func TestBlacklistSync_Run(t *testing.T) {
+	scopes := model.NewBlacklistScopes()
+	dst := scopes.Get("")
+	bs := NewBlacklistSync(NewBlacklistMock(), scopes, config.BlacklistSyncConfig{
+		Interval: time.Hour,
+		Backoff:  time.Millisecond,
+	}, slog.Default())
+	require.Nil(t, bs.Resync(context.TODO()))
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	bs.Run(ctx)
+	prefix, _, _ := dst.Match(context.TODO(), "source", "https://new.example/feed", true)
+	assert.Equal(t, "source:https://new.example", prefix)
+	// deleted by the id
+	prefix, _, _ = dst.Match(context.TODO(), "source", "https://spam.example/feed", true)
+	assert.Empty(t, prefix)
+	group, found := scopes.Find("group0")
+	require.True(t, found)
+	prefix, _, _ = group.Match(context.TODO(), "source", "https://group.example/feed", true)
+	assert.Equal(t, "source:https://group.example", prefix)
+}
+
+type blacklistWatchFailing struct {
+	Blacklist
+	err   error
+	calls *atomic.Int32
+}
+
+func (bwf blacklistWatchFailing) Watch(ctx context.Context, consume func(c BlacklistChange)) (err error) {
+	bwf.calls.Add(1)
+	return bwf.err
+}
+
+func TestBlacklistSync_Run_WatchFailing(t *testing.T) {
+	cases := map[string]struct {
+		err      error
+		callsMin int32
+		callsMax int32
+	}{
+		"unsupported": {
+			err:      ErrWatchUnsupported,
+			callsMin: 1,
+			callsMax: 1,
+		},
+		// the restarts after 10, 30, 70 and 150 ms, the fixed backoff would restart ~20 times
+		"exponential backoff": {
+			err:      ErrInternal,
+			callsMin: 2,
+			callsMax: 6,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			stor := blacklistWatchFailing{
+				Blacklist: NewBlacklistMock(),
+				err:       c.err,
+				calls:     &atomic.Int32{},
+			}
+			bs := NewBlacklistSync(stor, model.NewBlacklistScopes(), config.BlacklistSyncConfig{
+				Interval: time.Hour,
+				Backoff:  10 * time.Millisecond,
+			}, slog.Default())
+			ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+			defer cancel()
+			bs.Run(ctx)
+			calls := stor.calls.Load()
+			assert.GreaterOrEqual(t, calls, c.callsMin)
+			assert.LessOrEqual(t, calls, c.callsMax)
+		})
+	}
+}
@@ -68,14 +68,21 @@ func TestBlacklist_GetPage(t *testing.T) {
 			attrCreated: time.Date(2025, 12, 14, 20, 18, 50, 0, time.UTC),
 			attrReason:  "reason 2",
 		},
+		bson.M{
+			attrGroupId: "group0",
+			attrPrefix:  "foo",
+			attrCreated: time.Date(2025, 12, 14, 20, 18, 50, 0, time.UTC),
+			attrReason:  "reason 3",
+		},
 	})
 	require.Nil(t, err)
 
 	cases := map[string]struct {
-		limit  uint32
-		cursor string
-		out    []model.BlacklistEntry
-		err    error
+		groupId string
+		limit   uint32
+		cursor  string
+		out     []model.BlacklistEntry
+		err     error
 	}{
 		"default": {
 			limit: 10,
@@ -108,6 +115,20 @@ func TestBlacklist_GetPage(t *testing.T) {
 				},
 			},
 		},
+		"group": {
+			groupId: "group0",
+			limit:   10,
+			out: []model.BlacklistEntry{
+				{
+					GroupId: "group0",
+					Prefix:  "foo",
+					Value: model.BlacklistValue{
+						CreatedAt: time.Date(2025, 12, 14, 20, 18, 50, 0, time.UTC),
+						Reason:    "reason 3",
+					},
+				},
+			},
+		},
 		"cursor": {
 			cursor: "foo",
 			out: []model.BlacklistEntry{
@@ -125,9 +146,242 @@ func TestBlacklist_GetPage(t *testing.T) {
 	for k, c := range cases {
 		t.Run(k, func(t *testing.T) {
 			var p []model.BlacklistEntry
-			p, err = s.GetPage(ctx, c.limit, c.cursor)
+			p, err = s.GetPage(ctx, c.groupId, c.limit, c.cursor)
+			for i := range p {
+				assert.NotEmpty(t, p[i].Id)
+				p[i].Id = ""
+			}
 			assert.Equal(t, c.out, p)
 			assert.ErrorIs(t, err, c.err)
 		})
 	}
 }
+
+func TestBlacklist_Put(t *testing.T) {
+	//
+	collName := fmt.Sprintf("blacklist-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "pub",
+	}
+	dbCfg.Table.Blacklist.Name = collName
+	dbCfg.Tls.Enabled = true
+	dbCfg.Tls.Insecure = true
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewBlacklist(ctx, dbCfg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+	//
+	defer clear(ctx, t, s.(blacklistMongo))
+
+	err = s.Put(ctx, model.BlacklistEntry{
+		Prefix: "foo",
+	})
+	require.Nil(t, err)
+
+	cases := map[string]struct {
+		in  model.BlacklistEntry
+		err error
+	}{
+		"ok": {
+			in: model.BlacklistEntry{
+				Prefix: "bar",
+				Value: model.BlacklistValue{
+					CreatedAt: time.Date(2025, 12, 14, 20, 18, 50, 0, time.UTC),
+					Reason:    "spam",
+				},
+			},
+		},
+		"scheduled": {
+			in: model.BlacklistEntry{
+				Prefix: "baz",
+				Value: model.BlacklistValue{
+					CreatedAt: time.Date(2025, 12, 14, 20, 18, 50, 0, time.UTC),
+					Reason:    "cool-down",
+					Action:    model.BlacklistActionThrottle,
+					RateLimit: 10,
+					NotBefore: time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC),
+					ExpiresAt: time.Date(2099, 1, 2, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		"allow": {
+			in: model.BlacklistEntry{
+				Prefix: "source:https://t.me/goodchannel",
+				Value: model.BlacklistValue{
+					CreatedAt: time.Date(2025, 12, 14, 20, 18, 50, 0, time.UTC),
+					Allow:     true,
+				},
+			},
+		},
+		"group": {
+			in: model.BlacklistEntry{
+				GroupId: "group0",
+				Prefix:  "foo",
+				Value: model.BlacklistValue{
+					CreatedAt: time.Date(2025, 12, 14, 20, 18, 50, 0, time.UTC),
+					Reason:    "off-topic",
+				},
+			},
+		},
+		"conflict": {
+			in: model.BlacklistEntry{
+				Prefix: "foo",
+			},
+			err: ErrConflict,
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err = s.Put(ctx, c.in)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				var v model.BlacklistValue
+				v, err = s.Get(ctx, c.in.GroupId, c.in.Prefix)
+				assert.Nil(t, err)
+				assert.Equal(t, c.in.Value, v)
+			}
+		})
+	}
+}
+
+func TestBlacklist_Delete(t *testing.T) {
+	//
+	collName := fmt.Sprintf("blacklist-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "pub",
+	}
+	dbCfg.Table.Blacklist.Name = collName
+	dbCfg.Tls.Enabled = true
+	dbCfg.Tls.Insecure = true
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewBlacklist(ctx, dbCfg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+	//
+	defer clear(ctx, t, s.(blacklistMongo))
+
+	err = s.Put(ctx, model.BlacklistEntry{
+		Prefix: "foo",
+	})
+	require.Nil(t, err)
+
+	cases := map[string]struct {
+		prefix string
+		err    error
+	}{
+		"ok": {
+			prefix: "foo",
+		},
+		"missing": {
+			prefix: "bar",
+			err:    ErrNotFound,
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err = s.Delete(ctx, "", c.prefix)
+			assert.ErrorIs(t, err, c.err)
+			_, err = s.Get(ctx, "", c.prefix)
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestBlacklist_Watch(t *testing.T) {
+	//
+	collName := fmt.Sprintf("blacklist-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "pub",
+	}
+	dbCfg.Table.Blacklist.Name = collName
+	dbCfg.Tls.Enabled = true
+	dbCfg.Tls.Insecure = true
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewBlacklist(ctx, dbCfg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+	//
+	defer clear(ctx, t, s.(blacklistMongo))
+
+	changes := make(chan BlacklistChange, 10)
+	ctxWatch, cancelWatch := context.WithCancel(ctx)
+	go func() {
+		_ = s.Watch(ctxWatch, func(c BlacklistChange) {
+			changes <- c
+		})
+	}()
+	defer cancelWatch()
+	time.Sleep(time.Second) // let the change stream open
+
+	e := model.BlacklistEntry{
+		Prefix: "foo",
+		Value: model.BlacklistValue{
+			CreatedAt: time.Date(2025, 12, 14, 20, 18, 50, 0, time.UTC),
+			Reason:    "spam",
+		},
+	}
+	require.Nil(t, s.Put(ctx, e))
+	c := <-changes
+	id := c.Entry.Id
+	assert.NotEmpty(t, id)
+	c.Entry.Id = ""
+	assert.Equal(t, BlacklistChange{Entry: e}, c)
+
+	require.Nil(t, s.Delete(ctx, e.GroupId, e.Prefix))
+	c = <-changes
+	assert.True(t, c.Deleted)
+	// the id is known also without the previous entry state
+	assert.Equal(t, id, c.Entry.Id)
+}
+
+func TestBlacklist_GetGroupIds(t *testing.T) {
+	//
+	collName := fmt.Sprintf("blacklist-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "pub",
+	}
+	dbCfg.Table.Blacklist.Name = collName
+	dbCfg.Tls.Enabled = true
+	dbCfg.Tls.Insecure = true
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewBlacklist(ctx, dbCfg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+	//
+	defer clear(ctx, t, s.(blacklistMongo))
+
+	for _, e := range []model.BlacklistEntry{
+		{
+			Prefix: "foo",
+		},
+		{
+			GroupId: "group0",
+			Prefix:  "foo",
+		},
+		{
+			GroupId: "group1",
+			Prefix:  "bar",
+		},
+		{
+			GroupId: "group1",
+			Prefix:  "foo",
+		},
+	} {
+		require.Nil(t, s.Put(ctx, e))
+	}
+
+	var groupIds []string
+	groupIds, err = s.GetGroupIds(ctx)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"group0", "group1"}, groupIds)
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"time"
+)
+
+type Hooks interface {
+	io.Closer
+	Create(ctx context.Context, h model.Hook) (err error)
+	Read(ctx context.Context, id string) (h model.Hook, err error)
+	Delete(ctx context.Context, id, groupId, userId string) (err error)
+	List(ctx context.Context, groupId, userId string, limit uint32, cursor string) (ids []string, err error)
+}
+
+var ErrNotFound = errors.New("not found")
+var ErrConflict = errors.New("already exists")
+var ErrInternal = errors.New("internal failure")
+
+type hookMongo struct {
+	Id        string           `bson:"id"`
+	GroupId   string           `bson:"groupId"`
+	UserId    string           `bson:"userId"`
+	CreatedAt time.Time        `bson:"created"`
+	Mapping   hookMappingMongo `bson:"mapping"`
+}
+
+type hookMappingMongo struct {
+	Id         string            `bson:"id,omitempty"`
+	Source     string            `bson:"source"`
+	Type       string            `bson:"type"`
+	TextData   string            `bson:"textData,omitempty"`
+	Attributes map[string]string `bson:"attrs,omitempty"`
+}
+
+const attrHookId = "id"
+const attrHookGroupId = "groupId"
+const attrHookUserId = "userId"
+
+type hooksMongo struct {
+	conn *mongo.Client
+	db   *mongo.Database
+	coll *mongo.Collection
+}
+
+var projHookIds = bson.D{
+	{
+		Key:   attrHookId,
+		Value: 1,
+	},
+}
+
+func NewHooks(ctx context.Context, cfgDb config.DbConfig) (s Hooks, err error) {
+	conn, err := connect(ctx, cfgDb)
+	var sm hooksMongo
+	if err == nil {
+		db := conn.Database(cfgDb.Name)
+		coll := db.Collection(cfgDb.Table.Hooks.Name)
+		sm.conn = conn
+		sm.db = db
+		sm.coll = coll
+		_, err = sm.ensureIndices(ctx)
+	}
+	if err == nil {
+		s = sm
+	}
+	return
+}
+
+func (sm hooksMongo) ensureIndices(ctx context.Context) ([]string, error) {
+	return sm.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{
+					Key:   attrHookId,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{
+					Key:   attrHookGroupId,
+					Value: 1,
+				},
+				{
+					Key:   attrHookUserId,
+					Value: 1,
+				},
+				{
+					Key:   attrHookId,
+					Value: 1,
+				},
+			},
+		},
+	})
+}
+
+func (sm hooksMongo) Close() error {
+	return sm.conn.Disconnect(context.TODO())
+}
+
+func (sm hooksMongo) Create(ctx context.Context, h model.Hook) (err error) {
+	rec := hookMongo{
+		Id:        h.Id,
+		GroupId:   h.GroupId,
+		UserId:    h.UserId,
+		CreatedAt: h.CreatedAt,
+		Mapping: hookMappingMongo{
+			Id:         h.Mapping.Id,
+			Source:     h.Mapping.Source,
+			Type:       h.Mapping.Type,
+			TextData:   h.Mapping.TextData,
+			Attributes: h.Mapping.Attributes,
+		},
+	}
+	_, err = sm.coll.InsertOne(ctx, rec)
+	err = decodeMongoError(err)
+	return
+}
+
+func (sm hooksMongo) Read(ctx context.Context, id string) (h model.Hook, err error) {
+	q := bson.M{
+		attrHookId: id,
+	}
+	var rec hookMongo
+	err = sm.coll.FindOne(ctx, q).Decode(&rec)
+	err = decodeMongoError(err)
+	if err == nil {
+		h = model.Hook{
+			Id:        rec.Id,
+			GroupId:   rec.GroupId,
+			UserId:    rec.UserId,
+			CreatedAt: rec.CreatedAt.UTC(),
+			Mapping: model.HookMapping{
+				Id:         rec.Mapping.Id,
+				Source:     rec.Mapping.Source,
+				Type:       rec.Mapping.Type,
+				TextData:   rec.Mapping.TextData,
+				Attributes: rec.Mapping.Attributes,
+			},
+		}
+	}
+	return
+}
+
+func (sm hooksMongo) Delete(ctx context.Context, id, groupId, userId string) (err error) {
+	q := bson.M{
+		attrHookId:      id,
+		attrHookGroupId: groupId,
+		attrHookUserId:  userId,
+	}
+	var result *mongo.DeleteResult
+	result, err = sm.coll.DeleteOne(ctx, q)
+	err = decodeMongoError(err)
+	if err == nil && result.DeletedCount < 1 {
+		err = fmt.Errorf("%w: hook %s", ErrNotFound, id)
+	}
+	return
+}
+
+func (sm hooksMongo) List(ctx context.Context, groupId, userId string, limit uint32, cursor string) (ids []string, err error) {
+	q := bson.M{
+		attrHookGroupId: groupId,
+		attrHookUserId:  userId,
+		attrHookId: bson.M{
+			"$gt": cursor,
+		},
+	}
+	optsList := options.
+		Find().
+		SetLimit(int64(limit)).
+		SetShowRecordID(false).
+		SetSort(projHookIds).
+		SetProjection(projHookIds)
+	var cur *mongo.Cursor
+	cur, err = sm.coll.Find(ctx, q, optsList)
+	if err == nil {
+		defer cur.Close(ctx)
+		for cur.Next(ctx) {
+			var rec hookMongo
+			err = errors.Join(err, cur.Decode(&rec))
+			if err == nil {
+				ids = append(ids, rec.Id)
+			}
+		}
+	}
+	err = decodeMongoError(err)
+	return
+}
+
+func decodeMongoError(src error) (dst error) {
+	switch {
+	case src == nil:
+	case errors.Is(src, mongo.ErrNoDocuments):
+		dst = fmt.Errorf("%w: %s", ErrNotFound, src)
+	case mongo.IsDuplicateKeyError(src):
+		dst = fmt.Errorf("%w: %s", ErrConflict, src)
+	default:
+		dst = fmt.Errorf("%w: %s", ErrInternal, src)
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/pub/model"
+	"time"
+)
+
+type hooksMock struct {
+}
+
+func NewHooksMock() Hooks {
+	return hooksMock{}
+}
+
+func (hm hooksMock) Close() error {
+	return nil
+}
+
+func (hm hooksMock) Create(ctx context.Context, h model.Hook) (err error) {
+	switch h.Mapping.Source {
+	case "conflict":
+		err = fmt.Errorf("%w: hook %s", ErrConflict, h.Id)
+	case "fail":
+		err = ErrInternal
+	}
+	return
+}
+
+func (hm hooksMock) Read(ctx context.Context, id string) (h model.Hook, err error) {
+	switch id {
+	case "missing":
+		err = fmt.Errorf("%w: hook %s", ErrNotFound, id)
+	case "fail":
+		err = ErrInternal
+	default:
+		h = model.Hook{
+			Id:        id,
+			GroupId:   "group0",
+			UserId:    "user0",
+			CreatedAt: time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC),
+			Mapping: model.HookMapping{
+				Id:       "$.head_commit.id",
+				Source:   "$.repository.html_url",
+				Type:     "com_github_{$.action}",
+				TextData: "$.head_commit.message",
+				Attributes: map[string]string{
+					"author": "$.head_commit.author.name",
+				},
+			},
+		}
+	}
+	return
+}
+
+func (hm hooksMock) Delete(ctx context.Context, id, groupId, userId string) (err error) {
+	switch id {
+	case "missing":
+		err = fmt.Errorf("%w: hook %s", ErrNotFound, id)
+	case "fail":
+		err = ErrInternal
+	}
+	return
+}
+
+func (hm hooksMock) List(ctx context.Context, groupId, userId string, limit uint32, cursor string) (ids []string, err error) {
+	switch groupId {
+	case "fail":
+		err = ErrInternal
+	default:
+		ids = []string{
+			"hook0",
+			"hook1",
+		}
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newHooksTest(ctx context.Context, t *testing.T) (s hooksMongo) {
+	collName := fmt.Sprintf("hooks-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "pub",
+	}
+	dbCfg.Table.Hooks.Name = collName
+	dbCfg.Tls.Enabled = true
+	dbCfg.Tls.Insecure = true
+	hs, err := NewHooks(ctx, dbCfg)
+	require.Nil(t, err)
+	s = hs.(hooksMongo)
+	return
+}
+
+func clearHooks(ctx context.Context, t *testing.T, s hooksMongo) {
+	require.Nil(t, s.coll.Drop(ctx))
+	require.Nil(t, s.Close())
+}
+
+func TestHooks_CreateReadDelete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s := newHooksTest(ctx, t)
+	defer clearHooks(ctx, t, s)
+	//
+	h := model.Hook{
+		Id:        "hook0",
+		GroupId:   "group0",
+		UserId:    "user0",
+		CreatedAt: time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC),
+		Mapping: model.HookMapping{
+			Source: "$.repository.html_url",
+			Type:   "com_github_{$.action}",
+			Attributes: map[string]string{
+				"author": "$.sender.login",
+			},
+		},
+	}
+	err := s.Create(ctx, h)
+	require.Nil(t, err)
+	err = s.Create(ctx, h)
+	assert.ErrorIs(t, err, ErrConflict)
+	//
+	var out model.Hook
+	out, err = s.Read(ctx, "hook0")
+	require.Nil(t, err)
+	assert.Equal(t, h, out)
+	_, err = s.Read(ctx, "hook1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	//
+	err = s.Delete(ctx, "hook0", "group0", "user1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	err = s.Delete(ctx, "hook0", "group0", "user0")
+	assert.Nil(t, err)
+	_, err = s.Read(ctx, "hook0")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestHooks_List(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s := newHooksTest(ctx, t)
+	defer clearHooks(ctx, t, s)
+	//
+	for i, owner := range []string{"user0", "user0", "user1", "user0"} {
+		err := s.Create(ctx, model.Hook{
+			Id:      fmt.Sprintf("hook%d", i),
+			GroupId: "group0",
+			UserId:  owner,
+			Mapping: model.HookMapping{
+				Source: "src0",
+				Type:   "type0",
+			},
+		})
+		require.Nil(t, err)
+	}
+	cases := map[string]struct {
+		userId string
+		limit  uint32
+		cursor string
+		out    []string
+	}{
+		"all": {
+			userId: "user0",
+			limit:  10,
+			out:    []string{"hook0", "hook1", "hook3"},
+		},
+		"limit": {
+			userId: "user0",
+			limit:  2,
+			out:    []string{"hook0", "hook1"},
+		},
+		"cursor": {
+			userId: "user0",
+			limit:  10,
+			cursor: "hook1",
+			out:    []string{"hook3"},
+		},
+		"other user": {
+			userId: "user1",
+			limit:  10,
+			out:    []string{"hook2"},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ids, err := s.List(ctx, "group0", c.userId, c.limit, c.cursor)
+			assert.Nil(t, err)
+			assert.Equal(t, c.out, ids)
+		})
+	}
+}
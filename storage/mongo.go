@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/awakari/pub/config"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var optsSrvApi = options.ServerAPI(options.ServerAPIVersion1)
+
+func connect(ctx context.Context, cfgDb config.DbConfig) (conn *mongo.Client, err error) {
+	clientOpts := options.
+		Client().
+		ApplyURI(cfgDb.Uri).
+		SetServerAPIOptions(optsSrvApi)
+	if cfgDb.Tls.Enabled {
+		clientOpts = clientOpts.SetTLSConfig(&tls.Config{InsecureSkipVerify: cfgDb.Tls.Insecure})
+	}
+	if len(cfgDb.UserName) > 0 {
+		auth := options.Credential{
+			Username:    cfgDb.UserName,
+			Password:    cfgDb.Password,
+			PasswordSet: len(cfgDb.Password) > 0,
+		}
+		clientOpts = clientOpts.SetAuth(auth)
+	}
+	conn, err = mongo.Connect(ctx, clientOpts)
+	return
+}
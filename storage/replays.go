@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"github.com/awakari/pub/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"time"
+)
+
+// Replays remembers the accepted webhook request signatures until these expire, so a replayed request is rejected by
+// any replica.
+type Replays interface {
+	io.Closer
+
+	// Add returns ErrConflict when the signature is added already and not expired yet.
+	Add(ctx context.Context, sig string, expires time.Time) (err error)
+}
+
+type replayMongo struct {
+	Sig     string    `bson:"_id"`
+	Expires time.Time `bson:"expires"`
+}
+
+const attrReplaySig = "_id"
+const attrReplayExpires = "expires"
+
+type replaysMongo struct {
+	conn *mongo.Client
+	db   *mongo.Database
+	coll *mongo.Collection
+}
+
+var optsReplayAdd = options.
+	Update().
+	SetUpsert(true)
+
+func NewReplays(ctx context.Context, cfgDb config.DbConfig) (r Replays, err error) {
+	conn, err := connect(ctx, cfgDb)
+	var rm replaysMongo
+	if err == nil {
+		db := conn.Database(cfgDb.Name)
+		coll := db.Collection(cfgDb.Table.Replays.Name)
+		rm.conn = conn
+		rm.db = db
+		rm.coll = coll
+		_, err = rm.ensureIndices(ctx)
+	}
+	if err == nil {
+		r = rm
+	}
+	return
+}
+
+func (rm replaysMongo) ensureIndices(ctx context.Context) ([]string, error) {
+	return rm.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{
+					Key:   attrReplayExpires,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetExpireAfterSeconds(0),
+		},
+	})
+}
+
+func (rm replaysMongo) Close() error {
+	return rm.conn.Disconnect(context.TODO())
+}
+
+func (rm replaysMongo) Add(ctx context.Context, sig string, expires time.Time) (err error) {
+	// the TTL monitor removes the expired records only once a minute, so the expired record is overwritten here,
+	// while the live one doesn't match and the upsert fails on the duplicate id
+	q := bson.M{
+		attrReplaySig: sig,
+		attrReplayExpires: bson.M{
+			"$lte": time.Now().UTC(),
+		},
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrReplayExpires: expires.UTC(),
+		},
+	}
+	_, err = rm.coll.UpdateOne(ctx, q, u, optsReplayAdd)
+	err = decodeMongoError(err)
+	return
+}
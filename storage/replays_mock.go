@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type replaysMock struct {
+	lock *sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewReplaysMock() Replays {
+	return replaysMock{
+		lock: &sync.Mutex{},
+		seen: make(map[string]time.Time),
+	}
+}
+
+func (rm replaysMock) Close() error {
+	return nil
+}
+
+func (rm replaysMock) Add(ctx context.Context, sig string, expires time.Time) (err error) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	switch {
+	case sig == "fail":
+		err = ErrInternal
+	case time.Now().Before(rm.seen[sig]):
+		err = fmt.Errorf("%w: signature", ErrConflict)
+	default:
+		rm.seen[sig] = expires
+	}
+	return
+}
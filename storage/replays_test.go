@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+func TestReplays_Add(t *testing.T) {
+	collName := fmt.Sprintf("replays-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "pub",
+	}
+	dbCfg.Table.Replays.Name = collName
+	dbCfg.Tls.Enabled = true
+	dbCfg.Tls.Insecure = true
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	r, err := NewReplays(ctx, dbCfg)
+	require.Nil(t, err)
+	rm := r.(replaysMongo)
+	defer func() {
+		require.Nil(t, rm.coll.Drop(ctx))
+		require.Nil(t, rm.Close())
+	}()
+	//
+	now := time.Now().UTC()
+	err = r.Add(ctx, "sig0", now.Add(time.Minute))
+	require.Nil(t, err)
+	err = r.Add(ctx, "sig0", now.Add(time.Minute))
+	assert.ErrorIs(t, err, ErrConflict)
+	err = r.Add(ctx, "sig1", now.Add(time.Minute))
+	assert.Nil(t, err)
+	// expired but not removed by the TTL monitor yet
+	_, err = rm.coll.InsertOne(ctx, replayMongo{
+		Sig:     "sig2",
+		Expires: now.Add(-time.Minute),
+	})
+	require.Nil(t, err)
+	err = r.Add(ctx, "sig2", now.Add(time.Minute))
+	assert.Nil(t, err)
+	var rec replayMongo
+	err = rm.coll.FindOne(ctx, bson.M{attrReplaySig: "sig2"}).Decode(&rec)
+	require.Nil(t, err)
+	assert.True(t, rec.Expires.After(now))
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"sync"
+	"time"
+)
+
+type Schemas interface {
+	io.Closer
+
+	// Create stores the new schema version. Returns ErrConflict when the same version exists already.
+	Create(ctx context.Context, s model.Schema) (err error)
+
+	// Read returns the specified schema version or the latest one when the version is 0.
+	Read(ctx context.Context, typ, dataSchema string, version uint32) (s model.Schema, err error)
+}
+
+type schemaMongo struct {
+	Type       string    `bson:"type"`
+	DataSchema string    `bson:"dataSchema"`
+	Version    uint32    `bson:"version"`
+	GroupId    string    `bson:"groupId"`
+	UserId     string    `bson:"userId"`
+	CreatedAt  time.Time `bson:"created"`
+	Value      string    `bson:"value"`
+}
+
+const attrSchemaType = "type"
+const attrSchemaDataSchema = "dataSchema"
+const attrSchemaVersion = "version"
+
+type schemasMongo struct {
+	conn *mongo.Client
+	db   *mongo.Database
+	coll *mongo.Collection
+}
+
+var optsSchemaReadLatest = options.
+	FindOne().
+	SetSort(bson.D{
+		{
+			Key:   attrSchemaVersion,
+			Value: -1,
+		},
+	})
+
+func NewSchemas(ctx context.Context, cfgDb config.DbConfig) (s Schemas, err error) {
+	conn, err := connect(ctx, cfgDb)
+	var sm schemasMongo
+	if err == nil {
+		db := conn.Database(cfgDb.Name)
+		coll := db.Collection(cfgDb.Table.Schemas.Name)
+		sm.conn = conn
+		sm.db = db
+		sm.coll = coll
+		_, err = sm.ensureIndices(ctx)
+	}
+	if err == nil {
+		s = sm
+	}
+	return
+}
+
+func (sm schemasMongo) ensureIndices(ctx context.Context) ([]string, error) {
+	return sm.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{
+					Key:   attrSchemaType,
+					Value: 1,
+				},
+				{
+					Key:   attrSchemaDataSchema,
+					Value: 1,
+				},
+				{
+					Key:   attrSchemaVersion,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetUnique(true),
+		},
+	})
+}
+
+func (sm schemasMongo) Close() error {
+	return sm.conn.Disconnect(context.TODO())
+}
+
+func (sm schemasMongo) Create(ctx context.Context, s model.Schema) (err error) {
+	rec := schemaMongo{
+		Type:       s.Type,
+		DataSchema: s.DataSchema,
+		Version:    s.Version,
+		GroupId:    s.GroupId,
+		UserId:     s.UserId,
+		CreatedAt:  s.CreatedAt,
+		Value:      s.Value,
+	}
+	_, err = sm.coll.InsertOne(ctx, rec)
+	err = decodeMongoError(err)
+	return
+}
+
+func (sm schemasMongo) Read(ctx context.Context, typ, dataSchema string, version uint32) (s model.Schema, err error) {
+	q := bson.M{
+		attrSchemaType:       typ,
+		attrSchemaDataSchema: dataSchema,
+	}
+	if version > 0 {
+		q[attrSchemaVersion] = version
+	}
+	var rec schemaMongo
+	err = sm.coll.FindOne(ctx, q, optsSchemaReadLatest).Decode(&rec)
+	err = decodeMongoError(err)
+	switch err {
+	case nil:
+		s = model.Schema{
+			Type:       rec.Type,
+			DataSchema: rec.DataSchema,
+			Version:    rec.Version,
+			GroupId:    rec.GroupId,
+			UserId:     rec.UserId,
+			CreatedAt:  rec.CreatedAt.UTC(),
+			Value:      rec.Value,
+		}
+	default:
+		err = fmt.Errorf("schema for type %s, data schema %s, version %d: %w", typ, dataSchema, version, err)
+	}
+	return
+}
+
+type schemasCache struct {
+	stor Schemas
+	ttl  time.Duration
+	size int
+	lock *sync.Mutex
+	// cache is bounded by evicting the least recently used entry, so the absent types requested don't grow it
+	cache map[schemaKey]*list.Element
+	lru   *list.List
+}
+
+type schemaKey struct {
+	typ        string
+	dataSchema string
+}
+
+type schemaCacheEntry struct {
+	k       schemaKey
+	s       *model.Schema
+	expires time.Time
+}
+
+// NewSchemasCache caches the latest schema versions including the absent ones for the specified time to live, keeping
+// at most the specified count of the most recently used entries. The cached schemas are compiled once.
+func NewSchemasCache(stor Schemas, ttl time.Duration, size uint32) Schemas {
+	return schemasCache{
+		stor:  stor,
+		ttl:   ttl,
+		size:  int(size),
+		lock:  &sync.Mutex{},
+		cache: make(map[schemaKey]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+func (sc schemasCache) Close() error {
+	return sc.stor.Close()
+}
+
+func (sc schemasCache) Create(ctx context.Context, s model.Schema) (err error) {
+	err = sc.stor.Create(ctx, s)
+	if err == nil {
+		sc.lock.Lock()
+		defer sc.lock.Unlock()
+		k := schemaKey{typ: s.Type, dataSchema: s.DataSchema}
+		if el, found := sc.cache[k]; found {
+			sc.lru.Remove(el)
+			delete(sc.cache, k)
+		}
+	}
+	return
+}
+
+func (sc schemasCache) Read(ctx context.Context, typ, dataSchema string, version uint32) (s model.Schema, err error) {
+	if version > 0 {
+		return sc.stor.Read(ctx, typ, dataSchema, version)
+	}
+	k := schemaKey{
+		typ:        typ,
+		dataSchema: dataSchema,
+	}
+	now := time.Now()
+	e, found := sc.get(k)
+	if !found || now.After(e.expires) {
+		e = schemaCacheEntry{
+			k:       k,
+			expires: now.Add(sc.ttl),
+		}
+		s, err = sc.stor.Read(ctx, typ, dataSchema, 0)
+		if err == nil {
+			err = s.Compile()
+			e.s = &s
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return
+		}
+		sc.put(e)
+	}
+	switch e.s {
+	case nil:
+		err = fmt.Errorf("schema for type %s, data schema %s: %w", typ, dataSchema, ErrNotFound)
+	default:
+		s = *e.s
+		err = nil
+	}
+	return
+}
+
+func (sc schemasCache) get(k schemaKey) (e schemaCacheEntry, found bool) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	var el *list.Element
+	el, found = sc.cache[k]
+	if found {
+		sc.lru.MoveToFront(el)
+		e = el.Value.(schemaCacheEntry)
+	}
+	return
+}
+
+func (sc schemasCache) put(e schemaCacheEntry) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if el, found := sc.cache[e.k]; found {
+		el.Value = e
+		sc.lru.MoveToFront(el)
+		return
+	}
+	sc.cache[e.k] = sc.lru.PushFront(e)
+	if sc.lru.Len() > sc.size {
+		oldest := sc.lru.Back()
+		sc.lru.Remove(oldest)
+		delete(sc.cache, oldest.Value.(schemaCacheEntry).k)
+	}
+}
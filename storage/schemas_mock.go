@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/pub/model"
+	"time"
+)
+
+type schemasMock struct {
+}
+
+func NewSchemasMock() Schemas {
+	return schemasMock{}
+}
+
+func (sm schemasMock) Close() error {
+	return nil
+}
+
+func (sm schemasMock) Create(ctx context.Context, s model.Schema) (err error) {
+	switch s.Type {
+	case "conflict":
+		err = fmt.Errorf("%w: schema %s v%d", ErrConflict, s.Type, s.Version)
+	case "fail":
+		err = ErrInternal
+	}
+	return
+}
+
+func (sm schemasMock) Read(ctx context.Context, typ, dataSchema string, version uint32) (s model.Schema, err error) {
+	switch {
+	case typ == "fail":
+		err = ErrInternal
+	case typ == "com_awakari_webapp" && dataSchema == "" && version < 2:
+		s = model.Schema{
+			Type:      typ,
+			Version:   1,
+			GroupId:   "group0",
+			UserId:    "user0",
+			CreatedAt: time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC),
+			Value:     `{"type":"object","properties":{"attributes":{"type":"object","required":["title"]}}}`,
+		}
+	default:
+		err = fmt.Errorf("%w: schema %s v%d", ErrNotFound, typ, version)
+	}
+	return
+}
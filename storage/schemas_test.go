@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"github.com/awakari/pub/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSchemas_CreateRead(t *testing.T) {
+	collName := fmt.Sprintf("schemas-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "pub",
+	}
+	dbCfg.Table.Schemas.Name = collName
+	dbCfg.Tls.Enabled = true
+	dbCfg.Tls.Insecure = true
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewSchemas(ctx, dbCfg)
+	require.Nil(t, err)
+	sm := s.(schemasMongo)
+	defer func() {
+		require.Nil(t, sm.coll.Drop(ctx))
+		require.Nil(t, sm.Close())
+	}()
+	//
+	for v := uint32(1); v <= 2; v++ {
+		err = s.Create(ctx, model.Schema{
+			Type:      "type0",
+			Version:   v,
+			GroupId:   "group0",
+			UserId:    "user0",
+			CreatedAt: time.Date(2024, 12, 19, 17, 52, 59, 0, time.UTC),
+			Value:     fmt.Sprintf(`{"title":"v%d"}`, v),
+		})
+		require.Nil(t, err)
+	}
+	err = s.Create(ctx, model.Schema{
+		Type:    "type0",
+		Version: 2,
+	})
+	assert.ErrorIs(t, err, ErrConflict)
+	//
+	cases := map[string]struct {
+		typ        string
+		dataSchema string
+		version    uint32
+		out        string
+		err        error
+	}{
+		"latest": {
+			typ: "type0",
+			out: `{"title":"v2"}`,
+		},
+		"specific version": {
+			typ:     "type0",
+			version: 1,
+			out:     `{"title":"v1"}`,
+		},
+		"missing version": {
+			typ:     "type0",
+			version: 3,
+			err:     ErrNotFound,
+		},
+		"other data schema": {
+			typ:        "type0",
+			dataSchema: "https://example.com/schema.json",
+			err:        ErrNotFound,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			out, err := s.Read(ctx, c.typ, c.dataSchema, c.version)
+			assert.ErrorIs(t, err, c.err)
+			assert.Equal(t, c.out, out.Value)
+		})
+	}
+}
+
+func TestSchemasCache_Read(t *testing.T) {
+	sc := NewSchemasCache(NewSchemasMock(), time.Minute, 10)
+	ctx := context.TODO()
+	s, err := sc.Read(ctx, "com_awakari_webapp", "", 0)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(1), s.Version)
+	// cached
+	s, err = sc.Read(ctx, "com_awakari_webapp", "", 0)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(1), s.Version)
+	// cached absent schema
+	_, err = sc.Read(ctx, "type0", "", 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = sc.Read(ctx, "type0", "", 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	// failures are not cached
+	_, err = sc.Read(ctx, "fail", "", 0)
+	assert.ErrorIs(t, err, ErrInternal)
+}
+
+func TestSchemasCache_Evict(t *testing.T) {
+	sc := NewSchemasCache(NewSchemasMock(), time.Minute, 2).(schemasCache)
+	ctx := context.TODO()
+	for _, typ := range []string{"type0", "type1", "type0", "type2"} {
+		_, err := sc.Read(ctx, typ, "", 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Len(t, sc.cache, 2)
+	assert.Equal(t, 2, sc.lru.Len())
+	// the least recently used is evicted
+	assert.Contains(t, sc.cache, schemaKey{typ: "type0"})
+	assert.Contains(t, sc.cache, schemaKey{typ: "type2"})
+	assert.NotContains(t, sc.cache, schemaKey{typ: "type1"})
+	// the new version invalidates the cached entry
+	require.Nil(t, sc.Create(ctx, model.Schema{Type: "type0"}))
+	assert.NotContains(t, sc.cache, schemaKey{typ: "type0"})
+	assert.Equal(t, 1, sc.lru.Len())
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+)
+
+// Secrets stores the per-user webhook signing secrets.
+type Secrets interface {
+	io.Closer
+
+	// Put creates or replaces the user's secret.
+	Put(ctx context.Context, groupId, userId, secret string) (err error)
+
+	Get(ctx context.Context, groupId, userId string) (secret string, err error)
+}
+
+type secretMongo struct {
+	GroupId string `bson:"groupId"`
+	UserId  string `bson:"userId"`
+	// Encrypted is the nonce followed by the AES-GCM sealed secret.
+	Encrypted []byte `bson:"secretEnc"`
+}
+
+const attrSecretGroupId = "groupId"
+const attrSecretUserId = "userId"
+const attrSecretEncrypted = "secretEnc"
+
+type secretsMongo struct {
+	conn *mongo.Client
+	db   *mongo.Database
+	coll *mongo.Collection
+	aead cipher.AEAD
+}
+
+var ErrSecretKey = errors.New("invalid secrets encryption key, expected hex-encoded 32 bytes")
+var ErrSecretsDisabled = errors.New("signed webhooks are disabled, the secrets encryption key is not set")
+
+var optsSecretPut = options.
+	Update().
+	SetUpsert(true)
+
+// NewSecrets returns the storage failing every call with ErrSecretsDisabled when the encryption key is not set.
+func NewSecrets(ctx context.Context, cfgDb config.DbConfig) (s Secrets, err error) {
+	if cfgDb.Table.Secrets.Key == "" {
+		s = secretsDisabled{}
+		return
+	}
+	var sm secretsMongo
+	sm.aead, err = newSecretsCipher(cfgDb.Table.Secrets.Key)
+	var conn *mongo.Client
+	if err == nil {
+		conn, err = connect(ctx, cfgDb)
+	}
+	if err == nil {
+		db := conn.Database(cfgDb.Name)
+		coll := db.Collection(cfgDb.Table.Secrets.Name)
+		sm.conn = conn
+		sm.db = db
+		sm.coll = coll
+		_, err = sm.ensureIndices(ctx)
+	}
+	if err == nil {
+		s = sm
+	}
+	return
+}
+
+func newSecretsCipher(keyHex string) (aead cipher.AEAD, err error) {
+	var key []byte
+	key, err = hex.DecodeString(keyHex)
+	if err == nil && len(key) != 32 {
+		err = fmt.Errorf("length is %d", len(key))
+	}
+	var block cipher.Block
+	if err == nil {
+		block, err = aes.NewCipher(key)
+	}
+	if err == nil {
+		aead, err = cipher.NewGCM(block)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrSecretKey, err)
+	}
+	return
+}
+
+func (sm secretsMongo) ensureIndices(ctx context.Context) ([]string, error) {
+	return sm.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{
+					Key:   attrSecretGroupId,
+					Value: 1,
+				},
+				{
+					Key:   attrSecretUserId,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetUnique(true),
+		},
+	})
+}
+
+func (sm secretsMongo) Close() error {
+	return sm.conn.Disconnect(context.TODO())
+}
+
+func (sm secretsMongo) Put(ctx context.Context, groupId, userId, secret string) (err error) {
+	q := bson.M{
+		attrSecretGroupId: groupId,
+		attrSecretUserId:  userId,
+	}
+	nonce := make([]byte, sm.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+		return
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrSecretEncrypted: sm.aead.Seal(nonce, nonce, []byte(secret), secretOwner(groupId, userId)),
+		},
+	}
+	_, err = sm.coll.UpdateOne(ctx, q, u, optsSecretPut)
+	err = decodeMongoError(err)
+	return
+}
+
+func (sm secretsMongo) Get(ctx context.Context, groupId, userId string) (secret string, err error) {
+	q := bson.M{
+		attrSecretGroupId: groupId,
+		attrSecretUserId:  userId,
+	}
+	var rec secretMongo
+	err = sm.coll.FindOne(ctx, q).Decode(&rec)
+	err = decodeMongoError(err)
+	if err == nil {
+		secret, err = sm.decrypt(rec.Encrypted, groupId, userId)
+	}
+	if err != nil {
+		err = fmt.Errorf("secret for group %s, user %s: %w", groupId, userId, err)
+	}
+	return
+}
+
+func (sm secretsMongo) decrypt(src []byte, groupId, userId string) (secret string, err error) {
+	n := sm.aead.NonceSize()
+	if len(src) < n {
+		err = fmt.Errorf("%w: encrypted secret is too short", ErrInternal)
+		return
+	}
+	var raw []byte
+	raw, err = sm.aead.Open(nil, src[:n], src[n:], secretOwner(groupId, userId))
+	switch err {
+	case nil:
+		secret = string(raw)
+	default:
+		err = fmt.Errorf("%w: failed to decrypt the secret: %s", ErrInternal, err)
+	}
+	return
+}
+
+// secretOwner is the additional authenticated data, so the encrypted secret can't be copied to another owner.
+func secretOwner(groupId, userId string) []byte {
+	return []byte(groupId + "/" + userId)
+}
+
+type secretsDisabled struct {
+}
+
+func (sd secretsDisabled) Close() error {
+	return nil
+}
+
+func (sd secretsDisabled) Put(ctx context.Context, groupId, userId, secret string) (err error) {
+	return ErrSecretsDisabled
+}
+
+func (sd secretsDisabled) Get(ctx context.Context, groupId, userId string) (secret string, err error) {
+	err = ErrSecretsDisabled
+	return
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+type secretsMock struct {
+}
+
+func NewSecretsMock() Secrets {
+	return secretsMock{}
+}
+
+func (sm secretsMock) Close() error {
+	return nil
+}
+
+func (sm secretsMock) Put(ctx context.Context, groupId, userId, secret string) (err error) {
+	switch groupId {
+	case "fail":
+		err = ErrInternal
+	case "disabled":
+		err = ErrSecretsDisabled
+	}
+	return
+}
+
+func (sm secretsMock) Get(ctx context.Context, groupId, userId string) (secret string, err error) {
+	switch userId {
+	case "missing":
+		err = fmt.Errorf("%w: secret", ErrNotFound)
+	case "fail":
+		err = ErrInternal
+	default:
+		secret = "secret0"
+	}
+	return
+}
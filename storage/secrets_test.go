@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/pub/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+const secretsKeyTest = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func newSecretsTest(ctx context.Context, t *testing.T) (s secretsMongo) {
+	collName := fmt.Sprintf("secrets-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "pub",
+	}
+	dbCfg.Table.Secrets.Name = collName
+	dbCfg.Table.Secrets.Key = secretsKeyTest
+	dbCfg.Tls.Enabled = true
+	dbCfg.Tls.Insecure = true
+	ss, err := NewSecrets(ctx, dbCfg)
+	require.Nil(t, err)
+	s = ss.(secretsMongo)
+	return
+}
+
+func clearSecrets(ctx context.Context, t *testing.T, s secretsMongo) {
+	require.Nil(t, s.coll.Drop(ctx))
+	require.Nil(t, s.Close())
+}
+
+func TestNewSecrets_InvalidKey(t *testing.T) {
+	cases := map[string]string{
+		"not hex":   "secret",
+		"too short": "000102030405060708090a0b0c0d0e0f",
+	}
+	for k, key := range cases {
+		t.Run(k, func(t *testing.T) {
+			dbCfg := config.DbConfig{
+				Uri:  dbUri,
+				Name: "pub",
+			}
+			dbCfg.Table.Secrets.Key = key
+			_, err := NewSecrets(context.TODO(), dbCfg)
+			assert.ErrorIs(t, err, ErrSecretKey)
+		})
+	}
+}
+
+func TestNewSecrets_Disabled(t *testing.T) {
+	s, err := NewSecrets(context.TODO(), config.DbConfig{})
+	require.Nil(t, err)
+	err = s.Put(context.TODO(), "group0", "user0", "secret0")
+	assert.ErrorIs(t, err, ErrSecretsDisabled)
+	_, err = s.Get(context.TODO(), "group0", "user0")
+	assert.ErrorIs(t, err, ErrSecretsDisabled)
+	assert.Nil(t, s.Close())
+}
+
+func TestSecrets_PutGet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s := newSecretsTest(ctx, t)
+	defer clearSecrets(ctx, t, s)
+	//
+	_, err := s.Get(ctx, "group0", "user0")
+	assert.ErrorIs(t, err, ErrNotFound)
+	err = s.Put(ctx, "group0", "user0", "secret0")
+	require.Nil(t, err)
+	var secret string
+	secret, err = s.Get(ctx, "group0", "user0")
+	require.Nil(t, err)
+	assert.Equal(t, "secret0", secret)
+	// replace
+	err = s.Put(ctx, "group0", "user0", "secret1")
+	require.Nil(t, err)
+	secret, err = s.Get(ctx, "group0", "user0")
+	require.Nil(t, err)
+	assert.Equal(t, "secret1", secret)
+	// not stored as the plain text
+	var rec secretMongo
+	err = s.coll.FindOne(ctx, bson.M{attrSecretGroupId: "group0", attrSecretUserId: "user0"}).Decode(&rec)
+	require.Nil(t, err)
+	assert.NotContains(t, string(rec.Encrypted), "secret1")
+	// the encrypted secret copied to another owner doesn't decrypt
+	_, err = s.coll.InsertOne(ctx, secretMongo{
+		GroupId:   "group0",
+		UserId:    "user1",
+		Encrypted: rec.Encrypted,
+	})
+	require.Nil(t, err)
+	_, err = s.Get(ctx, "group0", "user1")
+	assert.ErrorIs(t, err, ErrInternal)
+}